@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A/B test groups, recorded in the task's "ab_group" metadata
+const (
+	ABGroupA = "A"
+	ABGroupB = "B"
+)
+
+// ABTestConfig splits tasks that leave the model to the client between two
+// models, by name. The test is off unless both models are set.
+type ABTestConfig struct {
+	ModelA       string `yaml:"model_a"`
+	ModelB       string `yaml:"model_b"`
+	SplitPercent int    `yaml:"split_percent"` // share of tasks sent to ModelA, 0-100
+}
+
+func (c ABTestConfig) enabled() bool {
+	return c.ModelA != "" && c.ModelB != ""
+}
+
+// group assigns a task to a group. The same UUID always lands in the same
+// group, so resent tasks stay with their model.
+func (c ABTestConfig) group(id uuid.UUID) string {
+	h := fnv.New32a()
+	h.Write(id[:])
+	if int(h.Sum32()%100) < c.SplitPercent {
+		return ABGroupA
+	}
+	return ABGroupB
+}
+
+func (c ABTestConfig) model(group string) string {
+	if group == ABGroupA {
+		return c.ModelA
+	}
+	return c.ModelB
+}
+
+// abStats collects generation results per A/B group
+type abStats struct {
+	mu     sync.Mutex
+	groups map[string]*ModelStats
+}
+
+// assignABGroup picks the task's model by A/B group when the task leaves
+// the model to us
+func (w *WebSocketClient) assignABGroup(ctx context.Context, task *Tasukete) {
+	test := w.config.API.ABTest
+	if !test.enabled() || task.Model != 0 {
+		return
+	}
+
+	group := test.group(task.UUID)
+	name := test.model(group)
+	id := slices.IndexFunc(w.config.Models, func(m ModelConfig) bool { return m.Name == name }) + 1
+	if id == 0 {
+		loggerFromContext(ctx, w.logger).Error("A/B test model not configured", "model", name, "group", group)
+		return
+	}
+	task.Model = id
+	task.AddMetadata("ab_group", group)
+}
+
+// recordABResult adds a finished task to its A/B group's stats
+func (w *WebSocketClient) recordABResult(task *Tasukete, latency time.Duration, err error) {
+	group, ok := task.GetMetadata("ab_group")
+	if !ok {
+		return
+	}
+	name, _ := group.(string)
+
+	w.abStats.mu.Lock()
+	defer w.abStats.mu.Unlock()
+	if w.abStats.groups == nil {
+		w.abStats.groups = make(map[string]*ModelStats)
+	}
+	stats, ok := w.abStats.groups[name]
+	if !ok {
+		stats = &ModelStats{Name: w.config.API.ABTest.model(name)}
+		w.abStats.groups[name] = stats
+	}
+	stats.TotalRequests++
+	stats.TotalLatencyMs += latency.Milliseconds()
+	if err != nil {
+		stats.Failures++
+	}
+}
+
+// CollectABStats returns a snapshot of the generation results per A/B
+// group, keyed by ABGroupA and ABGroupB
+func (w *WebSocketClient) CollectABStats() map[string]ModelStats {
+	w.abStats.mu.Lock()
+	defer w.abStats.mu.Unlock()
+	stats := make(map[string]ModelStats, len(w.abStats.groups))
+	for group, s := range w.abStats.groups {
+		stats[group] = *s
+	}
+	return stats
+}
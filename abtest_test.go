@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestABTest_SplitsTasks(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "Base"
+	config.Models = append(config.Models, ModelConfig{Name: "Tuned", String: "default_model", Width: 512, Height: 512, Steps: 4})
+	config.API.ABTest = ABTestConfig{ModelA: "Tuned", ModelB: "Base", SplitPercent: 30}
+	w := newTestWebSocketClient(t, config)
+
+	groups := map[string]int{}
+	for i := range 100 {
+		task := NewTasukete(TTI, "a cat", 0)
+		task.UUID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(strconv.Itoa(i)))
+		w.handleTask(context.Background(), nil, task)
+
+		group, ok := task.GetMetadata("ab_group")
+		require.True(t, ok)
+		groups[group.(string)]++
+		if group == ABGroupA {
+			assert.Equal(t, 2, task.Model)
+		} else {
+			assert.Equal(t, 1, task.Model)
+		}
+	}
+
+	assert.InDelta(t, 30, groups[ABGroupA], 5)
+	assert.Equal(t, 100, groups[ABGroupA]+groups[ABGroupB])
+
+	stats := w.CollectABStats()
+	assert.Equal(t, "Tuned", stats[ABGroupA].Name)
+	assert.Equal(t, int64(groups[ABGroupA]), stats[ABGroupA].TotalRequests)
+	assert.Equal(t, "Base", stats[ABGroupB].Name)
+	assert.Equal(t, int64(groups[ABGroupB]), stats[ABGroupB].TotalRequests)
+	assert.Zero(t, stats[ABGroupB].Failures)
+}
+
+func TestABTest_KeepsExplicitModel(t *testing.T) {
+	config := MockConfig()
+	config.API.ABTest = ABTestConfig{ModelA: "Tuned", ModelB: "Base", SplitPercent: 50}
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.assignABGroup(context.Background(), task)
+	assert.Equal(t, 1, task.Model)
+	assert.NotContains(t, task.Metadata, "ab_group")
+}
+
+func TestABTestConfig_Group(t *testing.T) {
+	id := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	assert.Equal(t, ABGroupA, ABTestConfig{SplitPercent: 100}.group(id))
+	assert.Equal(t, ABGroupB, ABTestConfig{SplitPercent: 0}.group(id))
+
+	test := ABTestConfig{SplitPercent: 50}
+	assert.Equal(t, test.group(id), test.group(id), "assignment is deterministic")
+}
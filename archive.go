@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// BatchResult pairs a task with the image generated for it
+type BatchResult struct {
+	Task  *Tasukete
+	Image []byte
+}
+
+type manifestEntry struct {
+	UUID     string `json:"uuid"`
+	Prompt   string `json:"prompt"`
+	Model    int    `json:"model"`
+	Filename string `json:"filename"`
+}
+
+// CreateBatchArchive packs batch results into an in-memory ZIP with one file
+// per result plus a manifest.json describing them
+func CreateBatchArchive(results []BatchResult) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := make([]manifestEntry, 0, len(results))
+	for _, r := range results {
+		if r.Task == nil {
+			return nil, fmt.Errorf("batch result without task")
+		}
+		filename := r.Task.UUID.String() + imageExtension(r.Image)
+
+		f, err := zw.Create(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", filename, err)
+		}
+		if _, err := f.Write(r.Image); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+
+		manifest = append(manifest, manifestEntry{
+			UUID:     r.Task.UUID.String(),
+			Prompt:   r.Task.Prompt,
+			Model:    r.Task.Model,
+			Filename: filename,
+		})
+	}
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to add manifest: %w", err)
+	}
+	if err := json.NewEncoder(f).Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".bin"
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBatchArchive(t *testing.T) {
+	results := []BatchResult{
+		{Task: NewTasukete(TTI, "a cat", 1), Image: testPNG(t, 2, 2)},
+		{Task: NewTasukete(TTI, "a dog", 2), Image: testPNG(t, 3, 3)},
+		{Task: NewTasukete(TTI, "a fox", 1), Image: testPNG(t, 4, 4)},
+	}
+
+	data, err := CreateBatchArchive(results)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = content
+	}
+	assert.Len(t, files, 4)
+
+	for _, r := range results {
+		assert.Equal(t, r.Image, files[r.Task.UUID.String()+".png"])
+	}
+
+	var manifest []manifestEntry
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	require.Len(t, manifest, 3)
+	for i, r := range results {
+		assert.Equal(t, r.Task.UUID.String(), manifest[i].UUID)
+		assert.Equal(t, r.Task.Prompt, manifest[i].Prompt)
+		assert.Equal(t, r.Task.Model, manifest[i].Model)
+	}
+}
+
+func TestUploadGeneratedImage_ArchiveEndpoint(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.StartTLS()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockUploads(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	archive, err := CreateBatchArchive([]BatchResult{{Task: NewTasukete(TTI, "a cat", 1), Image: testPNG(t, 2, 2)}})
+	require.NoError(t, err)
+
+	require.NoError(t, client.UploadGeneratedImage(archive))
+	require.NoError(t, client.UploadGeneratedImage(testPNG(t, 2, 2)))
+
+	uploads := mock.Uploads()
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "/images", uploads[0].Path)
+	assert.Equal(t, "/image", uploads[1].Path)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultBaseResolution is the side of the square whose area bounds the
+// pixel count of dimensions derived from an aspect ratio
+const defaultBaseResolution = 1024
+
+var namedAspectRatios = map[string]string{
+	"square":    "1:1",
+	"portrait":  "2:3",
+	"landscape": "3:2",
+}
+
+// ParseAspectRatio turns a ratio like "16:9" or "portrait" into the largest
+// multiple-of-8 dimensions whose area fits in baseResolution x baseResolution
+func ParseAspectRatio(ratio string, baseResolution int) (width, height int, err error) {
+	if baseResolution < 8 {
+		return 0, 0, fmt.Errorf("invalid base resolution: %d", baseResolution)
+	}
+
+	ratio = strings.ToLower(strings.TrimSpace(ratio))
+	if named, ok := namedAspectRatios[ratio]; ok {
+		ratio = named
+	}
+
+	a, b, found := strings.Cut(ratio, ":")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %q", ratio)
+	}
+	rw, errW := strconv.ParseFloat(a, 64)
+	rh, errH := strconv.ParseFloat(b, 64)
+	if errW != nil || errH != nil || rw <= 0 || rh <= 0 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %q", ratio)
+	}
+
+	area := float64(baseResolution * baseResolution)
+	w := math.Sqrt(area * rw / rh)
+	h := w * rh / rw
+
+	width, height = int(w)/8*8, int(h)/8*8
+	if width == 0 || height == 0 {
+		return 0, 0, fmt.Errorf("aspect ratio %q is too extreme for base resolution %d", ratio, baseResolution)
+	}
+	return width, height, nil
+}
+
+// validateDimensions checks an image size the API will accept
+func validateDimensions(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("dimensions must be positive, got %dx%d", width, height)
+	}
+	if width%8 != 0 || height%8 != 0 {
+		return fmt.Errorf("dimensions must be multiples of 8, got %dx%d", width, height)
+	}
+	return nil
+}
+
+// applyAspectRatio fills in Width/Height from AspectRatio, explicit dimensions win
+func (m *ModelConfig) applyAspectRatio(logger *slog.Logger) error {
+	if m.AspectRatio == "" {
+		return nil
+	}
+	if m.Width != 0 || m.Height != 0 {
+		logger.Warn("Model has both aspect ratio and explicit dimensions, using explicit dimensions",
+			"model", m.Name, "aspect_ratio", m.AspectRatio, "width", m.Width, "height", m.Height)
+		return nil
+	}
+
+	width, height, err := ParseAspectRatio(m.AspectRatio, defaultBaseResolution)
+	if err != nil {
+		return err
+	}
+	m.Width, m.Height = width, height
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAspectRatio(t *testing.T) {
+	tests := []struct {
+		ratio         string
+		width, height int
+	}{
+		{"1:1", 1024, 1024},
+		{"square", 1024, 1024},
+		{"16:9", 1360, 768},
+		{"9:16", 768, 1360},
+		{"4:3", 1176, 880},
+		{"portrait", 832, 1248},
+		{"landscape", 1248, 832},
+		{" Landscape ", 1248, 832},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ratio, func(t *testing.T) {
+			width, height, err := ParseAspectRatio(tt.ratio, 1024)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.width, width)
+			assert.Equal(t, tt.height, height)
+			assert.Zero(t, width%8)
+			assert.Zero(t, height%8)
+			assert.LessOrEqual(t, width*height, 1024*1024)
+		})
+	}
+}
+
+func TestParseAspectRatio_Invalid(t *testing.T) {
+	for _, ratio := range []string{"", "16", "16:", ":9", "a:b", "0:1", "-1:1", "wide", "100000:1"} {
+		_, _, err := ParseAspectRatio(ratio, 1024)
+		assert.Error(t, err, ratio)
+	}
+
+	_, _, err := ParseAspectRatio("1:1", 0)
+	assert.Error(t, err)
+}
+
+func TestModelConfig_ApplyAspectRatio(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	m := ModelConfig{Name: "ratio", AspectRatio: "16:9"}
+	assert.NoError(t, m.applyAspectRatio(logger))
+	assert.Equal(t, 1360, m.Width)
+	assert.Equal(t, 768, m.Height)
+
+	// explicit dimensions win
+	m = ModelConfig{Name: "explicit", AspectRatio: "16:9", Width: 512, Height: 512}
+	assert.NoError(t, m.applyAspectRatio(logger))
+	assert.Equal(t, 512, m.Width)
+	assert.Equal(t, 512, m.Height)
+}
+
+func TestLoadConfig_AppliesAspectRatio(t *testing.T) {
+	path := writeTempFile(t, "config.yaml", `
+version: 1
+models:
+  - name: wide
+    aspect_ratio: "16:9"
+`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1360, cfg.Models[0].Width)
+	assert.Equal(t, 768, cfg.Models[0].Height)
+
+	path = writeTempFile(t, "config.yaml", `
+version: 1
+models:
+  - name: broken
+    aspect_ratio: "sideways"
+`)
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, "broken")
+}
+
+func TestNewClient_DoesNotModifyConfig(t *testing.T) {
+	config := MockConfig()
+	config.Models[0].AspectRatio = "16:9"
+	config.Models[0].Width, config.Models[0].Height = 0, 0
+
+	NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Zero(t, config.Models[0].Width)
+	assert.Zero(t, config.Models[0].Height)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// missingRequestedBy is the rejection reason for unattributed tasks when
+// server.require_requested_by is set
+const missingRequestedBy = "requested_by required"
+
+// withRequester adds the task's requested_by to the request-scoped logger
+func withRequester(ctx context.Context, task *Tasukete, fallback *slog.Logger) context.Context {
+	if task.RequestedBy == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey, loggerFromContext(ctx, fallback).With("requested_by", task.RequestedBy))
+}
+
+// requesterCounts counts handled tasks per requested_by
+type requesterCounts struct {
+	counts sync.Map // requested_by -> *atomic.Int64
+}
+
+func (r *requesterCounts) add(requestedBy string) {
+	v, _ := r.counts.LoadOrStore(requestedBy, new(atomic.Int64))
+	v.(*atomic.Int64).Add(1)
+}
+
+// TasksByRequester returns how many tasks each requested_by has had
+// handled, with unattributed tasks under ""
+func (w *WebSocketClient) TasksByRequester() map[string]int64 {
+	counts := make(map[string]int64)
+	w.requesters.counts.Range(func(key, value any) bool {
+		counts[key.(string)] = value.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
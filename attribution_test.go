@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMessage_RequireRequestedBy(t *testing.T) {
+	config := MockConfig()
+	config.Server.RequireRequestedBy = true
+	w := newTestWebSocketClient(t, config)
+
+	send := func(task *Tasukete) {
+		payload, err := json.Marshal(task)
+		require.NoError(t, err)
+		w.handleMessage(nil, WebSocketMessage{Type: "task", Payload: payload})
+	}
+
+	anonymous := NewTasukete(TTI, "a cat", 1)
+	send(anonymous)
+	assert.Empty(t, w.queue, "unattributed tasks must not be dispatched")
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	var update Tasukete
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &update))
+	assert.Equal(t, anonymous.UUID, update.UUID)
+	assert.Equal(t, StatusFailed, update.Status())
+	assert.Equal(t, missingRequestedBy, update.Metadata["reason"])
+
+	attributed := NewTasukete(TTI, "a cat", 1)
+	attributed.RequestedBy = "alice"
+	send(attributed)
+	require.Len(t, w.queue, 1)
+	assert.Equal(t, "alice", (<-w.queue).task.RequestedBy)
+}
+
+func TestHandleTTITask_RequestedBy(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	var logs bytes.Buffer
+	w := newLoggingWebSocketClient(t, config, &logs)
+
+	for _, requester := range []string{"alice", "alice", "bob"} {
+		task := NewTasukete(TTI, "a cat", 1)
+		task.RequestedBy = requester
+		w.handleTTITask(context.Background(), nil, task)
+	}
+	failed := NewTasukete(TTI, "a cat", 7)
+	failed.RequestedBy = "carol"
+	w.handleTTITask(context.Background(), nil, failed)
+
+	assert.Equal(t, map[string]int64{"alice": 2, "bob": 1, "carol": 1}, w.TasksByRequester())
+
+	var found bool
+	dec := json.NewDecoder(&logs)
+	for dec.More() {
+		var record map[string]any
+		require.NoError(t, dec.Decode(&record))
+		if record["msg"] == "Image generation failed" {
+			found = true
+			assert.Equal(t, "carol", record["requested_by"])
+		}
+	}
+	assert.True(t, found, "generation failure was logged")
+}
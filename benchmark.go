@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// benchmarkPrompt is generated by every benchmark, so runs are comparable
+const benchmarkPrompt = "a red apple on a wooden table, studio lighting"
+
+// BenchmarkResult is the outcome of one benchmark generation
+type BenchmarkResult struct {
+	ModelName      string `json:"model_name"`
+	LatencyMs      int64  `json:"latency_ms"`
+	ImageSizeBytes int    `json:"image_size_bytes"`
+	Steps          int    `json:"steps"`
+	Resolution     string `json:"resolution"` // WIDTHxHEIGHT
+}
+
+// Benchmark times one generation of a fixed prompt with the model.
+// Benchmarks run one at a time so they don't skew each other.
+func (c *Client) Benchmark(ctx context.Context, modelID int) (BenchmarkResult, error) {
+	c.benchmarkMu.Lock()
+	defer c.benchmarkMu.Unlock()
+
+	start := time.Now()
+	result, err := c.Generate(ctx, GenerateRequest{Prompt: benchmarkPrompt, ModelID: modelID})
+	latency := time.Since(start)
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+
+	// report the model that actually ran, which may be a fallback
+	model, ok := c.modelByName(result.ModelName)
+	if !ok {
+		model = c.config.Models[modelID-1]
+	}
+	return BenchmarkResult{
+		ModelName:      result.ModelName,
+		LatencyMs:      int64((latency + time.Millisecond - 1) / time.Millisecond),
+		ImageSizeBytes: len(result.Image),
+		Steps:          model.Steps,
+		Resolution:     fmt.Sprintf("%dx%d", model.Width, model.Height),
+	}, nil
+}
+
+// BenchmarkHandler serves POST /benchmark/{model_id} with the client's
+// Benchmark result. Requests must carry apiKey as a bearer token or in
+// X-API-Key; with an empty apiKey every request is refused.
+func BenchmarkHandler(c *Client, apiKey string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validAPIKey(r, apiKey) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		modelID, err := strconv.Atoi(r.PathValue("model_id"))
+		if err != nil || modelID <= 0 || modelID > len(c.config.Models) {
+			http.Error(w, "unknown model", http.StatusNotFound)
+			return
+		}
+
+		result, err := c.Benchmark(r.Context(), modelID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// validAPIKey checks the request's bearer token or X-API-Key against apiKey
+func validAPIKey(r *http.Request, apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	given := r.Header.Get("X-API-Key")
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = token
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(apiKey)) == 1
+}
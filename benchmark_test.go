@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBenchmarkServer(t *testing.T, mock *MockSwarmUIServer) *httptest.Server {
+	t.Helper()
+	backend := mock.Start()
+	t.Cleanup(backend.Close)
+
+	config := MockConfig()
+	useMockAPI(config, backend)
+	config.Models[0].Name = "SD"
+	config.Models[0].Steps = 20
+	config.Models[0].Width, config.Models[0].Height = 512, 768
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	mux := http.NewServeMux()
+	mux.Handle("/benchmark/{model_id}", BenchmarkHandler(client, "secret"))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postBenchmark(t *testing.T, server *httptest.Server, modelID, apiKey string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/benchmark/"+modelID, nil)
+	require.NoError(t, err)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestBenchmarkHandler(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(10 * time.Millisecond)
+	server := newBenchmarkServer(t, mock)
+
+	resp := postBenchmark(t, server, "1", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	latency, ok := body["latency_ms"].(float64)
+	require.True(t, ok, "latency_ms is a number")
+	assert.Equal(t, float64(int64(latency)), latency, "latency_ms is an integer")
+	assert.GreaterOrEqual(t, latency, float64(10))
+
+	assert.Equal(t, "SD", body["model_name"])
+	assert.Equal(t, float64(20), body["steps"])
+	assert.Equal(t, "512x768", body["resolution"])
+	assert.Positive(t, body["image_size_bytes"])
+	assert.Equal(t, benchmarkPrompt, mock.Generations()[0]["prompt"])
+}
+
+func TestBenchmarkHandler_Rejects(t *testing.T) {
+	server := newBenchmarkServer(t, NewMockSwarmUIServer())
+
+	assert.Equal(t, http.StatusUnauthorized, postBenchmark(t, server, "1", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, postBenchmark(t, server, "1", "wrong").StatusCode)
+	assert.Equal(t, http.StatusNotFound, postBenchmark(t, server, "99", "secret").StatusCode)
+
+	resp, err := http.Get(server.URL + "/benchmark/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestBenchmarkHandler_RunsOneAtATime(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(50 * time.Millisecond)
+	server := newBenchmarkServer(t, mock)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, postBenchmark(t, server, "1", "secret").StatusCode)
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "benchmarks ran in sequence")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// HTTPServerConfig holds settings for the HTTP server and its handlers
+type HTTPServerConfig struct {
+	Addr        string `yaml:"addr"`          // listen address, e.g. ":8080", empty disables the server
+	TLSCertFile string `yaml:"tls_cert_file"` // serve HTTPS, and HTTP/2 for http2_push, when set with tls_key_file
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	APIKey      string `yaml:"api_key"` // required by POST /benchmark, which is refused when empty
+
+	MaxRequestBodySize int64 `yaml:"max_request_body_size"` // bytes, defaultMaxRequestBodySize when 0
+
+	CSP string `yaml:"content_security_policy"` // defaultCSP when empty
+
+	HTTP2Push bool `yaml:"http2_push"` // push /models and /status with /dashboard to HTTP/2 clients
+}
+
+// defaultMaxRequestBodySize is the body limit when max_request_body_size is unset
+const defaultMaxRequestBodySize = 1 << 20
+
+func (c HTTPServerConfig) maxRequestBodySize() int64 {
+	if c.MaxRequestBodySize > 0 {
+		return c.MaxRequestBodySize
+	}
+	return defaultMaxRequestBodySize
+}
+
+// LimitRequestBody refuses requests whose body is over limit bytes with a
+// 413. Bodies that announce their length are refused before next runs;
+// reads past the limit from the rest fail with *http.MaxBytesError, which
+// next should answer with writeBodyTooLarge.
+func LimitRequestBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isBodyTooLarge reports whether err came from reading past a
+// LimitRequestBody limit
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// writeBodyTooLarge answers with a 413 and a JSON error body
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":     "request body too large",
+		"max_bytes": limit,
+	})
+}
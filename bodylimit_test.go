@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	limit := HTTPServerConfig{}.maxRequestBodySize()
+	var calls atomic.Int32
+	server := httptest.NewServer(LimitRequestBody(limit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, err := io.ReadAll(r.Body)
+		if isBodyTooLarge(err) {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		w.Write([]byte(strconv.Itoa(len(body))))
+	})))
+	defer server.Close()
+	large := bytes.Repeat([]byte("a"), 2<<20)
+
+	t.Run("announced length over the limit", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/generate", "application/json", bytes.NewReader(large))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "request body too large", body["error"])
+		assert.Equal(t, float64(limit), body["max_bytes"])
+		assert.Zero(t, calls.Load(), "handler must not run")
+	})
+
+	t.Run("streamed body over the limit", func(t *testing.T) {
+		// hiding the length makes the client send the body chunked
+		resp, err := http.Post(server.URL+"/generate", "application/json", struct{ io.Reader }{bytes.NewReader(large)})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("body within the limit", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/generate", "application/json", bytes.NewReader([]byte(`{"prompt":"a cat"}`)))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "18", string(body))
+	})
+}
+
+func TestHTTPServerConfig_MaxRequestBodySize(t *testing.T) {
+	assert.Equal(t, int64(1<<20), HTTPServerConfig{}.maxRequestBodySize())
+	assert.Equal(t, int64(4096), HTTPServerConfig{MaxRequestBodySize: 4096}.maxRequestBodySize())
+}
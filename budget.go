@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// budgetWindow is how far back generation steps count against the budget
+const budgetWindow = 24 * time.Hour
+
+// ErrBudgetExceeded means a generation would go over the daily step limit
+var ErrBudgetExceeded = errors.New("daily step budget exceeded")
+
+type BudgetConfig struct {
+	DailyStepLimit int     `yaml:"daily_step_limit"` // 0 disables the budget
+	AlertThreshold float64 `yaml:"alert_threshold"`  // fraction of the limit that triggers a warning, 0 disables
+}
+
+type budgetEntry struct {
+	At    time.Time `json:"at"`
+	Steps int       `json:"steps"`
+}
+
+// BudgetEnforcer counts generation steps over a rolling 24 hours and refuses
+// generations that would exceed the daily limit. With a path, the counter
+// survives restarts.
+type BudgetEnforcer struct {
+	config BudgetConfig
+	path   string
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries []budgetEntry
+}
+
+// NewBudgetEnforcer creates an enforcer, loading the counter from path if it
+// exists. An empty path keeps the counter in memory only.
+func NewBudgetEnforcer(config BudgetConfig, path string, logger *slog.Logger) (*BudgetEnforcer, error) {
+	b := &BudgetEnforcer{config: config, path: path, logger: logger, now: time.Now}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budget counter: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse budget counter %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Reserve charges steps against the budget, or returns ErrBudgetExceeded
+// without charging anything if they don't fit
+func (b *BudgetEnforcer) Reserve(ctx context.Context, steps int) error {
+	logger := loggerFromContext(ctx, b.logger)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	used := b.prune(now)
+	if used+steps > b.config.DailyStepLimit {
+		return fmt.Errorf("%w: %d of %d steps used, %d requested", ErrBudgetExceeded, used, b.config.DailyStepLimit, steps)
+	}
+	b.entries = append(b.entries, budgetEntry{At: now, Steps: steps})
+
+	if threshold := b.config.AlertThreshold * float64(b.config.DailyStepLimit); threshold > 0 &&
+		float64(used) < threshold && float64(used+steps) >= threshold {
+		logger.Warn("Daily step budget nearly used", "used", used+steps, "limit", b.config.DailyStepLimit)
+	}
+
+	if err := b.save(); err != nil {
+		logger.Error("Failed to save budget counter", "path", b.path, "error", err)
+	}
+	return nil
+}
+
+// Charge records steps already spent, such as a fallback model's extra
+// steps, even if they go over the limit
+func (b *BudgetEnforcer) Charge(ctx context.Context, steps int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries = append(b.entries, budgetEntry{At: b.now(), Steps: steps})
+	if err := b.save(); err != nil {
+		loggerFromContext(ctx, b.logger).Error("Failed to save budget counter", "path", b.path, "error", err)
+	}
+}
+
+// Refund gives back steps from the latest Reserve of at least that many,
+// for generations that failed or used fewer steps
+func (b *BudgetEnforcer) Refund(ctx context.Context, steps int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := len(b.entries) - 1; i >= 0; i-- {
+		if b.entries[i].Steps == steps {
+			b.entries = slices.Delete(b.entries, i, i+1)
+			break
+		}
+		if b.entries[i].Steps > steps {
+			b.entries[i].Steps -= steps
+			break
+		}
+	}
+	if err := b.save(); err != nil {
+		loggerFromContext(ctx, b.logger).Error("Failed to save budget counter", "path", b.path, "error", err)
+	}
+}
+
+// Used returns the steps charged in the last 24 hours
+func (b *BudgetEnforcer) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.prune(b.now())
+}
+
+// prune drops entries older than the window and returns the steps left,
+// b.mu must be held
+func (b *BudgetEnforcer) prune(now time.Time) int {
+	cutoff := now.Add(-budgetWindow)
+	kept := b.entries[:0]
+	used := 0
+	for _, e := range b.entries {
+		if e.At.After(cutoff) {
+			kept = append(kept, e)
+			used += e.Steps
+		}
+	}
+	b.entries = kept
+	return used
+}
+
+// save writes the counter through a temporary file, b.mu must be held
+func (b *BudgetEnforcer) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(b.entries)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, data, 0o644)
+}
+
+// WithBudget enforces a daily generation step limit
+func WithBudget(budget *BudgetEnforcer) ClientOption {
+	return func(c *Client) {
+		c.budget = budget
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetEnforcer_Limit(t *testing.T) {
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 50}, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	budget.now = func() time.Time { return now }
+
+	require.NoError(t, budget.Reserve(context.Background(), 20))
+	require.NoError(t, budget.Reserve(context.Background(), 30))
+	assert.ErrorIs(t, budget.Reserve(context.Background(), 1), ErrBudgetExceeded)
+	assert.Equal(t, 50, budget.Used())
+
+	// the first steps roll out of the window a day later
+	now = now.Add(budgetWindow + time.Second)
+	assert.Equal(t, 0, budget.Used())
+	assert.NoError(t, budget.Reserve(context.Background(), 50))
+}
+
+func TestBudgetEnforcer_Alert(t *testing.T) {
+	var logs bytes.Buffer
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 100, AlertThreshold: 0.8}, "", slog.New(slog.NewTextHandler(&logs, nil)))
+	require.NoError(t, err)
+
+	require.NoError(t, budget.Reserve(context.Background(), 70))
+	assert.NotContains(t, logs.String(), "level=WARN")
+
+	require.NoError(t, budget.Reserve(context.Background(), 20))
+	assert.Equal(t, 1, strings.Count(logs.String(), "level=WARN"))
+
+	// only the crossing warns
+	require.NoError(t, budget.Reserve(context.Background(), 5))
+	assert.Equal(t, 1, strings.Count(logs.String(), "level=WARN"))
+}
+
+func TestBudgetEnforcer_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 100}, path, logger)
+	require.NoError(t, err)
+	require.NoError(t, budget.Reserve(context.Background(), 60))
+
+	reloaded, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 100}, path, logger)
+	require.NoError(t, err)
+	assert.Equal(t, 60, reloaded.Used())
+	assert.ErrorIs(t, reloaded.Reserve(context.Background(), 41), ErrBudgetExceeded)
+}
+
+func TestGenerateImage_BudgetExceeded(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 30}, "", logger)
+	require.NoError(t, err)
+	client := NewClient(config, logger, WithBudget(budget))
+
+	// the mock model runs 20 steps per image
+	_, err = client.GenerateImage("a cat", 1)
+	require.NoError(t, err)
+	_, err = client.GenerateImage("a cat", 1)
+	assert.True(t, errors.Is(err, ErrBudgetExceeded), "expected ErrBudgetExceeded, got %v", err)
+	assert.Equal(t, 1, mock.CallCount("/API/GenerateText2Image"))
+}
+
+func TestGenerateImage_RefundsFailedGeneration(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetFailOnNthRequest(2, 3, 4, 5, 6)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 30}, "", logger)
+	require.NoError(t, err)
+	client := NewClient(config, logger, WithBudget(budget))
+
+	_, err = client.GenerateImage("a cat", 1)
+	require.Error(t, err)
+	assert.Equal(t, 0, budget.Used())
+}
+
+func TestBudgetEnforcer_Refund(t *testing.T) {
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 50}, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	require.NoError(t, budget.Reserve(context.Background(), 20))
+	require.NoError(t, budget.Reserve(context.Background(), 30))
+	budget.Refund(context.Background(), 30)
+	assert.Equal(t, 20, budget.Used())
+	assert.NoError(t, budget.Reserve(context.Background(), 30))
+
+	// partial refunds shrink the latest large enough reservation
+	budget.Refund(context.Background(), 10)
+	assert.Equal(t, 40, budget.Used())
+
+	budget.Charge(context.Background(), 20)
+	assert.Equal(t, 60, budget.Used(), "charges may go over the limit")
+}
+
+func TestGenerate_BudgetChargesRetries(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 1}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 100}, "", logger)
+	require.NoError(t, err)
+	client := NewClient(config, logger, WithBudget(budget))
+
+	// the blank first image and its retry each run 20 steps
+	mock.SetNextImageResponse(solidPNG(t, 32, 32, color.Black))
+	_, _, err = client.generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 40, budget.Used())
+
+	// a retry that doesn't fit the budget isn't run
+	budget.config.DailyStepLimit = 60
+	mock.SetNextImageResponse(solidPNG(t, 32, 32, color.Black))
+	_, _, err = client.generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1})
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, 60, budget.Used())
+	assert.Equal(t, 3, mock.CallCount("/API/GenerateText2Image"))
+}
+
+func TestGenerate_BudgetChargesFallbackSteps(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetUnavailableModels("primary_model")
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models = []ModelConfig{
+		{Name: "Primary", String: "primary_model", Steps: 20, FallbackModels: []string{"Backup"}},
+		{Name: "Backup", String: "backup_model", Steps: 30},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	budget, err := NewBudgetEnforcer(BudgetConfig{DailyStepLimit: 100}, "", logger)
+	require.NoError(t, err)
+	client := NewClient(config, logger, WithBudget(budget))
+
+	_, model, err := client.generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1})
+	require.NoError(t, err)
+	require.Equal(t, "Backup", model.Name)
+	assert.Equal(t, 30, budget.Used(), "charged the steps the fallback ran")
+}
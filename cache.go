@@ -0,0 +1,77 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+const defaultCacheSize = 100
+
+// GenerationKey identifies a deterministic generation; the same key always
+// yields the same image
+type GenerationKey struct {
+	Prompt    string
+	ModelName string
+	Seed      int64
+}
+
+// GenerationCache stores finished images so seeded generations can skip the API
+type GenerationCache interface {
+	Get(key GenerationKey) ([]byte, bool)
+	Put(key GenerationKey, data []byte)
+}
+
+// LRUCache is an in-memory GenerationCache holding at most size entries
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is most recently used
+	items map[GenerationKey]*list.Element
+}
+
+type lruEntry struct {
+	key  GenerationKey
+	data []byte
+}
+
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[GenerationKey]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key GenerationKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).data, true
+}
+
+func (c *LRUCache) Put(key GenerationKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruEntry).data = data
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, data: data})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached images
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
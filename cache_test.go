@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache_Evicts(t *testing.T) {
+	cache := NewLRUCache(2)
+	a := GenerationKey{Prompt: "a cat", ModelName: "SD", Seed: 1}
+	b := GenerationKey{Prompt: "a dog", ModelName: "SD", Seed: 1}
+	c := GenerationKey{Prompt: "a cat", ModelName: "SD", Seed: 2}
+
+	cache.Put(a, []byte("a"))
+	cache.Put(b, []byte("b"))
+	_, ok := cache.Get(a) // a is now the most recent
+	require.True(t, ok)
+
+	cache.Put(c, []byte("c")) // evicts b
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get(b)
+	assert.False(t, ok)
+
+	data, ok := cache.Get(a)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), data)
+	data, ok = cache.Get(c)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("c"), data)
+}
+
+func TestGenerate_CacheHitSkipsAPI(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "SD"
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	req := GenerateRequest{Prompt: "a cat", ModelID: 1, Seed: 42}
+	first, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mock.CallCount("/API/GetNewSession"))
+	assert.Equal(t, float64(42), mock.Generations()[0]["seed"])
+
+	second, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, first.Image, second.Image)
+	assert.Equal(t, "SD", second.ModelName)
+	assert.Equal(t, 1, mock.CallCount("/API/GetNewSession"), "cache hit should not reach the API")
+	assert.Equal(t, 1, mock.CallCount("/API/GenerateText2Image"))
+
+	// unseeded generations are never cached
+	_, err = client.Generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1})
+	require.NoError(t, err)
+	_, err = client.Generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, mock.CallCount("/API/GenerateText2Image"))
+}
+
+func TestGenerate_CacheDisabled(t *testing.T) {
+	config := MockConfig()
+	config.API.CacheSize = -1
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Nil(t, client.cache)
+}
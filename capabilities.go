@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Model capabilities, listed per model under capabilities
+const (
+	CapabilityTTI     = "tti"
+	CapabilityLLM     = "llm"
+	CapabilityRecon   = "recon"
+	CapabilityUpscale = "upscale"
+	CapabilityInpaint = "inpaint"
+)
+
+// capabilityMismatch is the failure reason for tasks needing worker
+// features this client doesn't have
+const capabilityMismatch = "capability_mismatch"
+
+// ErrModelDoesNotSupportTaskType means no usable model can run the task's type
+var ErrModelDoesNotSupportTaskType = errors.New("model does not support task type")
+
+// taskCapability returns the capability a task type needs
+func taskCapability(taskType Type) string {
+	switch taskType {
+	case LLM:
+		return CapabilityLLM
+	case Recon:
+		return CapabilityRecon
+	default:
+		return CapabilityTTI
+	}
+}
+
+// Supports reports whether the model can run taskType. Models without a
+// capabilities list support everything.
+func (m ModelConfig) Supports(taskType Type) bool {
+	return len(m.Capabilities) == 0 || slices.Contains(m.Capabilities, taskCapability(taskType))
+}
+
+// selectModel lets selector choose among the models that support taskType
+func (c *Client) selectModel(selector ModelSelector, taskType Type) (int, error) {
+	var ids []int
+	var stats []ModelStats
+	for i, s := range c.modelStatsByID() {
+		if c.config.Models[i].Supports(taskType) {
+			ids = append(ids, i+1)
+			stats = append(stats, s)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("%w: no model supports %s", ErrModelDoesNotSupportTaskType, taskType)
+	}
+
+	choice := selector.Select(taskType, stats)
+	if choice < 1 || choice > len(ids) {
+		choice = 1
+	}
+	return ids[choice-1], nil
+}
+
+// resolveModel fills in the task's model when it's left to us, and checks an
+// explicit one can run the task. With autoSelect an unsuitable explicit model
+// is swapped for one that can.
+func (c *Client) resolveModel(selector ModelSelector, task *Tasukete, autoSelect bool) error {
+	if task.Model == 0 {
+		id, err := c.selectModel(selector, task.Type)
+		if err != nil {
+			return err
+		}
+		task.Model = id
+		return nil
+	}
+
+	// out of range IDs are reported by Generate
+	if task.Model < 0 || task.Model > len(c.config.Models) {
+		return nil
+	}
+	model := c.config.Models[task.Model-1]
+	if model.Supports(task.Type) {
+		return nil
+	}
+	if !autoSelect {
+		return fmt.Errorf("%w: %q can't run %s tasks", ErrModelDoesNotSupportTaskType, model.Name, task.Type)
+	}
+
+	id, err := c.selectModel(selector, task.Type)
+	if err != nil {
+		return err
+	}
+	task.Model = id
+	return nil
+}
+
+// missingCapabilities returns the required worker capabilities this client
+// lacks
+func (w *WebSocketClient) missingCapabilities(required []string) []string {
+	var missing []string
+	for _, capability := range required {
+		if !slices.Contains(w.capabilities, capability) {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
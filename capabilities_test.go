@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func capabilityConfig() *Config {
+	config := MockConfig()
+	config.Models = []ModelConfig{
+		{Name: "Writer", String: "writer_model", Capabilities: []string{CapabilityLLM}},
+		{Name: "Painter", String: "painter_model", Capabilities: []string{CapabilityTTI, CapabilityUpscale}},
+		{Name: "Anything", String: "any_model"},
+	}
+	return config
+}
+
+func TestModelConfig_Supports(t *testing.T) {
+	config := capabilityConfig()
+	assert.True(t, config.Models[0].Supports(LLM))
+	assert.False(t, config.Models[0].Supports(TTI))
+	assert.True(t, config.Models[1].Supports(TTI))
+	assert.False(t, config.Models[1].Supports(Recon))
+	assert.True(t, config.Models[2].Supports(Recon), "no capabilities means everything")
+}
+
+func TestResolveModel(t *testing.T) {
+	config := capabilityConfig()
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		taskType   Type
+		model      int
+		autoSelect bool
+		want       int
+		wantErr    bool
+	}{
+		{"auto tti skips llm-only model", TTI, 0, false, 2, false},
+		{"auto llm", LLM, 0, false, 1, false},
+		{"explicit supported", TTI, 3, false, 3, false},
+		{"explicit unsupported", TTI, 1, false, 1, true},
+		{"explicit unsupported rerouted", TTI, 1, true, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := NewTasukete(tt.taskType, "prompt", tt.model)
+			err := client.resolveModel(FirstSelector{}, task, tt.autoSelect)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrModelDoesNotSupportTaskType))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, task.Model)
+		})
+	}
+}
+
+func TestResolveModel_NoCapableModel(t *testing.T) {
+	config := MockConfig()
+	config.Models[0].Capabilities = []string{CapabilityTTI}
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err := client.resolveModel(FirstSelector{}, NewTasukete(LLM, "hi", 0), true)
+	assert.True(t, errors.Is(err, ErrModelDoesNotSupportTaskType))
+}
+
+// TestHandleTask_CapabilityRouting verifies tasks only reach models that can run them
+func TestHandleTask_CapabilityRouting(t *testing.T) {
+	for _, autoSelect := range []bool{false, true} {
+		mock := NewMockSwarmUIServer()
+		server := mock.Start()
+		defer server.Close()
+
+		config := capabilityConfig()
+		config.AutoSelectModel = autoSelect
+		useMockAPI(config, server)
+
+		w := newTestWebSocketClient(t, config)
+		task := NewTasukete(TTI, "a cat", 1)
+		w.handleTask(context.Background(), nil, task)
+
+		if !autoSelect {
+			assert.Equal(t, StatusFailed, task.Status())
+			assert.Empty(t, mock.Generations(), "llm-only model must not be asked for an image")
+			messages := sentMessages(t, w)
+			require.NotEmpty(t, messages)
+			var sent Tasukete
+			require.NoError(t, json.Unmarshal(messages[len(messages)-1].Payload, &sent))
+			assert.Equal(t, StatusFailed, sent.Status())
+			continue
+		}
+
+		assert.Equal(t, StatusCompleted, task.Status())
+		assert.Equal(t, 2, task.Model)
+		require.Len(t, mock.Generations(), 1)
+		assert.Equal(t, "painter_model", mock.Generations()[0]["model"])
+	}
+}
+
+func TestHandleTask_RequiredCapabilities(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.Capabilities = []string{"sdxl", "fp16"}
+
+	tests := []struct {
+		name     string
+		required []string
+		missing  []any
+	}{
+		{name: "none required"},
+		{name: "all present", required: []string{"fp16", "sdxl"}},
+		{name: "missing", required: []string{"sdxl", "flux", "int8"}, missing: []any{"flux", "int8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWebSocketClient(t, config)
+			task := NewTasukete(TTI, "a cat", 1)
+			task.RequiredCapabilities = tt.required
+			generations := len(mock.Generations())
+
+			w.handleTask(context.Background(), nil, task)
+
+			if tt.missing == nil {
+				assert.Len(t, mock.Generations(), generations+1)
+				return
+			}
+			assert.Len(t, mock.Generations(), generations, "mismatched tasks must not be processed")
+			messages := sentMessages(t, w)
+			require.Len(t, messages, 1)
+			var update Tasukete
+			require.NoError(t, json.Unmarshal(messages[0].Payload, &update))
+			assert.Equal(t, StatusFailed, update.Status())
+			assert.Equal(t, capabilityMismatch, update.Metadata["reason"])
+			assert.Equal(t, tt.missing, update.Metadata["missing_capabilities"])
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runGenerate reads a task from taskFile, generates it synchronously and
+// writes the image to output. "-" stands for STDIN and STDOUT respectively.
+// In dry run mode the request body is written instead of an image.
+func runGenerate(client *Client, taskFile, output string) error {
+	var in io.Reader = os.Stdin
+	if taskFile != "-" {
+		file, err := os.Open(taskFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	task, err := ParseTaskFromReader(in)
+	if err != nil {
+		return err
+	}
+
+	selector, _ := newModelSelector(client.config.ModelSelectionStrategy)
+	if err := client.resolveModel(selector, task, client.config.AutoSelectModel); err != nil {
+		return err
+	}
+
+	req := GenerateRequest{
+		Prompt:         task.Prompt,
+		NegativePrompt: task.NegativePrompt,
+		ModelID:        task.Model,
+		LoraPreset:     task.LoraPreset,
+		Seed:           taskSeed(task),
+	}
+
+	var data []byte
+	if client.DryRun {
+		body, err := client.dryRunBody(req)
+		if err != nil {
+			return err
+		}
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err != nil {
+			data = append(body, '\n')
+		} else {
+			data = append(indented.Bytes(), '\n')
+		}
+	} else {
+		result, err := client.Generate(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		data = result.Image
+	}
+
+	return writeOutput(output, data)
+}
+
+// runUpscale upscales the image at input with the model and writes the
+// result to output. "-" stands for STDIN and STDOUT respectively.
+func runUpscale(client *Client, input string, modelID int, scale float64, output string) error {
+	var data []byte
+	var err error
+	if input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	upscaled, err := client.UpscaleImage(context.Background(), UpscaleRequest{Image: data, ModelID: modelID, Scale: scale})
+	if err != nil {
+		return err
+	}
+	return writeOutput(output, upscaled)
+}
+
+func writeOutput(output string, data []byte) error {
+	if output == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -2,20 +2,109 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
+	// DryRun makes generation log the request body it would send and
+	// return ErrDryRun instead of calling the API
+	DryRun bool
+
 	config     *Config
 	httpClient *http.Client
+	userAgent  string
 	logger     *slog.Logger
+	uploader   ImageUploader
+	dedup      *UploadDeduplicator
+	filter     PromptFilter
+	loras      *LoRALibrary
+	cache      GenerationCache
+	modelStats sync.Map // model name -> *modelCounters
+
+	budget   *BudgetEnforcer
+	sessions sessionAffinity
+	mock     *mockGeneration // set by WithMockGeneration
+
+	fixedSession string         // set by WithFixedSession
+	watcher      *ConfigWatcher // set by WithConfigWatcher
+
+	benchmarkMu sync.Mutex // one Benchmark at a time
+
+	promptLengths *sizeHistogram // bytes per generated prompt
+	imageSizes    *sizeHistogram // bytes per downloaded image
+	apiVersion    atomic.Value   // string, last seen X-SwarmUI-Version
+
+	rateLimitedCount atomic.Int64 // 429 responses from the API
+
+	modelList modelListCache // last FetchAvailableModels response
+}
+
+// ClientOption customizes a Client created by NewClient
+type ClientOption func(*Client)
+
+// WithLogger replaces the logger passed to NewClient
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithLoRALibrary enables named LoRA presets for models and tasks
+func WithLoRALibrary(library *LoRALibrary) ClientOption {
+	return func(c *Client) {
+		c.loras = library
+	}
+}
+
+// WithGenerationCache replaces the in-memory LRU cache of seeded generations
+func WithGenerationCache(cache GenerationCache) ClientOption {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithPromptFilter rejects prompts before they reach the generation API
+func WithPromptFilter(filter PromptFilter) ClientOption {
+	return func(c *Client) {
+		c.filter = filter
+	}
+}
+
+// WithFixedSession uses sessionID for every generation instead of asking
+// the API for new sessions, so tests can check requests without a session
+// endpoint
+func WithFixedSession(sessionID string) ClientOption {
+	return func(c *Client) {
+		c.fixedSession = sessionID
+	}
+}
+
+// WithConfigWatcher makes config changes the client makes at runtime go
+// through watcher, so they're saved to the config file
+func WithConfigWatcher(watcher *ConfigWatcher) ClientOption {
+	return func(c *Client) {
+		c.watcher = watcher
+	}
+}
+
+// updateConfig changes the config through the watcher when there is one
+func (c *Client) updateConfig(fn func(config *Config)) {
+	if c.watcher != nil {
+		c.watcher.Update(fn)
+		return
+	}
+	fn(c.config)
 }
 
 type SessionResponse struct {
@@ -27,192 +116,702 @@ type ImageResponse struct {
 	Images []string `json:"images"`
 }
 
-func NewClient(config *Config, logger *slog.Logger) *Client {
-	return &Client{
+func NewClient(config *Config, logger *slog.Logger, opts ...ClientOption) *Client {
+	c := &Client{
 		config: config,
 		httpClient: &http.Client{
-			Timeout: time.Duration(config.API.Timeout) * time.Second,
+			Transport: config.API.newTransport(),
+			Timeout:   time.Duration(config.API.Timeout) * time.Second,
 		},
-		logger: logger,
+		userAgent: defaultUserAgent(),
+
+		promptLengths: newSizeHistogram(),
+		imageSizes:    newSizeHistogram(),
+		logger:        logger,
+	}
+	if config.Upload.DedupWindow > 0 {
+		c.dedup = NewUploadDeduplicator(config.Upload.DedupWindow)
+	}
+	switch size := config.API.CacheSize; {
+	case size == 0:
+		c.cache = NewLRUCache(defaultCacheSize)
+	case size > 0:
+		c.cache = NewLRUCache(size)
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	withDebugTransport(c.httpClient, c.logger)
+	c.uploader = newImageUploader(config, c.logger)
+	if uploader, ok := c.uploader.(*HTTPUploader); ok {
+		uploader.userAgent = c.userAgent
+	}
+	return c
+}
+
+// GenerateRequest describes a single generation beyond the model defaults
+type GenerateRequest struct {
+	Prompt         string
+	NegativePrompt string
+	ModelID        int
+	LoraPreset     string // merged over the model's LoRAs, winning on conflicts
+	Count          int    // images to generate in one call, 1 when 0
+	Seed           int64  // 0 lets the API pick; non-zero results are cached
+
+	ControlNet      *ControlNetConfig // overrides the model's default ControlNet
+	ControlNetImage []byte            // guidance image, ControlNet is off without one
+	InitImage       []byte            // img2img source, resized to the model's dimensions
+
+	OutputMimeType string // "image/png", "image/jpeg" or "image/webp", overrides the model's imageformat option
+}
+
+// GenerateResult is the outcome of a successful generation
+type GenerateResult struct {
+	Image     []byte
+	ModelName string        // the model that produced the image, which may be a fallback
+	Jitter    time.Duration // random delay waited before the generation requests
 }
 
 // GenerateImage generates an image based on the provided prompt and model ID
 // Returns the image data as a byte slice
 func (c *Client) GenerateImage(prompt string, modelID int) ([]byte, error) {
+	result, err := c.Generate(context.Background(), GenerateRequest{Prompt: prompt, ModelID: modelID})
+	if err != nil {
+		return nil, err
+	}
+	return result.Image, nil
+}
+
+// Generate generates an image for the request, falling back to the model's
+// FallbackModels when the requested one isn't loaded
+func (c *Client) Generate(ctx context.Context, req GenerateRequest) (*GenerateResult, error) {
+	var jitter time.Duration
+	ctx = withJitterHook(ctx, func(d time.Duration) { jitter += d })
+
+	images, model, err := c.generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &GenerateResult{Image: images[0], ModelName: model.Name, Jitter: jitter}, nil
+}
+
+// GenerateImages generates count images for the prompt in a single API call
+// and returns them in the order the API listed them
+func (c *Client) GenerateImages(ctx context.Context, prompt string, modelID int, count int) ([][]byte, error) {
 	if modelID <= 0 || modelID > len(c.config.Models) {
 		return nil, fmt.Errorf("invalid modelID: %d", modelID)
 	}
+	if limit := c.config.Models[modelID-1].maxBatch(); count < 1 || count > limit {
+		return nil, fmt.Errorf("invalid image count %d: must be between 1 and %d", count, limit)
+	}
 
-	// Get session
-	sessionID, err := c.getNewSession()
+	images, _, err := c.generate(ctx, GenerateRequest{Prompt: prompt, ModelID: modelID, Count: count})
+	return images, err
+}
+
+// GenerateImageFromImage generates an image for the prompt starting from
+// initImage, which is resized to the model's dimensions first
+func (c *Client) GenerateImageFromImage(ctx context.Context, prompt string, modelID int, initImage []byte) ([]byte, error) {
+	result, err := c.Generate(ctx, GenerateRequest{Prompt: prompt, ModelID: modelID, InitImage: initImage})
+	if err != nil {
+		return nil, err
+	}
+	return result.Image, nil
+}
+
+func (c *Client) generate(ctx context.Context, req GenerateRequest) ([][]byte, ModelConfig, error) {
+	logger := loggerFromContext(ctx, c.logger)
+
+	if c.DryRun {
+		body, err := c.dryRunBody(req)
+		if err != nil {
+			return nil, ModelConfig{}, err
+		}
+		logger.Info("Dry run request body", "body", string(body))
+		return nil, ModelConfig{}, ErrDryRun
+	}
+
+	if req.ModelID <= 0 || req.ModelID > len(c.config.Models) {
+		return nil, ModelConfig{}, fmt.Errorf("invalid modelID: %d", req.ModelID)
+	}
+
+	if c.filter != nil {
+		if ok, reason := c.filter.Allow(req.Prompt); !ok {
+			return nil, ModelConfig{}, ErrPromptRejected{Reason: reason}
+		}
+	}
+
+	prompt, err := c.checkPromptLength(logger, req.Prompt)
+	if err != nil {
+		return nil, ModelConfig{}, err
+	}
+	req.Prompt = prompt
+
+	requested := c.config.Models[req.ModelID-1]
+	candidates, err := c.modelCandidates(requested)
+	if err != nil {
+		return nil, ModelConfig{}, err
+	}
+
+	// Seeded single images are deterministic, so a cached copy is as good as a new one
+	cacheable := c.cache != nil && req.Seed != 0 && req.Count <= 1 && len(req.ControlNetImage) == 0 && len(req.InitImage) == 0 && req.OutputMimeType == ""
+	key := GenerationKey{Prompt: req.Prompt, ModelName: requested.Name, Seed: req.Seed}
+	if cacheable {
+		if image, ok := c.cache.Get(key); ok {
+			logger.Debug("Generation cache hit", "model", requested.Name, "seed", req.Seed)
+			return [][]byte{image}, requested, nil
+		}
+	}
+
+	c.promptLengths.Observe(int64(len(req.Prompt)))
+
+	// Get session, unless each model keeps its own
+	var sessionID string
+	if !c.config.API.SessionAffinityMode {
+		sessionID, err = c.getNewSession(ctx)
+		if err != nil {
+			return nil, ModelConfig{}, fmt.Errorf("failed to get session: %w", err)
+		}
+	}
+
+	// Generate and download, retrying generations that come back blank.
+	// Steps are reserved before each attempt, so neither concurrent
+	// generations nor retries can overshoot the budget.
+	retry := perModelRetryConfig(requested, c.config.API.Retry)
+	steps := requested.Steps * max(req.Count, 1)
+	var images [][]byte
+	var model ModelConfig
+	var params GenerationParams
+	for attempt := 0; ; attempt++ {
+		if c.budget != nil {
+			if err := c.budget.Reserve(ctx, steps); err != nil {
+				return nil, requested, err
+			}
+		}
+		images, model, params, err = c.generateAndDownload(ctx, req, candidates, sessionID)
+		c.settleBudget(ctx, steps, images, model.Steps*max(req.Count, 1))
+		if err == nil {
+			err = c.checkImageQuality(logger, images)
+		}
+		if !errors.Is(err, ErrLowQualityImage) || attempt >= retry.MaxRetries {
+			break
+		}
+
+		logger.Warn("Blank generation, retrying", "model", model.Name, "attempt", attempt+1, "error", err)
+		notifyRetry(ctx, err)
+		select {
+		case <-ctx.Done():
+			return nil, model, err
+		case <-time.After(retry.backoff()):
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %v", err)
+		return nil, model, err
 	}
 
-	// Generate image
-	imageURL, err := c.generateImage(sessionID, prompt, modelID)
+	for i := range images {
+		if c.config.API.Watermark.Text != "" {
+			watermarked, err := ApplyWatermark(images[i], c.config.API.Watermark)
+			switch {
+			case errors.Is(err, ErrWatermarkUnsupported):
+				logger.Warn("Skipping watermark", "model", model.Name, "error", err)
+			case err != nil:
+				return nil, model, fmt.Errorf("failed to apply watermark: %v", err)
+			default:
+				images[i] = watermarked
+			}
+		}
+
+		if c.config.API.EmbedMetadata && (params.OutputFormat == "" || params.OutputFormat == "PNG") { // only PNGs carry text chunks
+			images[i], err = EmbedPNGMetadata(images[i], params)
+			if err != nil {
+				return nil, model, fmt.Errorf("failed to embed metadata: %v", err)
+			}
+		}
+	}
+
+	// only cache what the requested model produced, not a fallback's output
+	if cacheable && model.Name == requested.Name {
+		c.cache.Put(key, images[0])
+	}
+
+	return images, model, nil
+}
+
+// settleBudget squares an attempt's reserved steps with what it used: all
+// of them are refunded when no image came back, and a fallback model's
+// steps replace the requested model's. Blank images still cost their steps.
+func (c *Client) settleBudget(ctx context.Context, reserved int, images [][]byte, used int) {
+	switch {
+	case c.budget == nil:
+	case images == nil:
+		c.budget.Refund(ctx, reserved)
+	case used > reserved:
+		c.budget.Charge(ctx, used-reserved)
+	case used < reserved:
+		c.budget.Refund(ctx, reserved-used)
+	}
+}
+
+// generateAndDownload generates images, moving down the fallback chain while
+// models are missing, and downloads them
+func (c *Client) generateAndDownload(ctx context.Context, req GenerateRequest, candidates []ModelConfig, sessionID string) ([][]byte, ModelConfig, GenerationParams, error) {
+	logger := loggerFromContext(ctx, c.logger)
+
+	var model ModelConfig
+	var params GenerationParams
+	var imageURLs []string
+	var err error
+	for _, model = range candidates {
+		params, err = c.generationParams(req, model)
+		if err != nil {
+			return nil, model, params, err
+		}
+		if c.config.API.SessionAffinityMode {
+			imageURLs, err = c.generateInModelSession(ctx, model, params)
+		} else {
+			imageURLs, err = c.generateWithRetry(ctx, sessionID, model, params)
+		}
+		if !errors.Is(err, ErrModelNotLoaded) {
+			break
+		}
+		logger.Warn("Model not loaded, trying fallback", "model", model.Name)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate image: %v", err)
+		return nil, model, params, fmt.Errorf("failed to generate image: %w", err)
 	}
 
-	// Download image
-	imageData, err := c.downloadImageBytes(imageURL)
+	images, err := c.downloadImages(ctx, imageURLs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %v", err)
+		return nil, model, params, fmt.Errorf("failed to download image: %w", err)
 	}
+	if err := checkOutputFormat(req.OutputMimeType, images); err != nil {
+		return nil, model, params, err
+	}
+	return images, model, params, nil
+}
 
-	return imageData, nil
+// modelCandidates returns the model followed by its fallbacks in order
+func (c *Client) modelCandidates(model ModelConfig) ([]ModelConfig, error) {
+	candidates := []ModelConfig{model}
+	for _, name := range model.FallbackModels {
+		fallback, ok := c.modelByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown fallback model %q for %q", name, model.Name)
+		}
+		candidates = append(candidates, fallback)
+	}
+	return candidates, nil
 }
 
-// UploadGeneratedImage uploads a previously generated image
+func (c *Client) modelByName(name string) (ModelConfig, bool) {
+	for _, m := range c.config.Models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+// UploadGeneratedImage uploads a previously generated image using the configured backend.
+// Images identical to a recent upload are skipped with ErrDuplicateUpload.
 func (c *Client) UploadGeneratedImage(imageData []byte) error {
-	return c.uploadImageBytes(imageData)
+	var hash string
+	if c.dedup != nil {
+		hash = hashImage(imageData)
+		if c.dedup.SeenOrAdd(hash) {
+			return ErrDuplicateUpload{Hash: hash}
+		}
+	}
+
+	// Create a unique filename, batch archives keep their extension
+	name := time.Now().UTC().Format("20060102T150405Z") + "image.png"
+	if http.DetectContentType(imageData) == "application/zip" {
+		name = time.Now().UTC().Format("20060102T150405Z") + "images.zip"
+	}
+
+	location, err := c.uploader.Upload(context.Background(), imageData, name)
+	if err != nil {
+		if c.dedup != nil {
+			c.dedup.Forget(hash)
+		}
+		return err
+	}
+	c.logger.Debug("Image uploaded", "location", location)
+	return nil
 }
 
-func (c *Client) getNewSession() (string, error) {
+func (c *Client) getNewSession(ctx context.Context) (string, error) {
+	if c.mock != nil {
+		return mockSessionID, nil
+	}
+	if c.fixedSession != "" {
+		return c.fixedSession, nil
+	}
+
 	url := fmt.Sprintf("http://%s:%s/API/GetNewSession", c.config.API.Host, c.config.API.Port)
 
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader([]byte("{}")))
+	resp, err := c.postJSON(ctx, url, []byte("{}"))
 	if err != nil {
-		return "", fmt.Errorf("session request failed: %w", err)
+		return "", SessionError{Err: err}
 	}
 	defer resp.Body.Close()
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", SessionError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to read session response: %w", err)}
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("session request returned non-OK status: %d", resp.StatusCode)
+		return "", SessionError{
+			StatusCode: resp.StatusCode,
+			Body:       truncate(string(respBody), maxErrorBody),
+			Err:        newAPIError(resp.StatusCode, respBody),
+		}
 	}
 
 	var sessionResp SessionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&sessionResp); err != nil {
-		return "", fmt.Errorf("failed to decode session response: %w", err)
+	if err := json.Unmarshal(respBody, &sessionResp); err != nil {
+		return "", SessionError{
+			StatusCode: resp.StatusCode,
+			Body:       truncate(string(respBody), maxErrorBody),
+			Err:        fmt.Errorf("failed to decode session response: %w", err),
+		}
 	}
 
 	if sessionResp.SessionID == "" {
-		return "", fmt.Errorf("received empty session ID")
+		return "", SessionError{
+			StatusCode: resp.StatusCode,
+			Body:       truncate(string(respBody), maxErrorBody),
+			Err:        errors.New("received empty session ID"),
+		}
 	}
 
+	c.apiVersion.Store(resp.Header.Get(swarmUIVersionHeader))
+
 	return sessionResp.SessionID, nil
 }
 
-func (c *Client) generateImage(sessionID, prompt string, modelID int) (string, error) {
-	model := c.config.Models[modelID-1]
-	generateBody := map[string]interface{}{
-		"session_id": sessionID,
-		"images":     1,
-		"prompt":     prompt,
-		"model":      model.String,
-		"width":      model.Width,
-		"height":     model.Height,
-		"steps":      model.Steps,
-		"cfgscale":   model.Cfgscale,
-	}
+// generateImage requests params.Images images and returns their download URLs
+func (c *Client) generateImage(ctx context.Context, sessionID string, model ModelConfig, params GenerationParams) (imageURLs []string, err error) {
+	start := time.Now()
+	defer func() { c.recordModelStats(model.Name, time.Since(start), err) }()
 
-	if model.Loras != "" {
-		generateBody["loras"] = model.Loras
-		if generateBody["loras"] == "GyateGyate_pdxl_Incrs_v1" {
-			generateBody["prompt"] = prompt + ", open mouth, smile, chibi, :d, :3"
-		}
+	if c.mock != nil {
+		return c.mock.imageURLs(params)
 	}
 
-	if model.LoraWeights != 0.0 {
-		generateBody["loraweights"] = model.LoraWeights
-	}
-
-	for name, val := range model.Options {
-		generateBody[name] = val
-	}
+	generateBody := params.requestBody(sessionID)
 
 	bodyJSON, err := json.Marshal(generateBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	genErr := GenerationError{SessionID: sessionID, ModelName: model.Name}
+
+	if err := c.sleepJitter(ctx); err != nil {
+		genErr.Err = err
+		return nil, genErr
 	}
 
 	url := fmt.Sprintf("http://%s:%s/API/GenerateText2Image", c.config.API.Host, c.config.API.Port)
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(bodyJSON))
+	resp, err := c.postJSON(ctx, url, bodyJSON)
 	if err != nil {
-		return "", fmt.Errorf("image generation request failed: %w", err)
+		genErr.Err = err
+		return nil, genErr
 	}
 	defer resp.Body.Close()
 
+	genErr.StatusCode = resp.StatusCode
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		genErr.Err = fmt.Errorf("failed to read image response: %w", err)
+		return nil, genErr
+	}
+	genErr.Body = truncate(string(respBody), maxErrorBody)
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("image generation returned non-OK status: %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			genErr.RetryAfter = c.rateLimited(ctx, resp.Header.Get("Retry-After"))
+		}
+		apiErr := newAPIError(resp.StatusCode, respBody)
+		switch {
+		case isModelNotLoaded(respBody):
+			genErr.Err = fmt.Errorf("%w: %s: %w", ErrModelNotLoaded, model.String, apiErr)
+		case isSessionExpired(resp.StatusCode, respBody):
+			genErr.Err = fmt.Errorf("%w: %w", ErrSessionExpired, apiErr)
+		default:
+			genErr.Err = apiErr
+		}
+		return nil, genErr
 	}
 
 	var imageResp ImageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&imageResp); err != nil {
-		return "", fmt.Errorf("failed to decode image response: %w", err)
+	if err := json.Unmarshal(respBody, &imageResp); err != nil {
+		genErr.Err = fmt.Errorf("failed to decode image response: %w", err)
+		return nil, genErr
 	}
 
 	if len(imageResp.Images) == 0 {
-		return "", fmt.Errorf("no images returned from response")
+		// SwarmUI reports most failures as a 200 with an error field
+		if isModelNotLoaded(respBody) {
+			genErr.Err = fmt.Errorf("%w: %s", ErrModelNotLoaded, model.String)
+		} else if isSessionExpired(resp.StatusCode, respBody) {
+			genErr.Err = ErrSessionExpired
+		} else {
+			genErr.Err = errors.New("no images returned from response")
+		}
+		return nil, genErr
 	}
 
-	return fmt.Sprintf("http://%s:%s/%s", c.config.API.Host, c.config.API.Port, imageResp.Images[0]), nil
+	for _, image := range imageResp.Images {
+		imageURLs = append(imageURLs, fmt.Sprintf("http://%s:%s/%s", c.config.API.Host, c.config.API.Port, image))
+	}
+	return imageURLs, nil
 }
 
-// downloadImageBytes downloads an image and returns it as a byte slice
-func (c *Client) downloadImageBytes(imageURL string) ([]byte, error) {
-	resp, err := c.httpClient.Get(imageURL)
+// ErrModelNotLoaded means the API doesn't have the requested model available
+var ErrModelNotLoaded = errors.New("model not loaded")
+
+// modelNotLoadedMarkers are the API error texts that mean the model is unavailable
+var modelNotLoadedMarkers = []string{"model not found", "model not loaded", "invalid model"}
+
+func isModelNotLoaded(body []byte) bool {
+	lower := strings.ToLower(string(body))
+	for _, marker := range modelNotLoadedMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerationParams holds everything sent to the API for a single generation
+type GenerationParams struct {
+	Prompt         string         `json:"prompt"`
+	NegativePrompt string         `json:"negativeprompt,omitempty"`
+	Model          string         `json:"model"`
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	Steps          int            `json:"steps"`
+	Cfgscale       float32        `json:"cfgscale"`
+	Loras          string         `json:"loras,omitempty"`
+	LoraWeights    float32        `json:"loraweights,omitempty"`
+	LoraStack      []LoraEntry    `json:"lora_stack,omitempty"` // replaces Loras/LoraWeights when presets are used
+	Options        map[string]any `json:"options,omitempty"`
+	Images         int            `json:"-"` // images per request, 1 when 0
+	Seed           int64          `json:"seed,omitempty"`
+
+	ControlNet      *ControlNetConfig `json:"controlnet,omitempty"`
+	ControlNetImage []byte            `json:"-"`
+
+	InitImage           []byte  `json:"-"` // img2img or upscale source, see addInitImage
+	InitImageCreativity float64 `json:"-"`
+
+	OutputFormat string `json:"imageformat,omitempty"` // wins over an imageformat model option
+}
+
+// generationParams resolves the request against the model config
+func (c *Client) generationParams(req GenerateRequest, model ModelConfig) (GenerationParams, error) {
+	params := newGenerationParams(req.Prompt, model)
+	params.NegativePrompt = req.NegativePrompt
+	params.Images = req.Count
+	params.Seed = req.Seed
+
+	controlNet, image, err := controlNetParams(req, model)
 	if err != nil {
-		return nil, fmt.Errorf("download request failed: %w", err)
+		return params, err
 	}
-	defer resp.Body.Close()
+	params.ControlNet = controlNet
+	params.ControlNetImage = image
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download returned non-OK status: %d", resp.StatusCode)
+	if len(req.InitImage) > 0 {
+		params.InitImage, err = ResizeToModelDimensions(req.InitImage, model)
+		if err != nil {
+			return params, fmt.Errorf("failed to resize init image: %w", err)
+		}
+		params.InitImageCreativity = defaultInitImageCreativity
 	}
 
-	return io.ReadAll(resp.Body)
+	if req.OutputMimeType != "" {
+		params.OutputFormat, err = apiImageFormat(req.OutputMimeType)
+		if err != nil {
+			return params, err
+		}
+	}
+
+	if model.LoraPreset != "" || req.LoraPreset != "" || len(model.LoraStack) > 0 {
+		stack, err := c.loraStack(model, req.LoraPreset)
+		if err != nil {
+			return params, err
+		}
+		params.LoraStack = stack
+	}
+	return params, nil
 }
 
-// uploadImageBytes uploads image data directly without saving to disk first
-func (c *Client) uploadImageBytes(imageData []byte) error {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+func newGenerationParams(prompt string, model ModelConfig) GenerationParams {
+	if model.Loras == "GyateGyate_pdxl_Incrs_v1" ||
+		len(model.LoraStack) == 1 && model.LoraStack[0].Name == "GyateGyate_pdxl_Incrs_v1" {
+		prompt = prompt + ", open mouth, smile, chibi, :d, :3"
+	}
+	return GenerationParams{
+		Prompt:      prompt,
+		Model:       model.String,
+		Width:       model.Width,
+		Height:      model.Height,
+		Steps:       model.Steps,
+		Cfgscale:    model.Cfgscale,
+		Loras:       model.Loras,
+		LoraWeights: model.LoraWeights,
+		Options:     model.Options,
+	}
+}
+
+// requestBody builds the GenerateText2Image request body
+func (p GenerationParams) requestBody(sessionID string) map[string]interface{} {
+	body := map[string]interface{}{
+		"session_id": sessionID,
+		"images":     max(p.Images, 1),
+		"prompt":     p.Prompt,
+		"model":      p.Model,
+		"width":      p.Width,
+		"height":     p.Height,
+		"steps":      p.Steps,
+		"cfgscale":   p.Cfgscale,
+	}
 
-	// Create a unique filename for the form field
-	filename := time.Now().UTC().Format("20060102T150405Z") + "image.png"
+	if p.NegativePrompt != "" {
+		body["negativeprompt"] = p.NegativePrompt
+	}
 
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+	if p.Seed != 0 {
+		body["seed"] = p.Seed
+	}
+
+	if len(p.LoraStack) > 0 {
+		names := make([]string, len(p.LoraStack))
+		weights := make([]string, len(p.LoraStack))
+		for i, l := range p.LoraStack {
+			names[i] = l.Name
+			weights[i] = strconv.FormatFloat(float64(l.Weight), 'f', -1, 32)
+		}
+		body["loras"] = strings.Join(names, ",")
+		body["loraweights"] = strings.Join(weights, ",")
+	} else {
+		if p.Loras != "" {
+			body["loras"] = p.Loras
+		}
+
+		if p.LoraWeights != 0.0 {
+			body["loraweights"] = p.LoraWeights
+		}
 	}
 
-	if _, err = io.Copy(part, bytes.NewReader(imageData)); err != nil {
-		return fmt.Errorf("failed to copy image data: %w", err)
+	p.addControlNet(body)
+	p.addInitImage(body)
+
+	for name, val := range p.Options {
+		body[name] = val
 	}
 
-	if err = writer.Close(); err != nil {
-		return fmt.Errorf("failed to close multipart writer: %w", err)
+	if p.OutputFormat != "" {
+		body["imageformat"] = p.OutputFormat
 	}
 
-	url := fmt.Sprintf("https://%s:%s/image", c.config.Server.Host, c.config.Server.Port)
-	req, err := http.NewRequest("POST", url, body)
+	return body
+}
+
+func (c *Client) postJSON(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create upload request: %w", err)
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	return c.httpClient.Do(req)
+}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+// downloadImages downloads all images in parallel, keeping their order
+func (c *Client) downloadImages(ctx context.Context, imageURLs []string) ([][]byte, error) {
+	images := make([][]byte, len(imageURLs))
+	errs := make([]error, len(imageURLs))
+	var wg sync.WaitGroup
+	for i, url := range imageURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			images[i], errs[i] = c.downloadImageBytes(ctx, url)
+		}()
+	}
+	wg.Wait()
 
-	// TODO: For production, use proper certificate validation instead of InsecureSkipVerify
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// downloadImageBytes downloads an image and returns it as a byte slice
+func (c *Client) downloadImageBytes(ctx context.Context, imageURL string) ([]byte, error) {
+	if c.mock != nil {
+		data := c.mock.download()
+		c.imageSizes.Observe(int64(len(data)))
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
 	}
-	client := &http.Client{Transport: transport}
+	req.Header.Set("User-Agent", c.userAgent)
 
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("upload request failed: %w", err)
+		return nil, DownloadError{URL: imageURL, Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload failed with status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, DownloadError{URL: imageURL, StatusCode: resp.StatusCode, Err: parseAPIError(resp)}
 	}
 
-	return nil
+	data, err := readBody(resp, c.config.API.downloadBufferSize())
+	if err != nil {
+		return nil, DownloadError{URL: imageURL, Err: err}
+	}
+	c.imageSizes.Observe(int64(len(data)))
+	return data, nil
+}
+
+// defaultDownloadBufferSize covers most generated images in one allocation
+const defaultDownloadBufferSize = 1 << 20
+
+func (c APIConfig) downloadBufferSize() int {
+	if c.DownloadBufferSize == 0 {
+		return defaultDownloadBufferSize
+	}
+	return c.DownloadBufferSize
+}
+
+// readBody reads the response body into a buffer sized up front from the
+// Content-Length, so large images aren't copied through a series of ever
+// larger slices. The header comes from the server, so the buffer is never
+// larger than size; bodies past it grow the buffer as io.ReadAll would.
+// Without a Content-Length, or with a non-positive size, it is io.ReadAll.
+func readBody(resp *http.Response, size int) ([]byte, error) {
+	if size <= 0 || resp.ContentLength <= 0 {
+		return io.ReadAll(resp.Body)
+	}
+	// room for the final read that sees EOF, so a buffer of the right size never grows
+	capacity := min(resp.ContentLength, int64(size)) + bytes.MinRead
+	buf := bytes.NewBuffer(make([]byte, 0, capacity))
+	if _, err := buf.ReadFrom(io.LimitReader(resp.Body, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -2,14 +2,18 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // MockConfig creates a test configuration
@@ -41,94 +45,80 @@ func MockConfig() *Config {
 
 // TestGetNewSession tests the session retrieval functionality
 func TestGetNewSession(t *testing.T) {
-	// Setup test server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/API/GetNewSession" {
-			t.Errorf("Expected path /API/GetNewSession, got %s", r.URL.Path)
-			http.Error(w, "Not found", http.StatusNotFound)
-			return
-		}
-
-		if r.Method != "POST" {
-			t.Errorf("Expected POST method, got %s", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// Return a mock session response
-		resp := SessionResponse{
-			SessionID: "test-session-123",
-			UserID:    "test-user-456",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
-	}))
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
 	defer server.Close()
 
 	// Create a test client
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	config := MockConfig()
-	config.API.Host = server.URL[7:] // Remove "http://" prefix
-	config.API.Port = ""
+	useMockAPI(config, server)
 
 	client := NewClient(config, logger)
 
 	// Test the method
-	sessionID, err := client.getNewSession()
+	sessionID, err := client.getNewSession(context.Background())
 	if err != nil {
 		t.Fatalf("getNewSession failed: %v", err)
 	}
 
-	if sessionID != "test-session-123" {
-		t.Errorf("Expected sessionID to be 'test-session-123', got '%s'", sessionID)
+	if sessionID != "mock-session-1" {
+		t.Errorf("Expected sessionID to be 'mock-session-1', got '%s'", sessionID)
+	}
+	if n := mock.CallCount("/API/GetNewSession"); n != 1 {
+		t.Errorf("Expected 1 session request, got %d", n)
 	}
 }
 
-// TestGenerateImage tests the complete image generation flow
-func TestGenerateImage(t *testing.T) {
-	// Setup test server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/API/GetNewSession":
-			// Return a mock session
-			json.NewEncoder(w).Encode(SessionResponse{SessionID: "test-session-123"})
-
-		case "/API/GenerateText2Image":
-			// Verify request body
-			body, err := io.ReadAll(r.Body)
-			if err != nil {
-				t.Fatalf("Failed to read request body: %v", err)
-			}
-
-			var reqBody map[string]interface{}
-			if err := json.Unmarshal(body, &reqBody); err != nil {
-				t.Fatalf("Failed to parse request body: %v", err)
-			}
+// TestWithFixedSession tests that a fixed session replaces the session endpoint
+func TestWithFixedSession(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
-			if reqBody["prompt"] != "test prompt" {
-				t.Errorf("Expected prompt 'test prompt', got '%v'", reqBody["prompt"])
-			}
+	// No API to ask
+	config := MockConfig()
+	config.API.Host, config.API.Port = "127.0.0.1", "1"
+	sessionID, err := NewClient(config, logger, WithFixedSession("test-session")).getNewSession(context.Background())
+	if err != nil {
+		t.Fatalf("getNewSession failed: %v", err)
+	}
+	if sessionID != "test-session" {
+		t.Errorf("Expected sessionID to be 'test-session', got '%s'", sessionID)
+	}
 
-			// Return mock image response
-			json.NewEncoder(w).Encode(ImageResponse{Images: []string{"images/test.png"}})
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	useMockAPI(config, server)
+	client := NewClient(config, logger, WithFixedSession("test-session"))
 
-		case "/images/test.png":
-			// Return a mock image (simple 1x1 black PNG)
-			w.Header().Set("Content-Type", "image/png")
-			w.Write([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A})
+	if _, err := client.GenerateImage("test prompt", 1); err != nil {
+		t.Fatalf("GenerateImage failed: %v", err)
+	}
+	generations := mock.Generations()
+	if len(generations) != 1 {
+		t.Fatalf("Expected 1 generation request, got %d", len(generations))
+	}
+	if got := generations[0]["session_id"]; got != "test-session" {
+		t.Errorf("Expected session_id to be 'test-session', got '%v'", got)
+	}
+	if n := mock.CallCount("/API/GetNewSession"); n != 0 {
+		t.Errorf("Expected no session requests, got %d", n)
+	}
+}
 
-		default:
-			t.Errorf("Unexpected request to path: %s", r.URL.Path)
-			http.Error(w, "Not found", http.StatusNotFound)
-		}
-	}))
+// TestGenerateImage tests the complete image generation flow
+func TestGenerateImage(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
 	defer server.Close()
 
+	image := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+	mock.SetNextImageResponse(image)
+
 	// Create a test client
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	config := MockConfig()
-	config.API.Host = server.URL[7:] // Remove "http://" prefix
-	config.API.Port = ""
+	useMockAPI(config, server)
 
 	client := NewClient(config, logger)
 
@@ -138,73 +128,51 @@ func TestGenerateImage(t *testing.T) {
 		t.Fatalf("GenerateImage failed: %v", err)
 	}
 
-	// Verify we got some image data back
-	if len(imageData) == 0 {
-		t.Errorf("Expected non-empty image data")
+	if !bytes.Equal(imageData, image) {
+		t.Errorf("Expected queued image data, got %v", imageData)
+	}
+
+	generations := mock.Generations()
+	if len(generations) != 1 {
+		t.Fatalf("Expected 1 generation request, got %d", len(generations))
+	}
+	if generations[0]["prompt"] != "test prompt" {
+		t.Errorf("Expected prompt 'test prompt', got '%v'", generations[0]["prompt"])
+	}
+	if n := mock.CallCount("/images/"); n != 1 {
+		t.Errorf("Expected 1 image download, got %d", n)
 	}
 }
 
 // TestUploadGeneratedImage tests the image upload functionality
 func TestUploadGeneratedImage(t *testing.T) {
-	// Setup test server for the HTTPS upload endpoint
-	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/image" {
-			t.Errorf("Expected path /image, got %s", r.URL.Path)
-			http.Error(w, "Not found", http.StatusNotFound)
-			return
-		}
-
-		if r.Method != "POST" {
-			t.Errorf("Expected POST method, got %s", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// Verify multipart form
-		if err := r.ParseMultipartForm(10 << 20); err != nil {
-			t.Fatalf("Failed to parse multipart form: %v", err)
-		}
-
-		file, _, err := r.FormFile("file")
-		if err != nil {
-			t.Fatalf("Failed to get form file: %v", err)
-		}
-		defer file.Close()
-
-		fileData, err := io.ReadAll(file)
-		if err != nil {
-			t.Fatalf("Failed to read file data: %v", err)
-		}
-
-		if !bytes.Equal(fileData, []byte("test image data")) {
-			t.Errorf("Expected file content 'test image data', got '%s'", string(fileData))
-		}
-
-		w.WriteHeader(http.StatusOK)
-	}))
+	mock := NewMockSwarmUIServer()
+	server := mock.StartTLS()
 	defer server.Close()
 
 	// Create a test client
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	config := MockConfig()
-	config.Server.Host = server.URL[8:] // Remove "https://" prefix
-	config.Server.Port = ""
+	useMockUploads(config, server)
 
 	client := NewClient(config, logger)
 
-	// Create a custom HTTP client that uses the test server's TLS certificate
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-	client.httpClient.Transport = transport
-
 	// Test the method
 	err := client.UploadGeneratedImage([]byte("test image data"))
 	if err != nil {
 		t.Fatalf("UploadGeneratedImage failed: %v", err)
 	}
+
+	uploads := mock.Uploads()
+	if len(uploads) != 1 {
+		t.Fatalf("Expected 1 upload, got %d", len(uploads))
+	}
+	if uploads[0].Path != "/image" {
+		t.Errorf("Expected path /image, got %s", uploads[0].Path)
+	}
+	if !bytes.Equal(uploads[0].Data, []byte("test image data")) {
+		t.Errorf("Expected file content 'test image data', got '%s'", string(uploads[0].Data))
+	}
 }
 
 // TestGenerateImageInvalidModel tests error handling with invalid model ID
@@ -253,3 +221,336 @@ func TestGenerateImageInvalidModel(t *testing.T) {
 // 	LoraWeights float64
 // 	Options     map[string]interface{}
 // }
+
+// TestModelStats verifies that generation outcomes are counted per model
+func TestModelStats(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	// session, generate and download, then fail the second generation
+	mock.SetFailOnNthRequest(5)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "SD"
+
+	client := NewClient(config, logger)
+
+	if _, err := client.GenerateImage("test prompt", 1); err != nil {
+		t.Fatalf("first generation failed: %v", err)
+	}
+	if _, err := client.GenerateImage("test prompt", 1); err == nil {
+		t.Fatalf("expected second generation to fail")
+	}
+
+	stats := client.GetModelStats()
+	if len(stats) != 1 {
+		t.Fatalf("Expected stats for 1 model, got %d", len(stats))
+	}
+	if stats[0].Name != "SD" || stats[0].TotalRequests != 2 || stats[0].Failures != 1 {
+		t.Errorf("Unexpected stats: %+v", stats[0])
+	}
+}
+
+func TestSizeHistograms(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, logger)
+
+	// 100 and 1000 bytes land in the 128 and 1024 byte buckets
+	for _, size := range []int{100, 1000} {
+		mock.SetNextImageResponse(bytes.Repeat([]byte{0xff}, size))
+		if _, err := client.GenerateImage(strings.Repeat("p", size/10), 1); err != nil {
+			t.Fatalf("GenerateImage failed: %v", err)
+		}
+	}
+
+	images := client.ImageSizes()
+	if images.UpperBounds[0] != 64 || images.UpperBounds[11] != 131072 {
+		t.Errorf("Unexpected bucket bounds: %v", images.UpperBounds)
+	}
+	wantImages := []int64{0, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2}
+	if !slices.Equal(images.Counts, wantImages) || images.Count != 2 || images.Sum != 1100 {
+		t.Errorf("Unexpected image sizes: %+v", images)
+	}
+
+	// 10 and 100 byte prompts
+	prompts := client.PromptLengths()
+	wantPrompts := []int64{1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
+	if !slices.Equal(prompts.Counts, wantPrompts) || prompts.Count != 2 || prompts.Sum != 110 {
+		t.Errorf("Unexpected prompt lengths: %+v", prompts)
+	}
+}
+
+// TestGenerateFallbackModel verifies generation moves to the fallback when the primary model is missing
+func TestGenerateFallbackModel(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	mock.SetUnavailableModels("primary_model")
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models = []ModelConfig{
+		{Name: "Primary", String: "primary_model", FallbackModels: []string{"Backup"}},
+		{Name: "Backup", String: "backup_model"},
+	}
+
+	w := newTestWebSocketClient(t, config)
+	task := NewTasukete(TTI, "test prompt", 1)
+	w.handleTTITask(context.Background(), nil, task)
+
+	if task.Status() != StatusCompleted {
+		t.Fatalf("Expected task to complete, got %s", task.Status())
+	}
+	if got, _ := task.GetMetadata("resolved_model"); got != "Backup" {
+		t.Errorf("Expected resolved_model 'Backup', got '%v'", got)
+	}
+	var requested []any
+	for _, g := range mock.Generations() {
+		requested = append(requested, g["model"])
+	}
+	if len(requested) != 2 || requested[0] != "primary_model" || requested[1] != "backup_model" {
+		t.Errorf("Unexpected model requests: %v", requested)
+	}
+}
+
+func newBenchmarkClient(b *testing.B) (*Client, *MockSwarmUIServer) {
+	b.Helper()
+	mock := NewMockSwarmUIServer()
+	api := mock.Start()
+	b.Cleanup(api.Close)
+	uploads := mock.StartTLS()
+	b.Cleanup(uploads.Close)
+
+	config := MockConfig()
+	useMockAPI(config, api)
+	useMockUploads(config, uploads)
+	return NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil))), mock
+}
+
+// The benchmarks run against MockSwarmUIServer with no artificial latency and
+// its fixed 1x1 PNG, so they measure client overhead plus loopback HTTP.
+// Baseline (go test -bench . -benchtime 2000x -cpu 4, 1 vCPU Xeon, linux/amd64):
+//
+//	BenchmarkGenerateImage-4          181408 ns/op   27712 B/op   347 allocs/op
+//	BenchmarkUploadGeneratedImage-4    71240 ns/op   17113 B/op   146 allocs/op
+//	BenchmarkConcurrentGeneration-4   181126 ns/op   28157 B/op   345 allocs/op
+
+func BenchmarkGenerateImage(b *testing.B) {
+	client, _ := newBenchmarkClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GenerateImage("benchmark prompt", 1); err != nil {
+			b.Fatalf("GenerateImage failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkUploadGeneratedImage(b *testing.B) {
+	client, _ := newBenchmarkClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.UploadGeneratedImage(mockImage); err != nil {
+			b.Fatalf("UploadGeneratedImage failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkConcurrentGeneration shares one client across goroutines to
+// surface contention in the shared session and stats state
+func BenchmarkConcurrentGeneration(b *testing.B) {
+	client, _ := newBenchmarkClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.GenerateImage("benchmark prompt", 1); err != nil {
+				b.Errorf("GenerateImage failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// TestConcurrentGenerateImage hammers one client from many goroutines; run
+// with -race to catch unsynchronized shared state
+func TestConcurrentGenerateImage(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockSwarmUIServer()
+	mock.SetSessionLatency(time.Millisecond)
+	mock.SetGenerationLatency(time.Millisecond)
+	mock.SetLatencyJitter(9 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "SD"
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GenerateImage("test prompt", 1); err != nil {
+				errs <- err
+			}
+			client.GetModelStats()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("GenerateImage failed: %v", err)
+	}
+	if n := mock.CallCount("/API/GenerateText2Image"); n != workers {
+		t.Errorf("Expected %d generation requests, got %d", workers, n)
+	}
+	stats := client.GetModelStats()
+	if len(stats) != 1 || stats[0].TotalRequests != workers || stats[0].Failures != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestGenerateImages verifies every returned image is downloaded
+func TestGenerateImages(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	images, err := client.GenerateImages(context.Background(), "test prompt", 1, 3)
+	if err != nil {
+		t.Fatalf("GenerateImages failed: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Expected 3 images, got %d", len(images))
+	}
+	for i, image := range images {
+		if !bytes.Equal(image, mockImage) {
+			t.Errorf("Image %d doesn't match the served image", i)
+		}
+	}
+	if n := mock.CallCount("/images/"); n != 3 {
+		t.Errorf("Expected 3 image downloads, got %d", n)
+	}
+	if got := mock.Generations()[0]["images"]; got != float64(3) {
+		t.Errorf("Expected images 3 in request, got %v", got)
+	}
+}
+
+func TestGenerateImagesCount(t *testing.T) {
+	config := MockConfig()
+	config.Models[0].MaxBatch = 2
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, count := range []int{0, 3} {
+		if _, err := client.GenerateImages(context.Background(), "test prompt", 1, count); err == nil {
+			t.Errorf("Expected error for count %d", count)
+		}
+	}
+}
+
+// largeImage stands in for a 4K generation
+var largeImage = bytes.Repeat([]byte{0x89}, 10<<20)
+
+// newLargeImageServer serves largeImage, in chunks without a Content-Length
+// when chunked is set
+func newLargeImageServer(tb testing.TB, chunked bool) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !chunked {
+			w.Header().Set("Content-Length", strconv.Itoa(len(largeImage)))
+			w.Write(largeImage)
+			return
+		}
+		for chunk := range slices.Chunk(largeImage, 256<<10) {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// BenchmarkDownloadLargeImage downloads a 10 MB image; the disabled buffer
+// is the io.ReadAll baseline.
+func BenchmarkDownloadLargeImage(b *testing.B) {
+	for _, chunked := range []bool{false, true} {
+		name := "content_length"
+		if chunked {
+			name = "chunked"
+		}
+		server := newLargeImageServer(b, chunked)
+		for _, buffer := range []struct {
+			name string
+			size int
+		}{{"default", 0}, {"disabled", -1}} {
+			b.Run(name+"/"+buffer.name, func(b *testing.B) {
+				config := MockConfig()
+				config.API.DownloadBufferSize = buffer.size
+				client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+				b.ReportAllocs()
+				b.SetBytes(int64(len(largeImage)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := client.downloadImageBytes(context.Background(), server.URL); err != nil {
+						b.Fatalf("downloadImageBytes failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReadBody_FewerAllocations(t *testing.T) {
+	allocs := func(contentLength int64, size int) float64 {
+		return testing.AllocsPerRun(20, func() {
+			resp := &http.Response{Body: io.NopCloser(struct{ io.Reader }{bytes.NewReader(largeImage)}), ContentLength: contentLength}
+			data, err := readBody(resp, size)
+			if err != nil || len(data) != len(largeImage) {
+				t.Fatalf("readBody = %d bytes, %v", len(data), err)
+			}
+		})
+	}
+
+	baseline := allocs(-1, -1)
+	if got := allocs(int64(len(largeImage)), len(largeImage)); got >= baseline {
+		t.Errorf("Expected fewer than %v allocations with a Content-Length, got %v", baseline, got)
+	}
+}
+
+func TestReadBody_ForgedContentLength(t *testing.T) {
+	body := []byte("tiny")
+	for _, contentLength := range []int64{1 << 40, 2} {
+		resp := &http.Response{Body: io.NopCloser(bytes.NewReader(body)), ContentLength: contentLength}
+		data, err := readBody(resp, defaultDownloadBufferSize)
+		if err != nil {
+			t.Fatalf("readBody failed: %v", err)
+		}
+		if !bytes.Equal(data, body) {
+			t.Errorf("Content-Length %d: expected %q, got %q", contentLength, body, data)
+		}
+		if cap(data) > defaultDownloadBufferSize+bytes.MinRead {
+			t.Errorf("Content-Length %d: buffer of %d bytes exceeds the limit", contentLength, cap(data))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+
+	"github.com/gorilla/websocket"
+)
+
+// ClientInfo is the payload of the "client_info" message sent after
+// authenticating, describing this worker to the task server
+type ClientInfo struct {
+	Version      string            `json:"version"`
+	GoVersion    string            `json:"go_version"`
+	OS           string            `json:"os"`
+	Arch         string            `json:"arch"`
+	ModelNames   []string          `json:"model_names"`
+	Capabilities []string          `json:"capabilities"`
+	QueueDepth   int               `json:"queue_depth"`
+	Extra        map[string]string `json:"extra,omitempty"`
+}
+
+// ServerInfo is the payload of the server's optional "server_info" reply
+type ServerInfo struct {
+	ServerVersion string `json:"server_version"`
+}
+
+// WithClientInfo adds custom fields to the client_info message
+func WithClientInfo(extra map[string]string) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.clientInfo = extra
+	}
+}
+
+func (w *WebSocketClient) sendClientInfo(conn *websocket.Conn, queueDepth int) error {
+	info := ClientInfo{
+		Version:      packageVersion,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		ModelNames:   make([]string, 0, len(w.config.Models)),
+		Capabilities: w.capabilities,
+		QueueDepth:   queueDepth,
+		Extra:        w.clientInfo,
+	}
+	for _, m := range w.config.Models {
+		info.ModelNames = append(info.ModelNames, m.Name)
+	}
+	if info.Capabilities == nil {
+		info.Capabilities = []string{}
+	}
+
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(context.Background(), conn, WebSocketMessage{Type: "client_info", Payload: payload})
+}
+
+// handleServerInfo logs the server's reply to client_info
+func (w *WebSocketClient) handleServerInfo(ctx context.Context, message WebSocketMessage) error {
+	var info ServerInfo
+	if err := json.Unmarshal(message.Payload, &info); err != nil {
+		return malformedPayload(message.Type, err)
+	}
+	loggerFromContext(ctx, w.logger).Info("Connected to task server", "server_version", info.ServerVersion)
+	return nil
+}
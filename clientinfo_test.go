@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClientInfoServer authenticates one client and passes on the payload of
+// its client_info message
+func newClientInfoServer(t *testing.T) (*httptest.Server, <-chan json.RawMessage) {
+	t.Helper()
+	received := make(chan json.RawMessage, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var msg WebSocketMessage
+		if conn.ReadJSON(&msg) != nil {
+			return
+		}
+		conn.WriteJSON(WebSocketMessage{Type: "auth_success", Payload: []byte(`{"token":"t"}`)})
+		for conn.ReadJSON(&msg) == nil {
+			if msg.Type == "client_info" {
+				received <- msg.Payload
+				conn.WriteJSON(WebSocketMessage{Type: "server_info", Payload: []byte(`{"server_version":"2.1.0"}`)})
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestConnect_SendsClientInfo(t *testing.T) {
+	server, received := newClientInfoServer(t)
+	config := MockConfig()
+	useWebSocketServer(config, server)
+	config.Server.Capabilities = []string{"sdxl"}
+	config.Server.QueueDepth = 8
+	w := newTestWebSocketClient(t, config, WithClientInfo(map[string]string{"region": "eu-west"}))
+
+	go w.connect()
+
+	var payload json.RawMessage
+	select {
+	case payload = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no client_info message was sent")
+	}
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(payload, &fields))
+	for _, field := range []string{"version", "go_version", "os", "arch", "model_names", "capabilities", "queue_depth"} {
+		assert.Contains(t, fields, field)
+	}
+
+	var info ClientInfo
+	require.NoError(t, json.Unmarshal(payload, &info))
+	assert.Equal(t, packageVersion, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, runtime.GOOS, info.OS)
+	assert.Equal(t, runtime.GOARCH, info.Arch)
+	require.Len(t, info.ModelNames, len(config.Models))
+	assert.Equal(t, config.Models[0].Name, info.ModelNames[0])
+	assert.Equal(t, []string{"sdxl"}, info.Capabilities)
+	assert.Equal(t, 8, info.QueueDepth)
+	assert.Equal(t, map[string]string{"region": "eu-west"}, info.Extra)
+}
+
+func TestHandleServerInfo(t *testing.T) {
+	var logs bytes.Buffer
+	w := newTestWebSocketClient(t, MockConfig(), WithWebSocketLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	err := w.handleServerInfo(context.Background(), WebSocketMessage{Type: "server_info", Payload: []byte(`{"server_version":"2.1.0"}`)})
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "server_version=2.1.0")
+
+	err = w.handleServerInfo(context.Background(), WebSocketMessage{Type: "server_info", Payload: []byte(`"2.1.0"`)})
+	var protocolErr *WebSocketProtocolError
+	require.ErrorAs(t, err, &protocolErr)
+	assert.Equal(t, ProtocolErrMalformedPayload, protocolErr.Code)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// modelListCache keeps the last model list along with the ETag it was
+// served with
+type modelListCache struct {
+	mu     sync.Mutex
+	etag   string
+	models []RemoteModel
+}
+
+// downloadWithConditional fetches url, sending prevETag as If-None-Match
+// when it is set. A 304 returns no data, prevETag and notModified. The
+// request is a POST of body when body isn't nil, as the API expects for
+// its listing endpoints, and a GET otherwise.
+func (c *Client) downloadWithConditional(ctx context.Context, url string, body []byte, prevETag string) (data []byte, newETag string, notModified bool, err error) {
+	method := http.MethodGet
+	var reqBody io.Reader
+	if body != nil {
+		method = http.MethodPost
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if prevETag != "" {
+		req.Header.Set("If-None-Match", prevETag)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, prevETag, true, nil
+	case http.StatusOK:
+	default:
+		return nil, "", false, parseAPIError(resp)
+	}
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, resp.Header.Get("ETag"), false, nil
+}
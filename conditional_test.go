@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newETagModelServer serves a model list tagged with etag, answering
+// matching If-None-Match requests with a 304. It counts full responses.
+func newETagModelServer(t *testing.T, etag string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var downloads atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/API/ListModels" {
+			http.NotFound(w, r)
+			return
+		}
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads.Add(1)
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		json.NewEncoder(w).Encode(listModelsResponse{Files: []RemoteModel{{Name: "base.safetensors"}}})
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+func TestFetchAvailableModels_NotModified(t *testing.T) {
+	server, downloads := newETagModelServer(t, `"v1"`)
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for range 2 {
+		models, err := client.FetchAvailableModels(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []RemoteModel{{Name: "base.safetensors"}}, models)
+	}
+	assert.Equal(t, int64(1), downloads.Load())
+}
+
+func TestFetchAvailableModels_NoETag(t *testing.T) {
+	server, downloads := newETagModelServer(t, "")
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for range 2 {
+		_, err := client.FetchAvailableModels(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), downloads.Load())
+}
+
+func TestDownloadWithConditional(t *testing.T) {
+	server, _ := newETagModelServer(t, `"v1"`)
+	client := NewClient(MockConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	url := server.URL + "/API/ListModels"
+
+	data, etag, notModified, err := client.downloadWithConditional(context.Background(), url, []byte("{}"), "")
+	require.NoError(t, err)
+	assert.False(t, notModified)
+	assert.Equal(t, `"v1"`, etag)
+	assert.Contains(t, string(data), "base.safetensors")
+
+	data, etag, notModified, err = client.downloadWithConditional(context.Background(), url, []byte("{}"), `"v1"`)
+	require.NoError(t, err)
+	assert.True(t, notModified)
+	assert.Equal(t, `"v1"`, etag)
+	assert.Nil(t, data)
+
+	_, _, _, err = client.downloadWithConditional(context.Background(), server.URL+"/missing", nil, "")
+	assert.Error(t, err)
+}
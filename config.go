@@ -1,51 +1,176 @@
 package main
 
 import (
-	"os"
-
-	"gopkg.in/yaml.v3"
+	"fmt"
+	"log/slog"
 )
 
 type Config struct {
-	Server ServerConfig  `yaml:"server"`
-	API    APIConfig     `yaml:"api"`
-	Models []ModelConfig `yaml:"models"`
+	Version                int           `yaml:"version"` // schema version, 0 for files predating versioning
+	Server                 ServerConfig  `yaml:"server"`
+	API                    APIConfig     `yaml:"api"`
+	Upload                 UploadConfig  `yaml:"upload"`
+	Log                    LogConfig     `yaml:"log"`
+	Queue                  QueueConfig   `yaml:"queue"`
+	Models                 []ModelConfig `yaml:"models"`
+	ModelSelectionStrategy string        `yaml:"model_selection_strategy"`
+	AutoSelectModel        bool          `yaml:"auto_select_model"` // reroute tasks their model can't run
+
+	HTTPServer HTTPServerConfig `yaml:"http_server"`
+
+	ConfigPath string `yaml:"-"` // file the config was loaded from, set by LoadConfig
 }
 
 type ServerConfig struct {
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	Passcode string `yaml:"passcode"`
+	Host             string   `yaml:"host"`
+	Port             string   `yaml:"port"`
+	Passcode         string   `yaml:"passcode"`
+	SendBufferSize   int      `yaml:"send_buffer_size"`
+	QueueDepth       int      `yaml:"queue_depth"`          // tasks buffered for the worker, defaultQueueDepth when 0
+	Capabilities     []string `yaml:"capabilities"`         // worker features tasks may require, e.g. "sdxl", "fp16"
+	PongTimeout      int      `yaml:"pong_timeout_seconds"` // reconnect after this long without a pong, 30 when 0
+	FilenameFormat   string   `yaml:"filename_format"`      // result filenames: "uuid" (default), "uuid_compact" or "timestamp_uuid"
+	MinTLSVersion    string   `yaml:"min_tls_version"`      // "1.2" (default) or "1.3"
+	PendingTasksPath string   `yaml:"pending_tasks_path"`   // JSON lines file for the file queue backend
+
+	MaxConnections       int     `yaml:"max_connections"`          // task server connections a MultiServerClient's workers hold open at once, defaultMaxConnections when 0
+	MaxOutboundMsgPerSec float64 `yaml:"max_outbound_msg_per_sec"` // defaultOutboundRate when 0, negative disables
+
+	MaxFrameSize int `yaml:"max_frame_size"` // results with larger images are sent in chunks of this many bytes, defaultMaxFrameSize when 0, negative disables
+
+	EnablePreemption   bool `yaml:"enable_preemption"`    // let higher priority tasks cancel and requeue the running one
+	RequireRequestedBy bool `yaml:"require_requested_by"` // reject tasks without requested_by
+
+	DialTimeout         int `yaml:"dial_timeout_seconds"`          // defaultDialTimeout when 0
+	TLSHandshakeTimeout int `yaml:"tls_handshake_timeout_seconds"` // TLS handshake and WebSocket upgrade, defaultTLSHandshakeTimeout when 0
+
+	SubProtocols []string `yaml:"subprotocols"` // message encodings offered, "json" and "msgpack", [json] when empty
+
+	ShutdownTimeout int `yaml:"shutdown_timeout_seconds"` // how long SIGINT or SIGTERM waits for the running task, defaultShutdownTimeout when 0
 }
 
 type APIConfig struct {
-	Host    string `yaml:"host"`
-	Port    string `yaml:"port"`
-	Timeout int    `yaml:"timeout"`
+	Host          string          `yaml:"host"`
+	Port          string          `yaml:"port"`
+	Timeout       int             `yaml:"timeout"`
+	EmbedMetadata bool            `yaml:"embed_metadata"`
+	BlocklistPath string          `yaml:"blocklist_path"`
+	Watermark     WatermarkConfig `yaml:"watermark"`
+
+	SessionAffinityMode bool `yaml:"session_affinity"`    // keep one session per model instead of one per generation
+	SessionParallelism  int  `yaml:"session_parallelism"` // generations running at once in an affinity session, unbounded when 1 or less
+	MaxPromptLength     int  `yaml:"max_prompt_length"`   // prompt bytes, defaultMaxPromptLength when 0, negative disables
+	TruncatePrompt      bool `yaml:"truncate_prompt"`     // cut long prompts at a word boundary instead of failing
+
+	MaxUpscaleInputWidth  int `yaml:"max_upscale_input_width"`  // defaultMaxUpscaleInput when 0
+	MaxUpscaleInputHeight int `yaml:"max_upscale_input_height"` // defaultMaxUpscaleInput when 0
+
+	ProgressPollingInterval int `yaml:"progress_polling_interval"` // seconds, defaultProgressInterval when 0, negative disables
+	DownloadBufferSize      int `yaml:"download_buffer_size"`      // bytes preallocated per download, defaultDownloadBufferSize when 0, negative disables
+
+	MaxIdleConns        int `yaml:"max_idle_conns"`            // idle API connections kept, defaultMaxIdleConns when 0
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`   // defaultMaxIdleConnsPerHost when 0
+	IdleConnTimeout     int `yaml:"idle_conn_timeout_seconds"` // close idle connections after this long, defaultIdleConnTimeout when 0
+
+	PromptLibraryPath  string       `yaml:"prompt_library_path"`
+	LoRALibraryPath    string       `yaml:"lora_library_path"`
+	TaskLogPath        string       `yaml:"task_log_path"`
+	TaskSchemaPath     string       `yaml:"task_schema_path"`
+	MinSwarmUIVersion  string       `yaml:"min_swarmui_version"`
+	BudgetCounterPath  string       `yaml:"budget_counter_path"`
+	ModelInventoryPath string       `yaml:"model_inventory_path"`
+	ModelInventoryTTL  int          `yaml:"model_inventory_ttl"` // seconds, defaultModelInventoryTTL when 0
+	Retry              RetryConfig  `yaml:"retry"`
+	MaxRetryAfter      int          `yaml:"max_retry_after_seconds"` // longest Retry-After honored, defaultMaxRetryAfter when 0
+	Budget             BudgetConfig `yaml:"budget"`
+	Cost               CostConfig   `yaml:"cost"`
+	ABTest             ABTestConfig `yaml:"ab_test"`
+	CacheSize          int          `yaml:"cache_size"` // seeded generations to cache, defaultCacheSize when 0, negative disables
+
+	Translation TranslationConfig `yaml:"translation"`
+
+	WarmupSchedule []WarmupJob `yaml:"warmup_schedule"`
+
+	RequestJitterMs     int     `yaml:"request_jitter_ms"`     // random delay of up to this long before each generation request, 0 disables
+	MinEntropyThreshold float64 `yaml:"min_entropy_threshold"` // reject and retry images below this, defaultMinEntropy when 0, negative disables
+
+	SyncRemoteModels bool `yaml:"sync_remote_models"` // add models the API lists but the config lacks at preflight, saved to the config file on exit
+}
+
+type LogConfig struct {
+	Format string `yaml:"format"` // "text" (default) or "json"
+	Level  string `yaml:"level"`  // "debug" (default), "info", "warn" or "error"
+
+	LogPayloads bool `yaml:"log_payloads"` // include WebSocket message payloads, truncated, in debug logs
+}
+
+type UploadConfig struct {
+	Backend     string   `yaml:"backend"`
+	S3          S3Config `yaml:"s3"`
+	DedupWindow int      `yaml:"dedup_window"` // recent uploads to remember, 0 disables
+}
+
+type S3Config struct {
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
 }
 
 type ModelConfig struct {
-	Name        string         `yaml:"name"`
-	String      string         `yaml:"string"`
-	Width       int            `yaml:"width"`
-	Height      int            `yaml:"height"`
-	Steps       int            `yaml:"steps"`
-	Cfgscale    float32        `yaml:"cfgscale"`
-	Loras       string         `yaml:"loras,omitempty"`
-	LoraWeights float32        `yaml:"loraweights,omitempty"`
-	Options     map[string]any `yaml:",inline"`
+	Name           string         `yaml:"name"`
+	String         string         `yaml:"string"`
+	Width          int            `yaml:"width"`
+	Height         int            `yaml:"height"`
+	AspectRatio    string         `yaml:"aspect_ratio"`
+	Steps          int            `yaml:"steps"`
+	Cfgscale       float32        `yaml:"cfgscale"`
+	Loras          string         `yaml:"loras,omitempty"`
+	LoraWeights    float32        `yaml:"loraweights,omitempty"`
+	LoraStack      []LoraEntry    `yaml:"lora_stack"`
+	LoraPreset     string         `yaml:"lora_preset"`
+	FallbackModels []string       `yaml:"fallback_models"`
+	Capabilities   []string       `yaml:"capabilities"` // empty supports every task type
+	MaxRetries     int            `yaml:"max_retries"`  // 0 uses api.retry, negative never retries
+	MaxBatch       int            `yaml:"max_batch"`    // most images per request, defaultMaxBatch when 0
+	Options        map[string]any `yaml:",inline"`
+
+	DefaultControlNet *ControlNetConfig `yaml:"controlnet"` // used for tasks with a guidance image but no ControlNet settings
 }
 
+const defaultMaxBatch = 4
+
+func (m ModelConfig) maxBatch() int {
+	if m.MaxBatch > 0 {
+		return m.MaxBatch
+	}
+	return defaultMaxBatch
+}
+
+// LoadConfig reads the config file, inlining files named by !include tags
 func LoadConfig(configPath string) (*Config, error) {
-	file, err := os.Open(configPath)
+	doc, err := loadYAMLWithIncludes(configPath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	config := &Config{}
-	if err := yaml.NewDecoder(file).Decode(&config); err != nil {
+	if err := doc.Decode(config); err != nil {
+		return nil, err
+	}
+
+	oldVersion := config.Version
+	if err := migrateConfig(config); err != nil {
 		return nil, err
 	}
+	PrintMigrationSummary(oldVersion, config.Version)
+
+	for i := range config.Models {
+		if err := config.Models[i].applyAspectRatio(slog.Default()); err != nil {
+			return nil, fmt.Errorf("model %q: %w", config.Models[i].Name, err)
+		}
+	}
+	config.ConfigPath = configPath
 	return config, nil
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Source supplies config values. Keys a source doesn't set are left zero.
+type Source interface {
+	Load() (*Config, error)
+}
+
+// FillConfig applies sources to cfg left to right. Later sources override
+// earlier ones, except that zero values never override.
+func FillConfig(cfg *Config, sources ...Source) error {
+	for _, source := range sources {
+		partial, err := source.Load()
+		if err != nil {
+			return err
+		}
+		mergeNonZero(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(partial).Elem())
+	}
+	return nil
+}
+
+func mergeNonZero(dst, src reflect.Value) {
+	for i := range src.NumField() {
+		field := src.Field(i)
+		switch {
+		case !dst.Field(i).CanSet():
+		case field.Kind() == reflect.Struct:
+			mergeNonZero(dst.Field(i), field)
+		case field.IsZero(), field.Kind() == reflect.Slice && field.Len() == 0:
+		default:
+			dst.Field(i).Set(field)
+		}
+	}
+}
+
+// YAMLSource reads a config file the way LoadConfig does
+type YAMLSource struct {
+	Path string
+}
+
+func (s YAMLSource) Load() (*Config, error) {
+	return LoadConfig(s.Path)
+}
+
+// EnvSource reads keys from environment variables named after their YAML
+// path, upper cased with underscores, e.g. GENCLIENT_SERVER_HOST for
+// server.host. Lists are comma separated; the models list can't be set.
+type EnvSource struct {
+	Prefix string                          // e.g. "GENCLIENT", empty for none
+	Lookup func(key string) (string, bool) // os.LookupEnv when nil
+}
+
+func (s EnvSource) Load() (*Config, error) {
+	lookup := s.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	cfg := &Config{}
+	for _, key := range configKeys(reflect.TypeOf(Config{}), nil) {
+		name := strings.ToUpper(strings.Join(key, "_"))
+		if s.Prefix != "" {
+			name = s.Prefix + "_" + name
+		}
+		if value, ok := lookup(name); ok {
+			if err := setConfigKey(cfg, key, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// FlagSource reads keys from the flags set on the command line whose names
+// are YAML paths, e.g. -server.host. Other flags are ignored.
+type FlagSource struct {
+	FlagSet *flag.FlagSet
+}
+
+func (s FlagSource) Load() (*Config, error) {
+	cfg := &Config{}
+	var err error
+	s.FlagSet.Visit(func(f *flag.Flag) {
+		key := strings.Split(f.Name, ".")
+		if err != nil || !isConfigKey(key) {
+			return
+		}
+		if setErr := setConfigKey(cfg, key, f.Value.String()); setErr != nil {
+			err = fmt.Errorf("-%s: %w", f.Name, setErr)
+		}
+	})
+	return cfg, err
+}
+
+// yamlName is the key a struct field is read from, "" for skipped fields
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// configKeys lists the YAML paths of every scalar and string list under t
+func configKeys(t reflect.Type, prefix []string) [][]string {
+	var keys [][]string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := yamlName(field)
+		if name == "" {
+			continue
+		}
+		key := append(append([]string(nil), prefix...), name)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			keys = append(keys, configKeys(field.Type, key)...)
+		case isScalar(field.Type), field.Type.Kind() == reflect.Slice && isScalar(field.Type.Elem()):
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func isConfigKey(key []string) bool {
+	for _, k := range configKeys(reflect.TypeOf(Config{}), nil) {
+		if strings.Join(k, ".") == strings.Join(key, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+// setConfigKey parses value into the field at the YAML path key
+func setConfigKey(cfg *Config, key []string, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+walk:
+	for _, name := range key {
+		for i := range v.NumField() {
+			if yamlName(v.Type().Field(i)) == name {
+				v = v.Field(i)
+				continue walk
+			}
+		}
+		return fmt.Errorf("unknown config key %s", strings.Join(key, "."))
+	}
+
+	if v.Kind() != reflect.Slice {
+		return setScalar(v, value)
+	}
+	parts := strings.Split(value, ",")
+	list := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		if err := setScalar(list.Index(i), strings.TrimSpace(part)); err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+func setScalar(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
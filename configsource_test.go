@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillConfig_Precedence(t *testing.T) {
+	path := writeTempFile(t, "config.yaml", `
+server:
+  host: yaml.example.com
+  port: "443"
+  queue_depth: 16
+  capabilities: [sdxl]
+api:
+  host: localhost
+  timeout: 30
+  embed_metadata: true
+models:
+  - name: SD
+    string: sd_xl
+`)
+	env := map[string]string{
+		"GENCLIENT_SERVER_HOST":              "env.example.com",
+		"GENCLIENT_SERVER_CAPABILITIES":      "sdxl, fp16",
+		"GENCLIENT_API_TIMEOUT":              "60",
+		"GENCLIENT_API_RETRY_MAX_RETRIES":    "5",
+		"GENCLIENT_SERVER_QUEUE_DEPTH":       "0", // zero values never override
+		"GENCLIENT_API_EMBED_METADATA":       "false",
+		"GENCLIENT_MODEL_SELECTION_STRATEGY": "round_robin",
+	}
+	flags := flag.NewFlagSet("genclient", flag.ContinueOnError)
+	flags.String("server.host", "", "")
+	flags.Int("api.timeout", 0, "")
+	flags.Bool("generate", false, "") // not a config key
+	require.NoError(t, flags.Parse([]string{"-server.host=flag.example.com", "-generate"}))
+
+	cfg := &Config{}
+	err := FillConfig(cfg,
+		YAMLSource{Path: path},
+		EnvSource{Prefix: "GENCLIENT", Lookup: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}},
+		FlagSource{FlagSet: flags},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "flag.example.com", cfg.Server.Host, "flags override env and yaml")
+	assert.Equal(t, 60, cfg.API.Timeout, "unset flags don't override env")
+	assert.Equal(t, "443", cfg.Server.Port, "yaml survives when nothing overrides it")
+	assert.Equal(t, []string{"sdxl", "fp16"}, cfg.Server.Capabilities)
+	assert.Equal(t, 5, cfg.API.Retry.MaxRetries)
+	assert.Equal(t, 16, cfg.Server.QueueDepth)
+	assert.True(t, cfg.API.EmbedMetadata)
+	assert.Equal(t, "round_robin", cfg.ModelSelectionStrategy)
+	require.Len(t, cfg.Models, 1)
+	assert.Equal(t, "SD", cfg.Models[0].Name)
+	assert.Equal(t, currentConfigVersion, cfg.Version)
+}
+
+func TestEnvSource_InvalidValue(t *testing.T) {
+	t.Setenv("GENCLIENT_API_TIMEOUT", "soon")
+	err := FillConfig(&Config{}, EnvSource{Prefix: "GENCLIENT"})
+	assert.ErrorContains(t, err, "GENCLIENT_API_TIMEOUT")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigWatcher writes runtime config changes back to Config.ConfigPath
+// when the process exits, so they survive a restart. Only changes made
+// through Update are written, patched into the file as it is on disk:
+// environment overrides, the passcode, included files and dimensions
+// derived from aspect ratios stay out of it unless Update changed them.
+type ConfigWatcher struct {
+	mu      sync.Mutex
+	config  *Config
+	changes []configChange
+	logger  *slog.Logger
+
+	signals chan os.Signal
+}
+
+// configChange is one Update change: the value at path, or elements to
+// append there when appended is set
+type configChange struct {
+	path     []string
+	value    *yaml.Node
+	appended bool
+}
+
+func NewConfigWatcher(config *Config, logger *slog.Logger) *ConfigWatcher {
+	return &ConfigWatcher{config: config, logger: logger}
+}
+
+// Update changes the config under the watcher's lock and records what fn
+// changed for writing
+func (cw *ConfigWatcher) Update(fn func(config *Config)) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	before := cw.encode()
+	fn(cw.config)
+	after := cw.encode()
+	if before == nil || after == nil {
+		return
+	}
+	cw.changes = append(cw.changes, diffConfigNodes(nil, before, after)...)
+}
+
+// encode returns the config as a YAML node, nil when it can't be encoded
+func (cw *ConfigWatcher) encode() *yaml.Node {
+	var node yaml.Node
+	if err := node.Encode(cw.config); err != nil {
+		cw.logger.Error("Config encode failed", "error", err)
+		return nil
+	}
+	return &node
+}
+
+// SaveOnExit writes the config as soon as the process is interrupted or
+// terminated, in case the shutdown that follows never reaches Close. It
+// doesn't exit: stopping the process is left to main.
+func (cw *ConfigWatcher) SaveOnExit() {
+	cw.signals = make(chan os.Signal, 1)
+	signal.Notify(cw.signals, os.Interrupt, syscall.SIGTERM)
+	go cw.saveOnSignal(cw.signals)
+}
+
+func (cw *ConfigWatcher) saveOnSignal(signals <-chan os.Signal) {
+	sig, ok := <-signals
+	if !ok {
+		return
+	}
+	cw.logger.Info("Saving config before exit", "signal", sig)
+	if err := cw.flush(); err != nil {
+		cw.logger.Error("Config save failed", "error", err)
+	}
+}
+
+// Close stops watching for signals and writes the config if it changed
+func (cw *ConfigWatcher) Close() error {
+	if cw.signals != nil {
+		signal.Stop(cw.signals)
+	}
+	return cw.flush()
+}
+
+// flush writes the recorded changes, if any
+func (cw *ConfigWatcher) flush() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if len(cw.changes) == 0 {
+		return nil
+	}
+	if err := cw.save(); err != nil {
+		return err
+	}
+	cw.changes = nil
+	return nil
+}
+
+// save patches the recorded changes into the config file, leaving !include
+// tags and everything Update didn't touch as they are
+func (cw *ConfigWatcher) save() error {
+	path := cw.config.ConfigPath
+	if path == "" {
+		return errors.New("config has no path to save to")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	for _, change := range cw.changes {
+		if err := applyConfigChange(doc.Content[0], change); err != nil {
+			return err
+		}
+	}
+	data, err = yaml.Marshal(&doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// diffConfigNodes lists the changes from before to after below path.
+// Mappings and same-length sequences are compared entry by entry, and a
+// sequence that only grew is recorded as an append, so untouched entries
+// aren't rewritten.
+func diffConfigNodes(path []string, before, after *yaml.Node) []configChange {
+	if before.Kind == yaml.DocumentNode && after.Kind == yaml.DocumentNode {
+		return diffConfigNodes(path, before.Content[0], after.Content[0])
+	}
+	if before.Kind != after.Kind {
+		return []configChange{{path: path, value: after}}
+	}
+
+	switch after.Kind {
+	case yaml.MappingNode:
+		var changes []configChange
+		for i := 0; i+1 < len(after.Content); i += 2 {
+			key := after.Content[i].Value
+			childPath := append(path[:len(path):len(path)], key)
+			if old := mappingValue(before, key); old != nil {
+				changes = append(changes, diffConfigNodes(childPath, old, after.Content[i+1])...)
+			} else {
+				changes = append(changes, configChange{path: childPath, value: after.Content[i+1]})
+			}
+		}
+		return changes
+
+	case yaml.SequenceNode:
+		n := len(before.Content)
+		if len(after.Content) < n {
+			return []configChange{{path: path, value: after}}
+		}
+		var changes []configChange
+		for i := range n {
+			childPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			changes = append(changes, diffConfigNodes(childPath, before.Content[i], after.Content[i])...)
+		}
+		if len(after.Content) > n {
+			added := &yaml.Node{Kind: yaml.SequenceNode, Content: after.Content[n:]}
+			changes = append(changes, configChange{path: path, value: added, appended: true})
+		}
+		return changes
+
+	default:
+		if before.Value != after.Value || before.Tag != after.Tag {
+			return []configChange{{path: path, value: after}}
+		}
+		return nil
+	}
+}
+
+// applyConfigChange writes change into the mapping root of a config file
+func applyConfigChange(root *yaml.Node, change configChange) error {
+	node := root
+	for i, key := range change.path {
+		if node.Tag == includeTag {
+			return fmt.Errorf("can't save %s: it's in an included file", joinConfigPath(change.path[:i]))
+		}
+		last := i == len(change.path)-1
+		switch node.Kind {
+		case yaml.MappingNode:
+			child := mappingValue(node, key)
+			if child == nil {
+				child = &yaml.Node{Kind: yaml.MappingNode}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+			}
+			if last && !change.appended {
+				*child = *change.value
+				return nil
+			}
+			node = child
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(key)
+			if err != nil || index >= len(node.Content) {
+				return fmt.Errorf("can't save %s: no such entry", joinConfigPath(change.path[:i+1]))
+			}
+			if last && !change.appended {
+				*node.Content[index] = *change.value
+				return nil
+			}
+			node = node.Content[index]
+		default:
+			return fmt.Errorf("can't save %s: %s isn't a mapping", joinConfigPath(change.path), joinConfigPath(change.path[:i]))
+		}
+	}
+
+	if node.Tag == includeTag {
+		return fmt.Errorf("can't save %s: it's in an included file", joinConfigPath(change.path))
+	}
+	if node.Kind == yaml.MappingNode && len(node.Content) == 0 {
+		// a key the file didn't have yet
+		*node = yaml.Node{Kind: yaml.SequenceNode}
+	}
+	if node.Kind != yaml.SequenceNode {
+		return fmt.Errorf("can't save %s: not a list", joinConfigPath(change.path))
+	}
+	node.Content = append(node.Content, change.value.Content...)
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, nil if absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func joinConfigPath(path []string) string {
+	return strings.Join(path, ".")
+}
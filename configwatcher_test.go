@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestConfig writes a minimal config file and loads it
+func writeTestConfig(t *testing.T) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "version: 1\nserver:\n  host: tasks.example\nmodels:\n  - name: base\n    string: base.safetensors\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, path, config.ConfigPath)
+	return config
+}
+
+func TestConfigWatcher_Close(t *testing.T) {
+	config := writeTestConfig(t)
+	before, err := os.ReadFile(config.ConfigPath)
+	require.NoError(t, err)
+
+	watcher := NewConfigWatcher(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, watcher.Close())
+	after, err := os.ReadFile(config.ConfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "clean configs aren't written")
+
+	watcher.Update(func(c *Config) {
+		c.Models = append(c.Models, ModelConfig{Name: "fetched", String: "fetched.safetensors"})
+	})
+	require.NoError(t, watcher.Close())
+
+	saved, err := LoadConfig(config.ConfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, "tasks.example", saved.Server.Host)
+	require.Len(t, saved.Models, 2)
+	assert.Equal(t, "fetched", saved.Models[1].Name)
+	assert.NoFileExists(t, config.ConfigPath+".tmp")
+}
+
+func TestConfigWatcher_SaveOnSignal(t *testing.T) {
+	config := writeTestConfig(t)
+	watcher := NewConfigWatcher(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	watcher.Update(func(c *Config) { c.Server.Host = "moved.example" })
+	signals := make(chan os.Signal, 1)
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		watcher.saveOnSignal(signals)
+	}()
+	signals <- os.Interrupt
+
+	select {
+	case <-saved:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher didn't save")
+	}
+	loaded, err := LoadConfig(config.ConfigPath)
+	require.NoError(t, err)
+	assert.Equal(t, "moved.example", loaded.Server.Host)
+}
+
+func TestConfigWatcher_OnlyWritesUpdates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "version: 1\nserver:\n  host: tasks.example\napi: !include api.yaml\nmodels:\n  - name: wide\n    string: wide.safetensors\n    aspect_ratio: \"16:9\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api.yaml"), []byte("host: api.example\n"), 0o600))
+
+	config := &Config{}
+	env := map[string]string{"GENCLIENT_SERVER_PASSCODE": "secret", "GENCLIENT_SERVER_PORT": "9999"}
+	lookup := func(key string) (string, bool) { v, ok := env[key]; return v, ok }
+	require.NoError(t, FillConfig(config, YAMLSource{Path: path}, EnvSource{Prefix: "GENCLIENT", Lookup: lookup}))
+	require.NotZero(t, config.Models[0].Height, "height comes from the aspect ratio")
+
+	watcher := NewConfigWatcher(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	watcher.Update(func(c *Config) {
+		c.Server.Host = "moved.example"
+		c.Models = append(c.Models, ModelConfig{Name: "added", String: "added"})
+	})
+	require.NoError(t, watcher.Close())
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(written), "api: !include api.yaml")
+	assert.NotContains(t, string(written), "secret")
+	assert.NotContains(t, string(written), "9999")
+	assert.NotContains(t, string(written), fmt.Sprintf("height: %d", config.Models[0].Height))
+
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "moved.example", loaded.Server.Host)
+	assert.Equal(t, "api.example", loaded.API.Host)
+	require.Len(t, loaded.Models, 2)
+	assert.Equal(t, config.Models[0].Height, loaded.Models[0].Height)
+	assert.Equal(t, "added", loaded.Models[1].Name)
+}
+
+func TestConfigWatcher_IncludedUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("version: 1\napi: !include api.yaml\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api.yaml"), []byte("host: api.example\n"), 0o600))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	watcher := NewConfigWatcher(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	watcher.Update(func(c *Config) { c.API.Host = "moved.example" })
+	assert.ErrorContains(t, watcher.Close(), "included file")
+
+	included, err := os.ReadFile(filepath.Join(dir, "api.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "host: api.example\n", string(included))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// defaultMaxConnections caps task server connections when
+	// server.max_connections is unset
+	defaultMaxConnections = 4
+	// connectionAcquireTimeout is how long connect waits for a free slot
+	connectionAcquireTimeout = 30 * time.Second
+)
+
+// ErrMaxConnectionsExceeded is returned by connect when no connection slot
+// freed up in time
+var ErrMaxConnectionsExceeded = errors.New("max connections exceeded")
+
+func (c ServerConfig) maxConnections() int {
+	if c.MaxConnections > 0 {
+		return c.MaxConnections
+	}
+	return defaultMaxConnections
+}
+
+// ConnectionLimiter bounds how many WebSocket connections the clients
+// sharing it hold open at once
+type ConnectionLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+func NewConnectionLimiter(maxConnections int) *ConnectionLimiter {
+	return &ConnectionLimiter{
+		slots:   make(chan struct{}, maxConnections),
+		timeout: connectionAcquireTimeout,
+	}
+}
+
+// acquire takes a slot, giving up when stop is closed or after the timeout
+func (l *ConnectionLimiter) acquire(stop <-chan struct{}) error {
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-stop:
+		return errors.New("client stopped")
+	case <-timer.C:
+		return ErrMaxConnectionsExceeded
+	}
+}
+
+func (l *ConnectionLimiter) release() {
+	<-l.slots
+}
+
+// WithConnectionLimiter makes the client take a slot from limiter for each
+// connection. Clients given the same limiter share its limit; a client
+// without one holds its single connection unlimited.
+func WithConnectionLimiter(limiter *ConnectionLimiter) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.limiter = limiter
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingServer authenticates clients and keeps their connections open
+// until release is closed, tracking the most open at once
+func newCountingServer(t *testing.T) (server *httptest.Server, peak *atomic.Int32, release chan struct{}) {
+	t.Helper()
+	var active atomic.Int32
+	peak = new(atomic.Int32)
+	release = make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server = httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		var auth WebSocketMessage
+		if conn.ReadJSON(&auth) != nil {
+			return
+		}
+		conn.WriteJSON(WebSocketMessage{Type: "auth_success", Payload: []byte(`{"token":"t"}`)})
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	return server, peak, release
+}
+
+func TestConnect_MaxConnections(t *testing.T) {
+	server, peak, release := newCountingServer(t)
+	config := MockConfig()
+	useWebSocketServer(config, server)
+
+	limiter := NewConnectionLimiter(3)
+	limiter.timeout = 300 * time.Millisecond
+
+	clients := make([]*WebSocketClient, 5)
+	errs := make(chan error, len(clients))
+	var wg sync.WaitGroup
+	for i := range clients {
+		clients[i] = newTestWebSocketClient(t, config, WithConnectionLimiter(limiter))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- clients[i].connect()
+		}()
+	}
+
+	// the two clients without a slot give up, the rest stay connected
+	for range 2 {
+		select {
+		case err := <-errs:
+			assert.ErrorIs(t, err, ErrMaxConnectionsExceeded)
+		case <-time.After(5 * time.Second):
+			t.Fatal("clients over the limit did not give up")
+		}
+	}
+	assert.Equal(t, int32(3), peak.Load())
+	assert.Len(t, limiter.slots, 3)
+
+	close(release)
+	wg.Wait()
+	assert.Empty(t, limiter.slots, "slots are released on disconnect")
+}
+
+func TestServerConfig_MaxConnections(t *testing.T) {
+	assert.Equal(t, defaultMaxConnections, ServerConfig{}.maxConnections())
+	assert.Equal(t, 2, ServerConfig{MaxConnections: 2}.maxConnections())
+}
+
+func TestNewMultiServerClient_SharesLimiter(t *testing.T) {
+	const workers, maxConnections = 4, 2
+	var clients []*WebSocketClient
+	var peaks []*atomic.Int32
+	for range workers {
+		server, peak, release := newCountingServer(t)
+		t.Cleanup(func() { close(release) })
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		config.Server.MaxConnections = maxConnections
+		clients = append(clients, newTestWebSocketClient(t, config))
+		peaks = append(peaks, peak)
+	}
+	_, err := NewMultiServerClient(clients...)
+	require.NoError(t, err)
+	for _, w := range clients[1:] {
+		require.Same(t, clients[0].limiter, w.limiter)
+	}
+	clients[0].limiter.timeout = 300 * time.Millisecond
+
+	errs := make(chan error, workers)
+	for _, w := range clients {
+		go func() { errs <- w.connect() }()
+	}
+
+	// the workers without a slot give up, the rest stay connected
+	for range workers - maxConnections {
+		select {
+		case err := <-errs:
+			assert.ErrorIs(t, err, ErrMaxConnectionsExceeded)
+		case <-time.After(5 * time.Second):
+			t.Fatal("workers over the limit did not give up")
+		}
+	}
+	var connected int32
+	for _, peak := range peaks {
+		connected += peak.Load()
+	}
+	assert.Equal(t, int32(maxConnections), connected)
+	assert.Len(t, clients[0].limiter.slots, maxConnections)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+	retryHookKey
+	sessionHookKey
+	jitterHookKey
+	receivedAtKey
+)
+
+// newMessageContext tags ctx with a correlation ID, a logger bound to it
+// and the time the message arrived
+func newMessageContext(ctx context.Context, requestID string, logger *slog.Logger) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	ctx = context.WithValue(ctx, receivedAtKey, time.Now())
+	return context.WithValue(ctx, loggerKey, logger.With("request_id", requestID))
+}
+
+// receivedAtFromContext returns when the message being handled arrived
+func receivedAtFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(receivedAtKey).(time.Time)
+	return t, ok
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// loggerFromContext returns the request-scoped logger, or fallback outside a request
+func loggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// messageRequestID uses the payload's request_id when present, otherwise a fresh one
+func messageRequestID(message WebSocketMessage) string {
+	var payload struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(message.Payload, &payload); err == nil && payload.RequestID != "" {
+		return payload.RequestID
+	}
+	return uuid.NewString()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// logRequestIDs decodes JSON log lines and returns the request_id of each
+func logRequestIDs(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var ids []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("Failed to decode log record: %v", err)
+		}
+		id, _ := record["request_id"].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func newLoggingWebSocketClient(t *testing.T, config *Config, buf *bytes.Buffer) *WebSocketClient {
+	t.Helper()
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	w := NewWebSocketClient(config, NewClient(config, logger), logger)
+	w.out.Store(newOutbox(256, &w.droppedMessages, logger))
+	w.queue = newTaskQueue(1)
+	return w
+}
+
+// TestHandleMessageRequestID verifies every log line of a message carries its request_id
+func TestHandleMessageRequestID(t *testing.T) {
+	config := MockConfig()
+	config.Models = []ModelConfig{
+		{Name: "Primary", String: "primary_model", FallbackModels: []string{"Backup"}},
+		{Name: "Backup", String: "backup_model"},
+	}
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{"from payload", "req-42"},
+		{"generated", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the primary model is missing and the fallback errors out
+			mock := NewMockSwarmUIServer()
+			mock.SetUnavailableModels("primary_model")
+			mock.SetFailOnNthRequest(3)
+			server := mock.Start()
+			defer server.Close()
+			useMockAPI(config, server)
+
+			var buf bytes.Buffer
+			w := newLoggingWebSocketClient(t, config, &buf)
+
+			payload := map[string]any{
+				"uuid":   "00000000-0000-0000-0000-000000000001",
+				"type":   TTI,
+				"prompt": "test prompt",
+				"model":  0,
+			}
+			if tt.requestID != "" {
+				payload["request_id"] = tt.requestID
+			}
+			w.handleMessage(nil, WebSocketMessage{Type: "task", Payload: must(json.Marshal(payload))})
+			// stand in for the worker so the request scope crosses the queue
+			queued := <-w.queue
+			w.handleTask(queued.ctx, queued.conn, queued.task)
+
+			ids := logRequestIDs(t, &buf)
+			// auto-selection, fallback warning and the final failure
+			if len(ids) < 3 {
+				t.Fatalf("Expected at least 3 log lines, got %d", len(ids))
+			}
+			want := ids[0]
+			if want == "" {
+				t.Fatal("Expected request_id on log lines")
+			}
+			if tt.requestID != "" && want != tt.requestID {
+				t.Errorf("Expected request_id '%s', got '%s'", tt.requestID, want)
+			}
+			for i, id := range ids {
+				if id != want {
+					t.Errorf("Log line %d: expected request_id '%s', got '%s'", i, want, id)
+				}
+			}
+		})
+	}
+}
+
+// TestMessageRequestIDUnique verifies messages without a request_id get distinct IDs
+func TestMessageRequestIDUnique(t *testing.T) {
+	msg := WebSocketMessage{Type: "models_update", Payload: json.RawMessage(`[]`)}
+	if messageRequestID(msg) == messageRequestID(msg) {
+		t.Error("Expected generated request IDs to differ")
+	}
+}
+
+// TestMessageContext verifies the request ID round-trips through the context
+func TestMessageContext(t *testing.T) {
+	fallback := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if got := loggerFromContext(context.Background(), fallback); got != fallback {
+		t.Error("Expected fallback logger outside a request")
+	}
+
+	ctx := newMessageContext(context.Background(), "req-7", fallback)
+	if got := requestIDFromContext(ctx); got != "req-7" {
+		t.Errorf("Expected request ID 'req-7', got '%s'", got)
+	}
+	if loggerFromContext(ctx, fallback) == fallback {
+		t.Error("Expected request-scoped logger")
+	}
+}
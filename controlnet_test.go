@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_ControlNet(t *testing.T) {
+	canny := &ControlNetConfig{Preprocessor: "canny", Model: "control_canny", Strength: 0.8, GuidanceStart: 0.1, GuidanceEnd: 0.9}
+	modelDefault := &ControlNetConfig{Model: "control_depth"}
+	guidance := "data:image/png;base64," + base64.StdEncoding.EncodeToString(mockImage)
+
+	tests := []struct {
+		name         string
+		req          GenerateRequest
+		modelDefault *ControlNetConfig
+		want         map[string]any // nil when no ControlNet fields are expected
+		wantErr      bool
+	}{
+		{
+			name: "request settings",
+			req:  GenerateRequest{ControlNet: canny, ControlNetImage: mockImage},
+			want: map[string]any{
+				"controlnetimageinput":   guidance,
+				"controlnetmodel":        "control_canny",
+				"controlnetpreprocessor": "canny",
+				"controlnetstrength":     0.8,
+				"controlnetstart":        0.1,
+				"controlnetend":          0.9,
+			},
+		},
+		{
+			name:         "model default",
+			req:          GenerateRequest{ControlNetImage: mockImage},
+			modelDefault: modelDefault,
+			want: map[string]any{
+				"controlnetimageinput": guidance,
+				"controlnetmodel":      "control_depth",
+			},
+		},
+		{
+			name:         "no guidance image",
+			req:          GenerateRequest{ControlNet: canny},
+			modelDefault: modelDefault,
+		},
+		{
+			name:    "image without settings",
+			req:     GenerateRequest{ControlNetImage: mockImage},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockSwarmUIServer()
+			client := newErrorTestClient(t, mock)
+			client.config.Models[0].DefaultControlNet = tt.modelDefault
+
+			tt.req.Prompt = "a cat"
+			tt.req.ModelID = 1
+			_, err := client.Generate(context.Background(), tt.req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, mock.Generations())
+				return
+			}
+			require.NoError(t, err)
+
+			body := mock.Generations()[0]
+			for key, want := range tt.want {
+				if f, ok := want.(float64); ok {
+					assert.InDelta(t, f, body[key], 1e-6, key)
+				} else {
+					assert.Equal(t, want, body[key], key)
+				}
+			}
+			if tt.want == nil {
+				assert.NotContains(t, body, "controlnetmodel")
+				assert.NotContains(t, body, "controlnetimageinput")
+			}
+		})
+	}
+}
+
+func TestHandleTTITask_ControlNet(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	task.ControlNet = &ControlNetConfig{Model: "control_canny"}
+	task.ControlNetImageB64 = base64.StdEncoding.EncodeToString(mockImage)
+	w.handleTTITask(context.Background(), nil, task)
+
+	assert.Equal(t, StatusCompleted, task.Status())
+	require.Len(t, mock.Generations(), 1)
+	assert.Equal(t, "control_canny", mock.Generations()[0]["controlnetmodel"])
+
+	invalid := NewTasukete(TTI, "a cat", 1)
+	invalid.ControlNet = task.ControlNet
+	invalid.ControlNetImageB64 = "not base64!"
+	w.handleTTITask(context.Background(), nil, invalid)
+
+	assert.Equal(t, StatusFailed, invalid.Status())
+	assert.Len(t, mock.Generations(), 1)
+}
@@ -0,0 +1,23 @@
+package main
+
+type CostConfig struct {
+	PricePerStep float64 `yaml:"price_per_step"` // per step per megapixel, 0 disables estimates
+}
+
+// EstimateCost prices a single image from model as
+// width × height × steps × pricePerStep / 1e6. Tasks carry no dimensions
+// of their own, so the estimate comes from the model they run on.
+func EstimateCost(model ModelConfig, pricePerStep float64) float64 {
+	return float64(model.Width) * float64(model.Height) * float64(model.Steps) * pricePerStep / 1e6
+}
+
+// estimateTaskCost fills in the task's cost estimate when pricing is
+// configured and the task's model exists
+func (w *WebSocketClient) estimateTaskCost(task *Tasukete) {
+	price := w.config.API.Cost.PricePerStep
+	if price <= 0 || task.Model <= 0 || task.Model > len(w.config.Models) {
+		return
+	}
+	task.CostEstimate = EstimateCost(w.config.Models[task.Model-1], price)
+	task.AddMetadata("cost_estimate", task.CostEstimate)
+}
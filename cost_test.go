@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateCost(t *testing.T) {
+	model := ModelConfig{Width: 1024, Height: 1024, Steps: 4}
+	assert.InDelta(t, 1024*1024*4*0.5/1e6, EstimateCost(model, 0.5), 1e-9)
+	assert.Zero(t, EstimateCost(model, 0))
+}
+
+func TestHandleTTITask_CostEstimate(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Cost.PricePerStep = 0.25
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+
+	// 512 × 512 × 20 steps
+	want := 512 * 512 * 20 * 0.25 / 1e6
+	assert.InDelta(t, want, task.CostEstimate, 1e-9)
+	assert.Equal(t, task.CostEstimate, task.Metadata["cost_estimate"])
+
+	// the estimate goes out with the processing update
+	messages := sentMessages(t, w)
+	require.NotEmpty(t, messages)
+	var update Tasukete
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &update))
+	assert.Equal(t, StatusProcessing, update.Status())
+	assert.InDelta(t, want, update.Metadata["cost_estimate"], 1e-9)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSPMiddleware_Dashboard(t *testing.T) {
+	config := MockConfig()
+	w := newTestWebSocketClient(t, config)
+	csp := CSPMiddleware(config.HTTPServer.contentSecurityPolicy())
+	server := httptest.NewServer(csp(w.DashboardHandler()))
+	defer server.Close()
+
+	for _, path := range []string{"/dashboard", "/dashboard.js", "/dashboard.css", "/missing"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, defaultCSP, resp.Header.Get("Content-Security-Policy"), path)
+		assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"), path)
+	}
+}
+
+func TestHTTPServerConfig_ContentSecurityPolicy(t *testing.T) {
+	assert.Equal(t, defaultCSP, HTTPServerConfig{}.contentSecurityPolicy())
+	assert.Equal(t, "default-src 'none'", HTTPServerConfig{CSP: "default-src 'none'"}.contentSecurityPolicy())
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// dashboardPromptLimit is how many characters of a prompt the dashboard shows
+	dashboardPromptLimit = 60
+	// dashboardRecentTasks is how many completed tasks the dashboard lists
+	dashboardRecentTasks = 10
+	// taskWorkers is the number of tasks the client runs at once
+	taskWorkers = 1
+)
+
+// The dashboard's script and style are separate same-origin files so the
+// default Content-Security-Policy lets them run
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+//go:embed dashboard.js
+var dashboardScript []byte
+
+//go:embed dashboard.css
+var dashboardStyle []byte
+
+// DashboardStatus is the queue snapshot behind the dashboard
+type DashboardStatus struct {
+	QueueDepth        int             `json:"queue_depth"`
+	QueueCapacity     int             `json:"queue_capacity"`
+	InFlight          []InFlightTask  `json:"in_flight"`
+	RecentCompletions []CompletedTask `json:"recent_completions"` // newest first
+	Workers           int             `json:"workers"`
+	WorkerUtilization float64         `json:"worker_utilization"` // busy workers / workers, 0-1
+}
+
+// InFlightTask is a task the worker is running
+type InFlightTask struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Model     string    `json:"model"` // empty until the model is chosen
+	Prompt    string    `json:"prompt"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// CompletedTask is a task the worker finished, successfully or not
+type CompletedTask struct {
+	UUID        uuid.UUID  `json:"uuid"`
+	Model       string     `json:"model"`
+	Prompt      string     `json:"prompt"`
+	Status      TaskStatus `json:"status"`
+	DurationMs  int64      `json:"duration_ms"`
+	CompletedAt time.Time  `json:"completed_at"`
+}
+
+// DashboardModel is a configured model as listed at /models, with its
+// generation stats since start
+type DashboardModel struct {
+	ID     int    `json:"id"` // the task model number
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+
+	Requests     int64   `json:"requests"`
+	SuccessRate  float64 `json:"success_rate"` // share of requests that succeeded, 0 before any
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+}
+
+// dashboardPushPaths are pushed along with the dashboard page when
+// http_server.http2_push is enabled
+var dashboardPushPaths = []string{"/dashboard.js", "/dashboard.css", "/models", "/status"}
+
+// dashboardState tracks running and recently finished tasks
+type dashboardState struct {
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]InFlightTask
+	recent   []CompletedTask // oldest first
+}
+
+// taskStarted records a task the worker picked up
+func (w *WebSocketClient) taskStarted(task *Tasukete) {
+	d := &w.dashboard
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inFlight == nil {
+		d.inFlight = make(map[uuid.UUID]InFlightTask)
+	}
+	d.inFlight[task.UUID] = InFlightTask{
+		UUID:      task.UUID,
+		Model:     w.modelName(task.Model),
+		Prompt:    truncate(task.Prompt, dashboardPromptLimit),
+		StartedAt: time.Now(),
+	}
+}
+
+// taskFinished moves a task from in flight to the recent completions
+func (w *WebSocketClient) taskFinished(task *Tasukete) {
+	d := &w.dashboard
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	started, ok := d.inFlight[task.UUID]
+	if !ok {
+		return
+	}
+	delete(d.inFlight, task.UUID)
+
+	d.recent = append(d.recent, CompletedTask{
+		UUID:        task.UUID,
+		Model:       w.modelName(task.Model),
+		Prompt:      started.Prompt,
+		Status:      task.Status(),
+		DurationMs:  time.Since(started.StartedAt).Milliseconds(),
+		CompletedAt: time.Now(),
+	})
+	if len(d.recent) > dashboardRecentTasks {
+		d.recent = d.recent[len(d.recent)-dashboardRecentTasks:]
+	}
+}
+
+// modelName returns the configured name of model ID id, "" when out of range
+func (w *WebSocketClient) modelName(id int) string {
+	if id <= 0 || id > len(w.config.Models) {
+		return ""
+	}
+	return w.config.Models[id-1].Name
+}
+
+// DashboardStatus snapshots the task queue
+func (w *WebSocketClient) DashboardStatus() DashboardStatus {
+	status := DashboardStatus{
+		QueueDepth:        w.queueDepth(),
+		QueueCapacity:     cap(w.queue),
+		InFlight:          []InFlightTask{},
+		RecentCompletions: []CompletedTask{},
+		Workers:           taskWorkers,
+	}
+
+	d := &w.dashboard
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, task := range d.inFlight {
+		task.ElapsedMs = now.Sub(task.StartedAt).Milliseconds()
+		status.InFlight = append(status.InFlight, task)
+	}
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		status.RecentCompletions = append(status.RecentCompletions, d.recent[i])
+	}
+	status.WorkerUtilization = min(float64(len(d.inFlight))/taskWorkers, 1)
+	return status
+}
+
+// DashboardModels lists the configured models with their stats
+func (w *WebSocketClient) DashboardModels() []DashboardModel {
+	stats := make(map[string]ModelStats)
+	for _, s := range w.client.GetModelStats() {
+		stats[s.Name] = s
+	}
+	models := make([]DashboardModel, len(w.config.Models))
+	for i, m := range w.config.Models {
+		s := stats[m.Name]
+		models[i] = DashboardModel{
+			ID:     i + 1,
+			Name:   m.Name,
+			Width:  m.Width,
+			Height: m.Height,
+
+			Requests:     s.TotalRequests,
+			SuccessRate:  s.SuccessRate(),
+			AvgLatencyMs: s.AvgLatencyMs(),
+		}
+	}
+	return models
+}
+
+// DashboardHandler serves the queue dashboard at GET /dashboard with its
+// script and style, the JSON it renders at GET /status, the configured models at GET /models and the
+// tasks being handled at GET /tasks
+func (w *WebSocketClient) DashboardHandler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, handler := range w.dashboardRoutes() {
+		mux.Handle(pattern, handler)
+	}
+	return mux
+}
+
+// dashboardRoutes maps the ServeMux patterns DashboardHandler serves to
+// their handlers
+func (w *WebSocketClient) dashboardRoutes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /status": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(rw).Encode(w.DashboardStatus())
+		}),
+		"GET /models": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(rw).Encode(w.DashboardModels())
+		}),
+		"GET /tasks": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(rw).Encode(w.RegisteredTasks())
+		}),
+		"GET /dashboard": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if w.config.HTTPServer.HTTP2Push {
+				w.pushDashboardData(rw)
+			}
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			rw.Write(dashboardPage)
+		}),
+		"GET /dashboard.js": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			rw.Write(dashboardScript)
+		}),
+		"GET /dashboard.css": http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/css; charset=utf-8")
+			rw.Write(dashboardStyle)
+		}),
+	}
+}
+
+// pushDashboardData pushes the JSON the dashboard fetches. Clients that
+// can't take pushes, including every HTTP/1 client, just fetch it later.
+func (w *WebSocketClient) pushDashboardData(rw http.ResponseWriter) {
+	pusher, ok := rw.(http.Pusher)
+	if !ok {
+		return
+	}
+	for _, path := range dashboardPushPaths {
+		if err := pusher.Push(path, nil); err != nil {
+			w.logger.Debug("Dashboard push skipped", "path", path, "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,25 @@
+function row(cells) {
+  const tr = document.createElement("tr");
+  cells.forEach((text, i) => {
+    const td = document.createElement("td");
+    td.textContent = text;
+    if (i === 0) td.className = "uuid";
+    tr.appendChild(td);
+  });
+  return tr;
+}
+
+function seconds(ms) {
+  return (ms / 1000).toFixed(1) + " s";
+}
+
+fetch("status").then(r => r.json()).then(s => {
+  document.getElementById("depth").textContent = s.queue_depth;
+  document.getElementById("capacity").textContent = s.queue_capacity;
+  document.getElementById("utilization").textContent = Math.round(s.worker_utilization * 100) + "%";
+  document.getElementById("workers").textContent = s.workers;
+  document.getElementById("in-flight").replaceChildren(...s.in_flight.map(t =>
+    row([t.uuid, t.model || "(auto)", t.prompt, seconds(t.elapsed_ms)])));
+  document.getElementById("recent").replaceChildren(...s.recent_completions.map(t =>
+    row([t.uuid, t.model, t.prompt, t.status, seconds(t.duration_ms)])));
+});
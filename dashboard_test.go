@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getStatus(t *testing.T, server *httptest.Server) map[string]any {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/status")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var status map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	return status
+}
+
+func TestDashboardHandler_Status(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(200 * time.Millisecond)
+	backend := mock.Start()
+	defer backend.Close()
+
+	config := MockConfig()
+	useMockAPI(config, backend)
+	config.Models[0].Name = "SD"
+	w := newTestWebSocketClient(t, config)
+	server := httptest.NewServer(w.DashboardHandler())
+	defer server.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	first := NewTasukete(TTI, strings.Repeat("a very long prompt ", 10), 1)
+	w.enqueueTask(context.Background(), nil, first)
+	w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "second", 1))
+	require.Eventually(t, func() bool { return w.inFlight.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	status := getStatus(t, server)
+	assert.Equal(t, float64(1), status["queue_depth"])
+	assert.Equal(t, float64(cap(w.queue)), status["queue_capacity"])
+	assert.Equal(t, float64(1), status["workers"])
+	assert.Equal(t, float64(1), status["worker_utilization"])
+	assert.Equal(t, []any{}, status["recent_completions"])
+
+	inFlight := status["in_flight"].([]any)
+	require.Len(t, inFlight, 1)
+	task := inFlight[0].(map[string]any)
+	assert.Equal(t, first.UUID.String(), task["uuid"])
+	assert.Equal(t, "SD", task["model"])
+	assert.Len(t, []rune(task["prompt"].(string)), dashboardPromptLimit)
+	assert.IsType(t, float64(0), task["elapsed_ms"])
+	assert.Contains(t, task, "started_at")
+
+	require.Eventually(t, func() bool { return len(w.queue) == 0 && w.inFlight.Load() == 0 }, 5*time.Second, 10*time.Millisecond)
+	status = getStatus(t, server)
+	assert.Equal(t, float64(0), status["worker_utilization"])
+	assert.Equal(t, []any{}, status["in_flight"])
+
+	recent := status["recent_completions"].([]any)
+	require.Len(t, recent, 2)
+	latest := recent[0].(map[string]any)
+	assert.Equal(t, "second", latest["prompt"], "newest first")
+	assert.Equal(t, "COMPLETED", latest["status"])
+	assert.Equal(t, "SD", latest["model"])
+	assert.GreaterOrEqual(t, latest["duration_ms"], float64(200))
+	assert.Contains(t, latest, "completed_at")
+}
+
+func TestDashboardStatus_KeepsLastTen(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	var tasks []*Tasukete
+	for range 12 {
+		task := NewTasukete(TTI, "a cat", 1)
+		w.taskStarted(task)
+		w.taskFinished(task)
+		tasks = append(tasks, task)
+	}
+
+	recent := w.DashboardStatus().RecentCompletions
+	require.Len(t, recent, dashboardRecentTasks)
+	assert.Equal(t, tasks[11].UUID, recent[0].UUID)
+	assert.Equal(t, tasks[2].UUID, recent[9].UUID)
+}
+
+func TestDashboardHandler_Page(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	server := httptest.NewServer(w.DashboardHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/dashboard")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `<meta http-equiv="refresh" content="5">`)
+	assert.Contains(t, string(body), `<script src="dashboard.js"></script>`)
+	assert.NotContains(t, string(body), "<script>", "no inline script for the CSP to block")
+	assert.NotContains(t, string(body), "<style>", "no inline style for the CSP to block")
+
+	for path, contentType := range map[string]string{
+		"/dashboard.js":  "text/javascript; charset=utf-8",
+		"/dashboard.css": "text/css; charset=utf-8",
+	} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, contentType, resp.Header.Get("Content-Type"), path)
+		assert.NotEmpty(t, body, path)
+	}
+}
+
+// pushRecorder is a ResponseWriter that supports HTTP/2 push
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+	err    error
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+func TestDashboardHandler_HTTP2Push(t *testing.T) {
+	config := MockConfig()
+	config.HTTPServer.HTTP2Push = true
+	handler := newTestWebSocketClient(t, config).DashboardHandler()
+
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/dashboard.js", "/dashboard.css", "/models", "/status"}, rec.pushed)
+
+	// push refused, as for an HTTP/1 client
+	rec = &pushRecorder{ResponseRecorder: httptest.NewRecorder(), err: http.ErrNotSupported}
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<h1>Task queue</h1>")
+
+	// disabled
+	config.HTTPServer.HTTP2Push = false
+	rec = &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	newTestWebSocketClient(t, config).DashboardHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard", nil))
+	assert.Empty(t, rec.pushed)
+}
+
+func TestDashboardHandler_Models(t *testing.T) {
+	config := MockConfig()
+	config.Models[0].Name = "SD"
+	config.Models = append(config.Models, ModelConfig{Name: "unused", String: "unused"})
+	w := newTestWebSocketClient(t, config)
+	w.client.recordModelStats("SD", 2*time.Second, nil)
+	w.client.recordModelStats("SD", 4*time.Second, nil)
+	w.client.recordModelStats("SD", 3*time.Second, errors.New("timeout"))
+	w.client.recordModelStats("SD", 3*time.Second, nil)
+	handler := w.DashboardHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/models", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var models []DashboardModel
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &models))
+	require.Len(t, models, len(config.Models))
+	assert.Equal(t, 1, models[0].ID)
+	assert.Equal(t, "SD", models[0].Name)
+	assert.Equal(t, int64(4), models[0].Requests)
+	assert.Equal(t, 0.75, models[0].SuccessRate)
+	assert.Equal(t, int64(3000), models[0].AvgLatencyMs)
+
+	assert.Equal(t, DashboardModel{ID: 2, Name: "unused"}, models[1], "no stats before the first request")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// debugBodyLimit caps how much of each body DebugTransport logs
+const debugBodyLimit = 4 << 10
+
+// redactedHeaders are replaced before headers are logged
+var redactedHeaders = []string{"Authorization", "X-Signature"}
+
+// DebugTransport logs the full request and response of every round trip
+// at debug level, through the request context's logger when it has one.
+// Bodies are logged up to debugBodyLimit bytes and passed on untouched.
+type DebugTransport struct {
+	Base   http.RoundTripper
+	Logger *slog.Logger
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	if !t.Logger.Enabled(ctx, slog.LevelDebug) {
+		return base.RoundTrip(req)
+	}
+	logger := loggerFromContext(ctx, t.Logger)
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	logger.DebugContext(ctx, "HTTP request",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"headers", redactHeaders(req.Header),
+		"body", debugBody(reqBody),
+	)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		logger.DebugContext(ctx, "HTTP request failed", "method", req.Method, "url", req.URL.String(), "error", err)
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	logger.DebugContext(ctx, "HTTP response",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"status", resp.StatusCode,
+		"headers", redactHeaders(resp.Header),
+		"body", debugBody(respBody),
+	)
+	return resp, nil
+}
+
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, "REDACTED")
+		}
+	}
+	return h
+}
+
+func debugBody(body []byte) string {
+	if len(body) > debugBodyLimit {
+		return string(body[:debugBodyLimit]) + "..."
+	}
+	return string(body)
+}
+
+// withDebugTransport wraps the client's transport with DebugTransport when
+// the logger is at debug level
+func withDebugTransport(client *http.Client, logger *slog.Logger) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	client.Transport = &DebugTransport{Base: client.Transport, Logger: logger}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugTransport_LogsRoundTrip(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := &http.Client{}
+	withDebugTransport(client, logger)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/API/GetNewSession", strings.NewReader("{}"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Signature", "deadbeef")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var session SessionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	assert.Equal(t, "mock-session-1", session.SessionID)
+
+	var entries []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(line, &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 2)
+
+	request, response := entries[0], entries[1]
+	assert.Equal(t, server.URL+"/API/GetNewSession", request["url"])
+	assert.Equal(t, "{}", request["body"])
+	assert.NotContains(t, logs.String(), "secret")
+	assert.NotContains(t, logs.String(), "deadbeef")
+
+	assert.Equal(t, server.URL+"/API/GetNewSession", response["url"])
+	assert.Equal(t, float64(http.StatusOK), response["status"])
+	assert.Contains(t, response["body"], "mock-session-1")
+}
+
+func TestDebugTransport_OnlyAtDebugLevel(t *testing.T) {
+	config := MockConfig()
+
+	info := slog.New(slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	assert.IsType(t, &http.Transport{}, NewClient(config, info).httpClient.Transport)
+
+	debug := slog.New(slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	assert.IsType(t, &DebugTransport{}, NewClient(config, debug).httpClient.Transport)
+}
+
+func TestDebugBody_Truncates(t *testing.T) {
+	body := debugBody(bytes.Repeat([]byte("a"), debugBodyLimit+10))
+	assert.Equal(t, debugBodyLimit+len("..."), len(body))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrDuplicateUpload is returned when an identical image was uploaded recently
+type ErrDuplicateUpload struct {
+	Hash string
+}
+
+func (e ErrDuplicateUpload) Error() string {
+	return fmt.Sprintf("duplicate upload skipped: %s", e.Hash)
+}
+
+// UploadDeduplicator remembers the hashes of the last size uploads
+type UploadDeduplicator struct {
+	mu     sync.Mutex
+	size   int
+	ring   []string
+	next   int
+	hashes map[string]struct{}
+}
+
+func NewUploadDeduplicator(size int) *UploadDeduplicator {
+	return &UploadDeduplicator{
+		size:   size,
+		ring:   make([]string, 0, size),
+		hashes: make(map[string]struct{}, size),
+	}
+}
+
+func hashImage(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SeenOrAdd reports whether hash is in the window, recording it when it
+// isn't and evicting the oldest hash once the window is full
+func (d *UploadDeduplicator) SeenOrAdd(hash string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.hashes[hash]; ok {
+		return true
+	}
+	if len(d.ring) < d.size {
+		d.ring = append(d.ring, hash)
+	} else {
+		delete(d.hashes, d.ring[d.next])
+		d.ring[d.next] = hash
+		d.next = (d.next + 1) % d.size
+	}
+	d.hashes[hash] = struct{}{}
+	return false
+}
+
+// Forget drops hash from the window so a failed upload can be retried
+func (d *UploadDeduplicator) Forget(hash string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.hashes[hash]; !ok {
+		return
+	}
+	delete(d.hashes, hash)
+	ordered := make([]string, 0, d.size)
+	ordered = append(ordered, d.ring[d.next:]...)
+	ordered = append(ordered, d.ring[:d.next]...)
+	d.ring = slices.DeleteFunc(ordered, func(h string) bool { return h == hash })
+	d.next = 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadGeneratedImage_SkipsDuplicates(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.StartTLS()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockUploads(config, server)
+	config.Upload.DedupWindow = 8
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	require.NoError(t, client.UploadGeneratedImage([]byte("same image")))
+
+	err := client.UploadGeneratedImage([]byte("same image"))
+	var dup ErrDuplicateUpload
+	require.True(t, errors.As(err, &dup))
+	assert.Equal(t, hashImage([]byte("same image")), dup.Hash)
+
+	require.NoError(t, client.UploadGeneratedImage([]byte("other image")))
+	assert.Equal(t, 2, mock.CallCount("/image"))
+}
+
+func TestUploadDeduplicator_Window(t *testing.T) {
+	d := NewUploadDeduplicator(2)
+	assert.False(t, d.SeenOrAdd("a"))
+	assert.False(t, d.SeenOrAdd("b"))
+	assert.True(t, d.SeenOrAdd("a"))
+
+	assert.False(t, d.SeenOrAdd("c")) // evicts a
+	assert.True(t, d.SeenOrAdd("b"))
+	assert.True(t, d.SeenOrAdd("c"))
+	assert.False(t, d.SeenOrAdd("a")) // evicts b
+	assert.False(t, d.SeenOrAdd("b")) // evicts c
+	assert.Len(t, d.hashes, 2)
+}
+
+func TestUploadDeduplicator_Forget(t *testing.T) {
+	d := NewUploadDeduplicator(2)
+	d.SeenOrAdd("a")
+	d.SeenOrAdd("b")
+	d.SeenOrAdd("c") // evicts a
+
+	d.Forget("b")
+	assert.False(t, d.SeenOrAdd("b"))
+	assert.True(t, d.SeenOrAdd("c"))
+	assert.False(t, d.SeenOrAdd("d")) // evicts c, the oldest
+	assert.True(t, d.SeenOrAdd("b"))
+	assert.Len(t, d.hashes, 2)
+}
+
+func TestUploadDeduplicator_Concurrent(t *testing.T) {
+	d := NewUploadDeduplicator(8)
+	var wg sync.WaitGroup
+	var fresh atomic.Int32
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !d.SeenOrAdd("same") {
+				fresh.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fresh.Load())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultDialTimeout         = 10 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// dialTimeout bounds opening the TCP connection to the task server
+func (c ServerConfig) dialTimeout() time.Duration {
+	if c.DialTimeout > 0 {
+		return time.Duration(c.DialTimeout) * time.Second
+	}
+	return defaultDialTimeout
+}
+
+// tlsHandshakeTimeout bounds the TLS handshake and WebSocket upgrade that
+// follow the dial, so a host that accepts connections but never answers
+// can't hang connect
+func (c ServerConfig) tlsHandshakeTimeout() time.Duration {
+	if c.TLSHandshakeTimeout > 0 {
+		return time.Duration(c.TLSHandshakeTimeout) * time.Second
+	}
+	return defaultTLSHandshakeTimeout
+}
+
+// dialer connects to the task server
+func (c ServerConfig) dialer() *websocket.Dialer {
+	netDialer := &net.Dialer{Timeout: c.dialTimeout()}
+	return &websocket.Dialer{
+		NetDialContext:   netDialer.DialContext,
+		HandshakeTimeout: c.tlsHandshakeTimeout(),
+		TLSClientConfig:  c.tlsConfig(),
+		Subprotocols:     c.subprotocols(),
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSilentServer accepts TCP connections and never answers them
+func newSilentServer(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	return listener
+}
+
+func TestServerConfig_Timeouts(t *testing.T) {
+	var config ServerConfig
+	assert.Equal(t, defaultDialTimeout, config.dialTimeout())
+	assert.Equal(t, defaultTLSHandshakeTimeout, config.tlsHandshakeTimeout())
+
+	config.DialTimeout = 3
+	config.TLSHandshakeTimeout = 5
+	dialer := config.dialer()
+	assert.Equal(t, 3*time.Second, config.dialTimeout())
+	assert.Equal(t, 5*time.Second, dialer.HandshakeTimeout)
+	assert.NotNil(t, dialer.NetDialContext)
+}
+
+func TestConnect_HandshakeTimeout(t *testing.T) {
+	listener := newSilentServer(t)
+	config := MockConfig()
+	config.Server.Host, config.Server.Port, _ = net.SplitHostPort(listener.Addr().String())
+	config.Server.TLSHandshakeTimeout = 1
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewWebSocketClient(config, NewClient(config, logger), logger, WithConnectionLimiter(NewConnectionLimiter(1)))
+
+	start := time.Now()
+	err := w.connect()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dial error")
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrDryRun is returned by generation calls while Client.DryRun is set
+var ErrDryRun = errors.New("dry run: request not sent")
+
+// dryRunSessionID stands in for a real session in previewed request bodies
+const dryRunSessionID = "dry-run"
+
+// GenerateImageDryRun returns the GenerateText2Image body that GenerateImage
+// would send for prompt and model, without contacting the API
+func (c *Client) GenerateImageDryRun(ctx context.Context, prompt string, modelID int) (json.RawMessage, error) {
+	return c.dryRunBody(GenerateRequest{Prompt: prompt, ModelID: modelID})
+}
+
+func (c *Client) dryRunBody(req GenerateRequest) (json.RawMessage, error) {
+	if req.ModelID <= 0 || req.ModelID > len(c.config.Models) {
+		return nil, fmt.Errorf("invalid modelID: %d", req.ModelID)
+	}
+
+	if c.filter != nil {
+		if ok, reason := c.filter.Allow(req.Prompt); !ok {
+			return nil, ErrPromptRejected{Reason: reason}
+		}
+	}
+
+	prompt, err := c.checkPromptLength(c.logger, req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	req.Prompt = prompt
+
+	model := c.config.Models[req.ModelID-1]
+	if err := validateDimensions(model.Width, model.Height); err != nil {
+		return nil, fmt.Errorf("model %q: %w", model.Name, err)
+	}
+
+	params, err := c.generationParams(req, model)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(params.requestBody(dryRunSessionID))
+}
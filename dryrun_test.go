@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dryRunConfig() *Config {
+	config := MockConfig()
+	config.Models = []ModelConfig{{
+		Name:      "SD",
+		String:    "OfficialStableDiffusion/sd_xl_base_1.0",
+		Width:     1024,
+		Height:    768,
+		Steps:     4,
+		Cfgscale:  1.5,
+		LoraStack: []LoraEntry{{Name: "add_detail", Weight: 0.6}, {Name: "flat_colors", Weight: 1}},
+		Options:   map[string]any{"sampler": "euler_a"},
+	}}
+	return config
+}
+
+func TestGenerateImageDryRun_Golden(t *testing.T) {
+	client := NewClient(dryRunConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	body, err := client.GenerateImageDryRun(context.Background(), "a cat in a hat", 1)
+	require.NoError(t, err)
+
+	golden, err := os.ReadFile("testdata/dryrun_golden.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(golden), string(body))
+}
+
+func TestGenerateImageDryRun_InvalidDimensions(t *testing.T) {
+	config := dryRunConfig()
+	config.Models[0].Width = 1020
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := client.GenerateImageDryRun(context.Background(), "a cat", 1)
+	assert.ErrorContains(t, err, "multiples of 8")
+
+	_, err = client.GenerateImageDryRun(context.Background(), "a cat", 2)
+	assert.Error(t, err)
+}
+
+// TestGenerate_DryRunSkipsAPI verifies DryRun never reaches the server
+func TestGenerate_DryRunSkipsAPI(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := dryRunConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	client.DryRun = true
+
+	_, err := client.GenerateImage("a cat", 1)
+	assert.True(t, errors.Is(err, ErrDryRun))
+	assert.Zero(t, mock.CallCount("/API/GetNewSession"))
+	assert.Zero(t, mock.CallCount("/API/GenerateText2Image"))
+}
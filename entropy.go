@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+)
+
+// ErrLowQualityImage is returned when a generation is too uniform to be
+// anything but a blank or failed image
+var ErrLowQualityImage = errors.New("low quality image")
+
+// defaultMinEntropy is the entropy threshold when min_entropy_threshold is unset
+const defaultMinEntropy = 0.1
+
+// minEntropy returns the entropy below which images are rejected, 0 when
+// the check is disabled
+func (c APIConfig) minEntropy() float64 {
+	switch {
+	case c.MinEntropyThreshold < 0:
+		return 0
+	case c.MinEntropyThreshold == 0:
+		return defaultMinEntropy
+	default:
+		return c.MinEntropyThreshold
+	}
+}
+
+// ScoreImageEntropy returns the Shannon entropy of the image's luminance
+// histogram scaled to [0,1], where 0 is a single flat color and 1 uses
+// every grey level equally
+func ScoreImageEntropy(imageData []byte) (float64, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var histogram [256]int
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			histogram[color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y]++
+		}
+	}
+
+	total := float64(bounds.Dx() * bounds.Dy())
+	if total == 0 {
+		return 0, nil
+	}
+	var entropy float64
+	for _, n := range histogram {
+		if n > 0 {
+			p := float64(n) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy / 8, nil // 8 bits is the most 256 levels can carry
+}
+
+// checkImageQuality rejects images whose entropy is under the threshold.
+// Images that can't be decoded are passed through, as there is nothing to
+// score.
+func (c *Client) checkImageQuality(logger *slog.Logger, images [][]byte) error {
+	threshold := c.config.API.minEntropy()
+	if threshold == 0 {
+		return nil
+	}
+	for i, data := range images {
+		score, err := ScoreImageEntropy(data)
+		if err != nil {
+			logger.Debug("Skipping entropy check", "image", i, "error", err)
+			continue
+		}
+		if score < threshold {
+			return fmt.Errorf("%w: image %d has entropy %.3f, below %.3f", ErrLowQualityImage, i, score, threshold)
+		}
+	}
+	return nil
+}
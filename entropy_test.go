@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreImageEntropy(t *testing.T) {
+	black, err := ScoreImageEntropy(solidPNG(t, 32, 32, color.Black))
+	require.NoError(t, err)
+	assert.Less(t, black, defaultMinEntropy)
+	assert.Zero(t, black)
+
+	gradient, err := ScoreImageEntropy(mockImage)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, gradient, 1e-9) // 256 pixels, one per grey level
+
+	_, err = ScoreImageEntropy([]byte("not an image"))
+	assert.Error(t, err)
+}
+
+func TestGenerate_RetriesBlankImages(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	blank := solidPNG(t, 32, 32, color.Black)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 1}
+	client := NewClient(config, logger)
+
+	t.Run("retries until the image has content", func(t *testing.T) {
+		mock.SetNextImageResponse(blank)
+		var retries []error
+		ctx := withRetryHook(context.Background(), func(err error) { retries = append(retries, err) })
+
+		images, _, err := client.generate(ctx, GenerateRequest{Prompt: "test prompt", ModelID: 1})
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{mockImage}, images)
+		require.Len(t, retries, 1)
+		assert.ErrorIs(t, retries[0], ErrLowQualityImage)
+	})
+
+	t.Run("fails once retries run out", func(t *testing.T) {
+		client.config.API.Retry.MaxRetries = 0
+		defer func() { client.config.API.Retry.MaxRetries = 1 }()
+		mock.SetNextImageResponse(blank)
+
+		_, _, err := client.generate(context.Background(), GenerateRequest{Prompt: "test prompt", ModelID: 1})
+		assert.True(t, errors.Is(err, ErrLowQualityImage), "got %v", err)
+	})
+
+	t.Run("negative threshold disables the check", func(t *testing.T) {
+		client.config.API.MinEntropyThreshold = -1
+		defer func() { client.config.API.MinEntropyThreshold = 0 }()
+		mock.SetNextImageResponse(blank)
+
+		images, _, err := client.generate(context.Background(), GenerateRequest{Prompt: "test prompt", ModelID: 1})
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{blank}, images)
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxErrorBody caps how much of an API response body is kept in errors
+const maxErrorBody = 512
+
+// APIError is SwarmUI's structured error response,
+// {"error": "...", "error_id": "..."}, which it sends on non-200 statuses
+type APIError struct {
+	StatusCode int
+	Message    string
+	ErrorID    string
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorID != "" {
+		return fmt.Sprintf("API error %d (%s): %s", e.StatusCode, e.ErrorID, e.Message)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// parseAPIError reads resp's body into an APIError
+func parseAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return newAPIError(resp.StatusCode, body)
+}
+
+// newAPIError decodes body as a SwarmUI error, falling back to the raw text
+// for bodies that aren't one
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	var parsed struct {
+		Error   string `json:"error"`
+		ErrorID string `json:"error_id"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && (parsed.Error != "" || parsed.ErrorID != "") {
+		apiErr.Message = parsed.Error
+		apiErr.ErrorID = parsed.ErrorID
+	} else {
+		apiErr.Message = truncate(string(body), maxErrorBody)
+	}
+	return apiErr
+}
+
+// SessionError is returned when a new SwarmUI session can't be obtained
+type SessionError struct {
+	StatusCode int
+	Body       string
+	Err        error // *APIError, or a transport or decoding failure
+}
+
+func (e SessionError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("session request failed: %v", e.Err)
+	}
+	return fmt.Sprintf("session request returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e SessionError) Unwrap() error { return e.Err }
+
+// GenerationError is returned when SwarmUI fails to generate an image
+type GenerationError struct {
+	SessionID  string
+	ModelName  string
+	StatusCode int
+	Body       string
+	Err        error // ErrModelNotLoaded, ErrSessionExpired, *APIError, or a transport or decoding failure
+
+	RetryAfter time.Duration // how long a 429 asked us to wait, 0 when it didn't say
+}
+
+func (e GenerationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("generation with %s failed: %v", e.ModelName, e.Err)
+	}
+	return fmt.Sprintf("generation with %s returned status %d: %s", e.ModelName, e.StatusCode, e.Body)
+}
+
+func (e GenerationError) Unwrap() error { return e.Err }
+
+// DownloadError is returned when a generated image can't be fetched
+type DownloadError struct {
+	URL        string
+	StatusCode int
+	Err        error // *APIError, or a transport failure
+}
+
+func (e DownloadError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("download of %s failed: %v", e.URL, e.Err)
+	}
+	return fmt.Sprintf("download of %s returned status %d", e.URL, e.StatusCode)
+}
+
+func (e DownloadError) Unwrap() error { return e.Err }
+
+// UploadError is returned when an upload backend rejects an image
+type UploadError struct {
+	StatusCode int
+	Body       string
+	Err        error // transport or backend failure, if any
+}
+
+func (e UploadError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("upload failed: %v", e.Err)
+	}
+	return fmt.Sprintf("upload returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (e UploadError) Unwrap() error { return e.Err }
+
+// WebSocket protocol error codes, carried by WebSocketProtocolError
+const (
+	ProtocolErrMissingAuthSuccess = iota + 1 // the server answered auth with something other than auth_success
+	ProtocolErrUnexpectedSequence            // a message arrived that isn't valid at this point, e.g. auth_success after auth
+	ProtocolErrMalformedPayload              // a message or its payload couldn't be decoded
+)
+
+// WebSocketProtocolError is a protocol violation by the task server that
+// ends the connection
+type WebSocketProtocolError struct {
+	Code        int
+	MessageType string
+	Details     string
+}
+
+func (e *WebSocketProtocolError) Error() string {
+	if e.MessageType != "" {
+		return fmt.Sprintf("protocol error %d on %q message: %s", e.Code, e.MessageType, e.Details)
+	}
+	return fmt.Sprintf("protocol error %d: %s", e.Code, e.Details)
+}
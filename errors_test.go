@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newErrorTestClient(t *testing.T, mock *MockSwarmUIServer) *Client {
+	t.Helper()
+	server := mock.Start()
+	t.Cleanup(server.Close)
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "Default"
+	return NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestSessionError(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetFailOnNthRequest(1)
+	client := newErrorTestClient(t, mock)
+
+	_, err := client.GenerateImage("test prompt", 1)
+	var sessionErr SessionError
+	require.ErrorAs(t, err, &sessionErr)
+	assert.Equal(t, http.StatusInternalServerError, sessionErr.StatusCode)
+	assert.Contains(t, sessionErr.Body, "injected failure")
+}
+
+func TestGenerationError(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(m *MockSwarmUIServer)
+		status    int
+		notLoaded bool
+	}{
+		{
+			name:   "server error",
+			setup:  func(m *MockSwarmUIServer) { m.SetFailOnNthRequest(2) },
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:      "model not loaded",
+			setup:     func(m *MockSwarmUIServer) { m.SetUnavailableModels("default_model") },
+			status:    http.StatusOK,
+			notLoaded: true,
+		},
+		{
+			name:   "no images",
+			setup:  func(m *MockSwarmUIServer) { m.SetErrorOnNthRequest(2, http.StatusOK, `{"images":[]}`) },
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockSwarmUIServer()
+			tt.setup(mock)
+			client := newErrorTestClient(t, mock)
+
+			_, err := client.GenerateImage("test prompt", 1)
+			var genErr GenerationError
+			require.ErrorAs(t, err, &genErr)
+			assert.Equal(t, "mock-session-1", genErr.SessionID)
+			assert.Equal(t, "Default", genErr.ModelName)
+			assert.Equal(t, tt.status, genErr.StatusCode)
+			assert.Equal(t, tt.notLoaded, errors.Is(err, ErrModelNotLoaded))
+		})
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int // request to fail: 1 session, 2 generation, 3 download
+		status  int
+		body    string
+		message string
+		errorID string
+	}{
+		{
+			name:    "bad request",
+			n:       2,
+			status:  http.StatusBadRequest,
+			body:    `{"error": "Invalid value for parameter steps", "error_id": "invalid_param"}`,
+			message: "Invalid value for parameter steps",
+			errorID: "invalid_param",
+		},
+		{
+			name:    "server error",
+			n:       1,
+			status:  http.StatusInternalServerError,
+			body:    `{"error": "Backend crashed"}`,
+			message: "Backend crashed",
+		},
+		{
+			name:    "plain text body",
+			n:       3,
+			status:  http.StatusNotFound,
+			body:    "not found",
+			message: "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockSwarmUIServer()
+			mock.SetErrorOnNthRequest(tt.n, tt.status, tt.body)
+			client := newErrorTestClient(t, mock)
+
+			_, err := client.GenerateImage("test prompt", 1)
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, tt.message, apiErr.Message)
+			assert.Equal(t, tt.errorID, apiErr.ErrorID)
+		})
+	}
+}
+
+func TestHandleTTITask_LogsErrorID(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetErrorOnNthRequest(2, http.StatusBadRequest, `{"error": "bad steps", "error_id": "invalid_param"}`)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	var logs bytes.Buffer
+	w := newTestWebSocketClient(t, config, WithWebSocketLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	w.handleTTITask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+	assert.Contains(t, logs.String(), "error_id=invalid_param")
+}
+
+func TestDownloadError(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetFailOnNthRequest(3)
+	client := newErrorTestClient(t, mock)
+
+	_, err := client.GenerateImage("test prompt", 1)
+	var downloadErr DownloadError
+	require.ErrorAs(t, err, &downloadErr)
+	assert.Equal(t, http.StatusInternalServerError, downloadErr.StatusCode)
+	assert.Contains(t, downloadErr.URL, "/images/mock-1-1.png")
+}
+
+func TestUploadError(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetFailOnNthRequest(1)
+	server := mock.StartTLS()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockUploads(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err := client.UploadGeneratedImage([]byte("test image data"))
+	var uploadErr UploadError
+	require.ErrorAs(t, err, &uploadErr)
+	assert.Equal(t, http.StatusInternalServerError, uploadErr.StatusCode)
+	assert.Contains(t, uploadErr.Body, "injected failure")
+}
+
+func TestS3UploadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>denied</Message></Error>`))
+	}))
+	defer server.Close()
+
+	uploader := NewS3Uploader(S3Config{
+		Bucket:          "images",
+		Region:          "us-east-1",
+		Endpoint:        server.URL,
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	})
+
+	_, err := uploader.Upload(context.Background(), []byte("test image data"), "cat.png")
+	var uploadErr UploadError
+	require.ErrorAs(t, err, &uploadErr)
+	assert.Equal(t, http.StatusForbidden, uploadErr.StatusCode)
+}
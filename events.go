@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const taskEventBuffer = 16
+
+// finishedTaskHistory caps how many terminal events are kept for replay to
+// subscribers that arrive after the task finished
+const finishedTaskHistory = 256
+
+type taskEvent struct {
+	data     []byte
+	terminal bool
+}
+
+// TaskEvents fans out task status changes to per-task subscribers and
+// serves them as server-sent events
+type TaskEvents struct {
+	mu       sync.Mutex
+	subs     map[uuid.UUID][]chan taskEvent
+	finished map[uuid.UUID]taskEvent
+	order    []uuid.UUID // finished tasks, oldest first
+}
+
+func NewTaskEvents() *TaskEvents {
+	return &TaskEvents{
+		subs:     make(map[uuid.UUID][]chan taskEvent),
+		finished: make(map[uuid.UUID]taskEvent),
+	}
+}
+
+// Subscribe returns a channel of status updates for the task, closed once the
+// task reaches a terminal status, and a function to unsubscribe early. A task
+// that already finished gets its terminal update replayed.
+func (e *TaskEvents) Subscribe(id uuid.UUID) (<-chan taskEvent, func()) {
+	ch := make(chan taskEvent, taskEventBuffer)
+
+	e.mu.Lock()
+	if ev, ok := e.finished[id]; ok {
+		e.mu.Unlock()
+		ch <- ev
+		close(ch)
+		return ch, func() {}
+	}
+	e.subs[id] = append(e.subs[id], ch)
+	e.mu.Unlock()
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		subs := e.subs[id]
+		for i, c := range subs {
+			if c == ch {
+				e.subs[id] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(e.subs[id]) == 0 {
+			delete(e.subs, id)
+		}
+	}
+}
+
+// Publish sends the task's current state to its subscribers
+func (e *TaskEvents) Publish(task *Tasukete) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return
+	}
+	ev := taskEvent{
+		data:     data,
+		terminal: task.Status() == StatusCompleted || task.Status() == StatusFailed,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, ch := range e.subs[task.UUID] {
+		select {
+		case ch <- ev:
+		default: // slow subscriber, skip this update
+		}
+		if ev.terminal {
+			close(ch)
+		}
+	}
+	if ev.terminal {
+		delete(e.subs, task.UUID)
+		e.rememberFinished(task.UUID, ev)
+	}
+}
+
+// rememberFinished must be called with e.mu held
+func (e *TaskEvents) rememberFinished(id uuid.UUID, ev taskEvent) {
+	if _, ok := e.finished[id]; !ok {
+		e.order = append(e.order, id)
+	}
+	e.finished[id] = ev
+	if len(e.order) > finishedTaskHistory {
+		delete(e.finished, e.order[0])
+		e.order = e.order[1:]
+	}
+}
+
+// ServeHTTP streams status updates for GET /events/{uuid} until the task finishes
+func (e *TaskEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/events/"))
+	if err != nil {
+		http.Error(w, "invalid task uuid", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := e.Subscribe(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			unsubscribe()
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: status_update\ndata: %s\n\n", ev.data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (w *WebSocketClient) publishStatus(task *Tasukete) {
+	if w.events != nil {
+		w.events.Publish(task)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForSubscriber(t *testing.T, e *TaskEvents, task *Tasukete) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return len(e.subs[task.UUID]) > 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestTaskEvents_StreamsUntilTerminal(t *testing.T) {
+	events := NewTaskEvents()
+	task := NewTasukete(TTI, "a cat", 1)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/events/"+task.UUID.String(), nil)
+	done := make(chan struct{})
+	go func() {
+		events.ServeHTTP(rec, req)
+		close(done)
+	}()
+	waitForSubscriber(t, events, task)
+
+	task.UpdateStatus(StatusProcessing)
+	events.Publish(task)
+	task.UpdateStatus(StatusCompleted)
+	events.Publish(task)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not close after terminal status")
+	}
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Equal(t, 2, strings.Count(body, "event: status_update\n"))
+	processing := strings.Index(body, `"status":"PROCESSING"`)
+	completed := strings.Index(body, `"status":"COMPLETED"`)
+	assert.True(t, processing >= 0 && completed > processing, "unexpected stream: %s", body)
+
+	events.mu.Lock()
+	assert.Empty(t, events.subs)
+	events.mu.Unlock()
+}
+
+func TestTaskEvents_InvalidUUID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewTaskEvents().ServeHTTP(rec, httptest.NewRequest("GET", "/events/not-a-uuid", nil))
+	assert.Equal(t, 400, rec.Code)
+}
+
+func TestTaskEvents_ReplaysFinishedTask(t *testing.T) {
+	events := NewTaskEvents()
+	task := NewTasukete(TTI, "a cat", 1)
+	task.UpdateStatus(StatusProcessing)
+	task.UpdateStatus(StatusCompleted)
+	events.Publish(task)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		events.ServeHTTP(rec, httptest.NewRequest("GET", "/events/"+task.UUID.String(), nil))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("late subscriber was not answered")
+	}
+	assert.Contains(t, rec.Body.String(), `"status":"COMPLETED"`)
+}
+
+func TestTaskEvents_StopsOnDisconnect(t *testing.T) {
+	events := NewTaskEvents()
+	task := NewTasukete(TTI, "a cat", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events/"+task.UUID.String(), nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		events.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	waitForSubscriber(t, events, task)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after the client went away")
+	}
+	events.mu.Lock()
+	assert.Empty(t, events.subs)
+	events.mu.Unlock()
+}
+
+func TestTaskEvents_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewTaskEvents().ServeHTTP(rec, httptest.NewRequest("POST", "/events/"+NewTasukete(TTI, "", 1).UUID.String(), nil))
+	assert.Equal(t, 405, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Allow"))
+}
+
+func TestWebSocketClient_PublishesStatus(t *testing.T) {
+	events := NewTaskEvents()
+	w := newTestWebSocketClient(t, MockConfig(), WithTaskEvents(events))
+	task := NewTasukete(TTI, "a cat", 1)
+
+	ch, unsubscribe := events.Subscribe(task.UUID)
+	defer unsubscribe()
+
+	task.status = StatusProcessing
+	w.sendTaskUpdate(context.Background(), nil, task)
+
+	ev := <-ch
+	assert.Contains(t, string(ev.data), `"status":"PROCESSING"`)
+	assert.False(t, ev.terminal)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// modelsFile is the layout ExportModels writes, a config holding only the
+// models
+type modelsFile struct {
+	Version int           `yaml:"version"`
+	Models  []ModelConfig `yaml:"models"`
+}
+
+// ExportModels writes the configured models to path as a config file that
+// LoadConfig reads back. The file is replaced through a temporary file, so
+// a crash leaves either the old or the new list.
+func (c *Client) ExportModels(path string) error {
+	data, err := yaml.Marshal(modelsFile{Version: currentConfigVersion, Models: c.config.Models})
+	if err != nil {
+		return fmt.Errorf("failed to marshal models: %w", err)
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// assertSameModels compares model lists as YAML, where nil and empty lists
+// are the same
+func assertSameModels(t *testing.T, want, got []ModelConfig) {
+	t.Helper()
+	wantYAML, err := yaml.Marshal(want)
+	require.NoError(t, err)
+	gotYAML, err := yaml.Marshal(got)
+	require.NoError(t, err)
+	assert.Equal(t, string(wantYAML), string(gotYAML))
+}
+
+func TestExportModels(t *testing.T) {
+	config := MockConfig()
+	config.Models = []ModelConfig{
+		{Name: "SD", String: "sd_base", Width: 512, Height: 512, Steps: 20, Cfgscale: 7, LoraStack: []LoraEntry{{Name: "detail", Weight: 0.8}}},
+	}
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	path := filepath.Join(t.TempDir(), "models.yaml")
+
+	require.NoError(t, client.ExportModels(path))
+	first, err := LoadConfig(path)
+	require.NoError(t, err)
+	assertSameModels(t, config.Models, first.Models)
+
+	config.Models = append(config.Models, ModelConfig{
+		Name:           "Flux",
+		String:         "flux_dev",
+		Steps:          28,
+		FallbackModels: []string{"SD"},
+		Capabilities:   []string{"fp16"},
+		Options:        map[string]any{"sampler": "euler"},
+	})
+	require.NoError(t, client.ExportModels(path))
+
+	second, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, second.Models, 2)
+	assertSameModels(t, config.Models, second.Models)
+	assert.Equal(t, currentConfigVersion, second.Version)
+
+	_, err = os.Stat(path + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist, "temporary file is renamed away")
+}
@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// Result filename formats for server.filename_format
+const (
+	FilenameUUID          = "uuid"           // 550e8400-e29b-41d4-a716-446655440000.png
+	FilenameUUIDCompact   = "uuid_compact"   // 550e8400e29b41d4a716446655440000.png
+	FilenameTimestampUUID = "timestamp_uuid" // 20240214T120000Z_550e8400-....png
+)
+
+// filenameTimestamp is ISO 8601 basic format, which avoids the colons of
+// the extended format that Windows rejects in filenames
+const filenameTimestamp = "20060102T150405Z"
+
+func validFilenameFormat(format string) bool {
+	switch format {
+	case "", FilenameUUID, FilenameUUIDCompact, FilenameTimestampUUID:
+		return true
+	default:
+		return false
+	}
+}
+
+// taskFilename names a task's result image, with the extension of its
+// requested output format. Unknown formats fall back to FilenameUUID.
+func taskFilename(task *Tasukete, format string) string {
+	ext := outputExtension(task.OutputMimeType)
+	switch format {
+	case FilenameUUIDCompact:
+		return strings.ReplaceAll(task.UUID.String(), "-", "") + ext
+	case FilenameTimestampUUID:
+		return task.CreatedAt.UTC().Format(filenameTimestamp) + "_" + task.UUID.String() + ext
+	default:
+		return task.UUID.String() + ext
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskFilename(t *testing.T) {
+	task := &Tasukete{
+		UUID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		CreatedAt: time.Date(2024, 2, 14, 15, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60)),
+	}
+	// portable across Windows, macOS and Linux filesystems
+	valid := regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: "550e8400-e29b-41d4-a716-446655440000.png"},
+		{format: FilenameUUID, want: "550e8400-e29b-41d4-a716-446655440000.png"},
+		{format: FilenameUUIDCompact, want: "550e8400e29b41d4a716446655440000.png"},
+		{format: FilenameTimestampUUID, want: "20240214T120000Z_550e8400-e29b-41d4-a716-446655440000.png"},
+		{format: "bogus", want: "550e8400-e29b-41d4-a716-446655440000.png"},
+	}
+	for _, tt := range tests {
+		got := taskFilename(task, tt.format)
+		assert.Equal(t, tt.want, got, "format %q", tt.format)
+		assert.Regexp(t, valid, got)
+	}
+	assert.NotContains(t, taskFilename(task, FilenameUUIDCompact), "-")
+
+	task.OutputMimeType = "image/jpeg"
+	assert.Equal(t, "550e8400-e29b-41d4-a716-446655440000.jpg", taskFilename(task, FilenameUUID))
+}
+
+func TestSendTaskResult_Filename(t *testing.T) {
+	config := MockConfig()
+	config.Server.FilenameFormat = FilenameUUIDCompact
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	assert.NoError(t, w.sendTaskResult(nil, task, mockImage))
+
+	msg := <-w.out.Load().queue
+	assert.Contains(t, string(msg.data), `filename="`+taskFilename(task, FilenameUUIDCompact)+`"`)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PromptFilter decides whether a prompt may be sent to the generation API
+type PromptFilter interface {
+	Allow(prompt string) (ok bool, reason string)
+}
+
+// ErrPromptRejected is returned when a prompt is blocked by the PromptFilter
+type ErrPromptRejected struct {
+	Reason string
+}
+
+func (e ErrPromptRejected) Error() string {
+	return fmt.Sprintf("prompt rejected: %s", e.Reason)
+}
+
+// WordlistFilter blocks prompts containing any of the listed words or phrases
+type WordlistFilter struct {
+	words []string
+}
+
+func NewWordlistFilter(words []string) *WordlistFilter {
+	f := &WordlistFilter{}
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w != "" {
+			f.words = append(f.words, w)
+		}
+	}
+	return f
+}
+
+// LoadWordlistFilter reads a blocklist file with one word or phrase per line
+func LoadWordlistFilter(path string) (*WordlistFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return NewWordlistFilter(words), nil
+}
+
+func (f *WordlistFilter) Allow(prompt string) (bool, string) {
+	lower := strings.ToLower(prompt)
+	for _, w := range f.words {
+		if strings.Contains(lower, w) {
+			return false, fmt.Sprintf("contains blocked phrase %q", w)
+		}
+	}
+	return true, ""
+}
+
+// defaultMaxPromptLength is the prompt limit when max_prompt_length is unset
+const defaultMaxPromptLength = 500
+
+// ErrPromptTooLong is returned when a prompt exceeds max_prompt_length and
+// truncation is off
+type ErrPromptTooLong struct {
+	Length int
+	Limit  int
+}
+
+func (e ErrPromptTooLong) Error() string {
+	return fmt.Sprintf("prompt too long: %d bytes, limit is %d", e.Length, e.Limit)
+}
+
+// TruncatePrompt shortens prompt to at most maxLen bytes, cutting at the last
+// word boundary that fits. A single word longer than maxLen is cut mid-word.
+func TruncatePrompt(prompt string, maxLen int) string {
+	if len(prompt) <= maxLen {
+		return prompt
+	}
+	cut := prompt[:maxLen]
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 && !unicode.IsSpace(rune(prompt[maxLen])) {
+		cut = cut[:i]
+	}
+	// don't leave half a multibyte character behind
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+}
+
+// checkPromptLength enforces max_prompt_length, truncating the prompt
+// instead of failing when truncate_prompt is set
+func (c *Client) checkPromptLength(logger *slog.Logger, prompt string) (string, error) {
+	limit := c.config.API.MaxPromptLength
+	switch {
+	case limit == 0:
+		limit = defaultMaxPromptLength
+	case limit < 0:
+		return prompt, nil
+	}
+	if len(prompt) <= limit {
+		return prompt, nil
+	}
+	if !c.config.API.TruncatePrompt {
+		return "", ErrPromptTooLong{Length: len(prompt), Limit: limit}
+	}
+	truncated := TruncatePrompt(prompt, limit)
+	logger.Warn("Prompt truncated", "length", len(prompt), "limit", limit, "truncated_length", len(truncated))
+	return truncated, nil
+}
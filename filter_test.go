@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordlistFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("Gore\n\n  bad phrase  \n"), 0o644))
+
+	filter, err := LoadWordlistFilter(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		prompt string
+		allow  bool
+	}{
+		{"a cute cat", true},
+		{"lots of GORE everywhere", false},
+		{"this is a Bad Phrase indeed", false},
+		{"bad, phrase", true},
+	}
+	for _, tt := range tests {
+		ok, reason := filter.Allow(tt.prompt)
+		assert.Equal(t, tt.allow, ok, tt.prompt)
+		if !ok {
+			assert.NotEmpty(t, reason)
+		}
+	}
+}
+
+func TestGenerateImage_PromptRejected(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// no server is running, so getting past the filter would fail differently
+	client := NewClient(MockConfig(), logger, WithPromptFilter(NewWordlistFilter([]string{"dog"})))
+
+	_, err := client.GenerateImage("a hot dog", 1)
+	var rejected ErrPromptRejected
+	require.True(t, errors.As(err, &rejected))
+	assert.Contains(t, rejected.Reason, "dog")
+}
+
+func TestTruncatePrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		maxLen int
+		want   string
+	}{
+		{name: "fits", prompt: "a cat", maxLen: 10, want: "a cat"},
+		{name: "word boundary", prompt: "a cat sitting on a mat", maxLen: 12, want: "a cat"},
+		{name: "cut at space", prompt: "a cat sitting on a mat", maxLen: 13, want: "a cat sitting"},
+		{name: "trailing comma", prompt: "cat, dog, bird", maxLen: 8, want: "cat"},
+		{name: "single long word", prompt: "supercalifragilistic", maxLen: 5, want: "super"},
+		{name: "multibyte", prompt: "ねこねこ", maxLen: 7, want: "ねこ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncatePrompt(tt.prompt, tt.maxLen)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, len(got), tt.maxLen)
+		})
+	}
+}
+
+func TestGenerateImage_PromptLength(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.MaxPromptLength = 12
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := client.GenerateImage("a cat sitting on a mat", 1)
+	var tooLong ErrPromptTooLong
+	require.True(t, errors.As(err, &tooLong), "expected ErrPromptTooLong, got %v", err)
+	assert.Equal(t, ErrPromptTooLong{Length: 22, Limit: 12}, tooLong)
+	assert.Zero(t, mock.CallCount("/API/GenerateText2Image"))
+
+	config.API.TruncatePrompt = true
+	_, err = client.GenerateImage("a cat sitting on a mat", 1)
+	require.NoError(t, err)
+	require.Len(t, mock.Generations(), 1)
+	assert.Equal(t, "a cat", mock.Generations()[0]["prompt"])
+}
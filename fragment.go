@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// defaultMaxFrameSize is the largest result chunk when max_frame_size is unset
+const defaultMaxFrameSize = 1 << 20
+
+func (c ServerConfig) maxFrameSize() int {
+	if c.MaxFrameSize == 0 {
+		return defaultMaxFrameSize
+	}
+	return c.MaxFrameSize
+}
+
+// fragmentPrefix starts the header line of each fragmented result frame
+const fragmentPrefix = "Fragment: "
+
+// FragmentHeader describes one frame of a fragmented task result. The first
+// frame also carries the task, which unfragmented results send as the
+// multipart "task" field.
+type FragmentHeader struct {
+	UUID        uuid.UUID `json:"uuid"`
+	ChunkIndex  int       `json:"chunk_index"`
+	TotalChunks int       `json:"total_chunks"`
+	IsLast      bool      `json:"is_last"`
+	Task        *Tasukete `json:"task,omitempty"`
+}
+
+// fragmentedSendTaskResult sends the image in binary frames of at most
+// max_frame_size bytes, each starting with a "Fragment: " line holding its
+// FragmentHeader as JSON
+func (w *WebSocketClient) fragmentedSendTaskResult(conn *websocket.Conn, task *Tasukete, result []byte) error {
+	chunkSize, err := fragmentChunkSize(w.resultFrameSize(), task, len(result))
+	if err != nil {
+		return err
+	}
+	chunks := slices.Collect(slices.Chunk(result, chunkSize))
+	for i, chunk := range chunks {
+		header := FragmentHeader{
+			UUID:        task.UUID,
+			ChunkIndex:  i,
+			TotalChunks: len(chunks),
+			IsLast:      i == len(chunks)-1,
+		}
+		if i == 0 {
+			header.Task = task
+		}
+		headerJSON, err := json.Marshal(header)
+		if err != nil {
+			return err
+		}
+
+		msg := make([]byte, 0, len(fragmentPrefix)+len(headerJSON)+1+len(chunk))
+		msg = append(msg, fragmentPrefix...)
+		msg = append(msg, headerJSON...)
+		msg = append(msg, '\n')
+		msg = append(msg, chunk...)
+		if err := w.sendResultFrame(msg); err != nil {
+			return fmt.Errorf("failed to send chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+	}
+	return nil
+}
+
+// resultFrameSize is max_frame_size less the room the negotiated
+// subprotocol's result envelope takes, 0 for no limit
+func (w *WebSocketClient) resultFrameSize() int {
+	size := w.config.Server.maxFrameSize()
+	if size <= 0 {
+		return size
+	}
+	return max(size-resultFrameOverhead(w.Subprotocol()), 1)
+}
+
+// fragmentChunkSize returns how many image bytes fit in a frame of
+// maxFrameSize after the header line. The header is sized for the worst
+// case: the first frame's task, with indexes no chunk count can exceed.
+func fragmentChunkSize(maxFrameSize int, task *Tasukete, resultSize int) (int, error) {
+	headerJSON, err := json.Marshal(FragmentHeader{
+		UUID:        task.UUID,
+		ChunkIndex:  resultSize,
+		TotalChunks: resultSize,
+		Task:        task,
+	})
+	if err != nil {
+		return 0, err
+	}
+	chunkSize := maxFrameSize - len(fragmentPrefix) - len(headerJSON) - 1
+	if chunkSize <= 0 {
+		return 0, fmt.Errorf("max_frame_size %d is too small for a %d byte fragment header", maxFrameSize, len(headerJSON))
+	}
+	return chunkSize, nil
+}
+
+// ParseFragmentedResult reassembles the frames of one fragmented task
+// result, in any order, into its task and image
+func ParseFragmentedResult(frames [][]byte) (*Tasukete, []byte, error) {
+	if len(frames) == 0 {
+		return nil, nil, errors.New("no fragments")
+	}
+
+	chunks := make([][]byte, len(frames))
+	var task *Tasukete
+	var id uuid.UUID
+	for _, frame := range frames {
+		line, chunk, ok := bytes.Cut(frame, []byte("\n"))
+		if !ok || !bytes.HasPrefix(line, []byte(fragmentPrefix)) {
+			return nil, nil, errors.New("frame has no fragment header")
+		}
+		var header FragmentHeader
+		if err := json.Unmarshal(line[len(fragmentPrefix):], &header); err != nil {
+			return nil, nil, fmt.Errorf("invalid fragment header: %w", err)
+		}
+
+		switch {
+		case header.TotalChunks != len(frames):
+			return nil, nil, fmt.Errorf("got %d fragments, header expects %d", len(frames), header.TotalChunks)
+		case header.ChunkIndex < 0 || header.ChunkIndex >= len(frames):
+			return nil, nil, fmt.Errorf("fragment index %d out of range", header.ChunkIndex)
+		case chunks[header.ChunkIndex] != nil:
+			return nil, nil, fmt.Errorf("duplicate fragment %d", header.ChunkIndex)
+		case header.IsLast != (header.ChunkIndex == len(frames)-1):
+			return nil, nil, fmt.Errorf("fragment %d has the wrong last flag", header.ChunkIndex)
+		case id != uuid.Nil && header.UUID != id:
+			return nil, nil, fmt.Errorf("fragments from tasks %s and %s", id, header.UUID)
+		}
+		id = header.UUID
+		chunks[header.ChunkIndex] = chunk
+		if header.Task != nil {
+			task = header.Task
+		}
+	}
+	if task == nil {
+		return nil, nil, errors.New("no fragment carries the task")
+	}
+	return task, bytes.Join(chunks, nil), nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFragmentedSendTaskResult(t *testing.T) {
+	config := MockConfig()
+	config.Server.MaxFrameSize = 1 << 20
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	image := bytes.Repeat([]byte{0x89, 0x50, 0x4e, 0x47}, 3<<20/4)
+	require.NoError(t, w.sendTaskResult(nil, task, image))
+
+	var frames [][]byte
+	for len(w.out.Load().queue) > 0 {
+		msg := <-w.out.Load().queue
+		assert.Equal(t, websocket.BinaryMessage, msg.messageType)
+		frames = append(frames, msg.data)
+	}
+	// the headers push the 3 MiB image into a fourth frame
+	require.Len(t, frames, 4)
+	for _, frame := range frames {
+		assert.LessOrEqual(t, len(frame), 1<<20, "frame is over max_frame_size")
+	}
+
+	// fragments may be reassembled in any order
+	frames[0], frames[3] = frames[3], frames[0]
+	got, data, err := ParseFragmentedResult(frames)
+	require.NoError(t, err)
+	assert.Equal(t, task.UUID, got.UUID)
+	assert.Equal(t, image, data)
+
+	_, _, err = ParseFragmentedResult(frames[:2])
+	assert.ErrorContains(t, err, "header expects 4")
+}
+
+func TestFragmentedSendTaskResult_FrameTooSmall(t *testing.T) {
+	config := MockConfig()
+	config.Server.MaxFrameSize = 64
+	w := newTestWebSocketClient(t, config)
+
+	err := w.fragmentedSendTaskResult(nil, NewTasukete(TTI, "a cat", 1), make([]byte, 1024))
+	assert.ErrorContains(t, err, "too small")
+	assert.Empty(t, w.out.Load().queue)
+}
+
+func TestSendTaskResult_FragmentsWhenMultipartOverflows(t *testing.T) {
+	config := MockConfig()
+	config.Server.MaxFrameSize = 4096
+	w := newTestWebSocketClient(t, config)
+
+	// the image fits, but not with the multipart headers around it
+	require.NoError(t, w.sendTaskResult(nil, NewTasukete(TTI, "a cat", 1), make([]byte, 4000)))
+	require.Len(t, w.out.Load().queue, 2)
+	for len(w.out.Load().queue) > 0 {
+		msg := <-w.out.Load().queue
+		assert.True(t, bytes.HasPrefix(msg.data, []byte(fragmentPrefix)))
+		assert.LessOrEqual(t, len(msg.data), 4096)
+	}
+}
+
+func TestSendTaskResult_SmallImageUnfragmented(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+
+	task := NewTasukete(TTI, "a cat", 1)
+	require.NoError(t, w.sendTaskResult(nil, task, mockImage))
+	got, data := sentResult(t, w)
+	assert.Equal(t, task.UUID, got.UUID)
+	assert.Equal(t, mockImage, data)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TaskHandler processes tasks of a type registered with RegisterTaskHandler.
+// A returned error fails the task.
+type TaskHandler interface {
+	Handle(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error
+}
+
+// TaskHandlerFunc adapts a function to TaskHandler
+type TaskHandlerFunc func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error
+
+func (f TaskHandlerFunc) Handle(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+	return f(ctx, conn, wsc, task)
+}
+
+type taskHandlers struct {
+	mu       sync.RWMutex
+	handlers map[Type]TaskHandler
+}
+
+func (h *taskHandlers) register(t Type, handler TaskHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.handlers == nil {
+		h.handlers = make(map[Type]TaskHandler)
+	}
+	h.handlers[t] = handler
+}
+
+func (h *taskHandlers) lookup(t Type) (TaskHandler, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	handler, ok := h.handlers[t]
+	return handler, ok
+}
+
+// RegisterTaskHandler routes tasks of type t to handler, taking precedence
+// over the built-in handling. Safe to call while tasks are being processed.
+func (w *WebSocketClient) RegisterTaskHandler(t Type, handler TaskHandler) {
+	w.handlers.register(t, handler)
+}
+
+// runTaskHandler runs a registered handler, failing the task on error
+func (w *WebSocketClient) runTaskHandler(ctx context.Context, conn *websocket.Conn, handler TaskHandler, task *Tasukete) {
+	logger := loggerFromContext(ctx, w.logger)
+	start := time.Now()
+	err := handler.Handle(ctx, conn, w, task)
+	defer w.recordTask(ctx, task, start, 0, err)
+	if err != nil {
+		logger.Error("Task handler failed", "uuid", task.UUID, "type", task.Type, "error", err)
+		task.AddMetadata("error", err.Error())
+		w.failTask(ctx, conn, task)
+	}
+}
+
+var customTypes = struct {
+	sync.RWMutex
+	names map[Type]string
+	next  Type
+}{names: make(map[Type]string), next: Heartbeat + 1}
+
+// RegisterTaskType allocates a Type for name so tasks of it can be parsed
+// from the wire and given a TaskHandler
+func RegisterTaskType(name string) (Type, error) {
+	customTypes.Lock()
+	defer customTypes.Unlock()
+	_, taken := parseBuiltinType(name)
+	for _, registered := range customTypes.names {
+		taken = taken || registered == name
+	}
+	if taken {
+		return 0, fmt.Errorf("task type %s already registered", name)
+	}
+	t := customTypes.next
+	customTypes.names[t] = name
+	customTypes.next++
+	return t, nil
+}
+
+func customTypeName(t Type) (string, bool) {
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+	name, ok := customTypes.names[t]
+	return name, ok
+}
+
+func parseCustomType(s string) (Type, bool) {
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+	for t, name := range customTypes.names {
+		if name == s {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerTestTaskType registers name for the duration of the test
+func registerTestTaskType(t *testing.T, name string) Type {
+	t.Helper()
+	taskType, err := RegisterTaskType(name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		customTypes.Lock()
+		defer customTypes.Unlock()
+		delete(customTypes.names, taskType)
+	})
+	return taskType
+}
+
+func TestRegisterTaskType(t *testing.T) {
+	upscale := registerTestTaskType(t, "TEST_UPSCALE")
+	assert.Equal(t, "TEST_UPSCALE", upscale.String())
+
+	var task Tasukete
+	require.NoError(t, json.Unmarshal([]byte(`{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"TEST_UPSCALE"}`), &task))
+	assert.Equal(t, upscale, task.Type)
+
+	_, err := RegisterTaskType("TEST_UPSCALE")
+	assert.Error(t, err)
+	_, err = RegisterTaskType("TTI")
+	assert.Error(t, err)
+}
+
+func TestRegisterTaskHandler(t *testing.T) {
+	custom := registerTestTaskType(t, "TEST_CUSTOM")
+
+	w := newTestWebSocketClient(t, MockConfig())
+	var handled []*Tasukete
+	w.RegisterTaskHandler(custom, TaskHandlerFunc(func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+		assert.Same(t, w, wsc)
+		handled = append(handled, task)
+		return nil
+	}))
+
+	task := NewTasukete(custom, "a cat", 1)
+	w.handleTask(context.Background(), nil, task)
+
+	require.Len(t, handled, 1)
+	assert.Same(t, task, handled[0])
+	assert.Empty(t, sentMessages(t, w))
+}
+
+func TestRegisterTaskHandler_Error(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	w.RegisterTaskHandler(TTI, TaskHandlerFunc(func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+		return errors.New("boom")
+	}))
+
+	w.handleTask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	var update Tasukete
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &update))
+	assert.Equal(t, StatusFailed, update.Status())
+	assert.Equal(t, "boom", update.Metadata["error"])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// handleHeartbeatTask completes a heartbeat task straight away, sending it
+// back as a task_result with its metadata plus the client's clock and how
+// long the task took from arrival to reply
+func (w *WebSocketClient) handleHeartbeatTask(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	logger := loggerFromContext(ctx, w.logger)
+
+	now := time.Now()
+	latency := time.Duration(0)
+	if receivedAt, ok := receivedAtFromContext(ctx); ok {
+		latency = now.Sub(receivedAt)
+	}
+	task.AddMetadata("server_time", now.UTC().Format(time.RFC3339Nano))
+	task.AddMetadata("processing_latency_ms", latency.Milliseconds())
+
+	err := task.UpdateStatus(StatusProcessing)
+	if err == nil {
+		err = task.UpdateStatus(StatusCompleted)
+	}
+	if err != nil {
+		logger.Error("Failed to complete heartbeat", "uuid", task.UUID, "error", err)
+		return
+	}
+	w.publishStatus(task)
+
+	msg := WebSocketMessage{
+		Type:    "task_result",
+		Payload: must(json.Marshal(task)),
+	}
+	if err := w.writeControlJSON(ctx, msg); err != nil {
+		logger.Error("Failed to send heartbeat result", "uuid", task.UUID, "error", err)
+		return
+	}
+	logger.Debug("Heartbeat answered", "uuid", task.UUID, "latency", latency)
+}
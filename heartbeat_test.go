@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHeartbeatTask(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+
+	var task Tasukete
+	payload := `{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"HEARTBEAT","model":0,"metadata":{"probe":"nightly","seq":7}}`
+	require.NoError(t, json.Unmarshal([]byte(payload), &task))
+	require.Equal(t, Heartbeat, task.Type)
+
+	ctx := newMessageContext(context.Background(), "req-1", w.logger)
+	w.handleTask(ctx, nil, &task)
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "task_result", messages[0].Type)
+
+	var result struct {
+		UUID     string         `json:"uuid"`
+		Status   string         `json:"status"`
+		Metadata map[string]any `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &result))
+	assert.Equal(t, task.UUID.String(), result.UUID)
+	assert.Equal(t, StatusCompleted.String(), result.Status)
+	assert.Equal(t, "nightly", result.Metadata["probe"])
+	assert.EqualValues(t, 7, result.Metadata["seq"])
+
+	serverTime, err := time.Parse(time.RFC3339Nano, result.Metadata["server_time"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), serverTime, 5*time.Second)
+	latency, ok := result.Metadata["processing_latency_ms"].(float64)
+	require.True(t, ok, "processing_latency_ms is a number")
+	assert.GreaterOrEqual(t, latency, 0.0)
+
+	assert.Empty(t, mock.Generations(), "heartbeats don't generate")
+}
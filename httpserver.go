@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpReadHeaderTimeout bounds how long a client may take to send request
+// headers. There's no write timeout: /events streams until the task ends.
+const httpReadHeaderTimeout = 10 * time.Second
+
+// NewHTTPServer serves the dashboard, task events and API handlers of
+// worker and client, behind the configured body limit and content security
+// policy
+func NewHTTPServer(config HTTPServerConfig, client *Client, worker *WebSocketClient, events *TaskEvents) (*http.Server, error) {
+	health, err := NewMultiServerClient(worker)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	for pattern, handler := range httpRoutes(config, client, worker, health, events) {
+		mux.Handle(pattern, handler)
+	}
+
+	handler := CSPMiddleware(config.contentSecurityPolicy())(LimitRequestBody(config.maxRequestBodySize(), mux))
+	return &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}, nil
+}
+
+// httpRoutes maps the ServeMux patterns NewHTTPServer serves to their
+// handlers. GenerateOpenAPISpec describes each of them.
+func httpRoutes(config HTTPServerConfig, client *Client, worker *WebSocketClient, health *MultiServerClient, events *TaskEvents) map[string]http.Handler {
+	routes := worker.dashboardRoutes()
+	routes["/events/{uuid}"] = events
+	routes["/health"] = HealthHandler(health)
+	routes["/ping"] = PingHandler(client)
+	routes["/benchmark/{model_id}"] = BenchmarkHandler(client, config.APIKey)
+	routes["/openapi.json"] = OpenAPIHandler()
+	return routes
+}
+
+// serveHTTP listens on the server's address, failing when it can't, and
+// serves in the background until the server is shut down
+func serveHTTP(server *http.Server, config HTTPServerConfig, logger *slog.Logger) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("HTTP server listening", "addr", listener.Addr().String())
+	go func() {
+		var err error
+		if config.TLSCertFile != "" && config.TLSKeyFile != "" {
+			err = server.ServeTLS(listener, config.TLSCertFile, config.TLSKeyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server stopped", "error", err)
+		}
+	}()
+	return nil
+}
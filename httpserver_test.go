@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPServer_Routes(t *testing.T) {
+	backend := NewMockSwarmUIServer().Start()
+	defer backend.Close()
+
+	config := MockConfig()
+	useMockAPI(config, backend)
+	config.HTTPServer.MaxRequestBodySize = 16
+	events := NewTaskEvents()
+	w := newTestWebSocketClient(t, config, WithTaskEvents(events))
+	httpServer, err := NewHTTPServer(config.HTTPServer, w.client, w, events)
+	require.NoError(t, err)
+	server := httptest.NewServer(httpServer.Handler)
+	defer server.Close()
+
+	tests := []struct {
+		method, path string
+		body         string
+		wantStatus   int
+	}{
+		{http.MethodGet, "/dashboard", "", http.StatusOK},
+		{http.MethodGet, "/dashboard.js", "", http.StatusOK},
+		{http.MethodGet, "/status", "", http.StatusOK},
+		{http.MethodGet, "/tasks", "", http.StatusOK},
+		{http.MethodGet, "/ping", "", http.StatusOK},
+		{http.MethodGet, "/openapi.json", "", http.StatusOK},
+		{http.MethodGet, "/health", "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/events/not-a-uuid", "", http.StatusBadRequest},
+		{http.MethodPost, "/benchmark/1", "", http.StatusUnauthorized},
+		{http.MethodPost, "/benchmark/1", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, defaultCSP, resp.Header.Get("Content-Security-Policy"))
+		})
+	}
+}
+
+func TestServeHTTP_AddressInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	config := MockConfig()
+	config.HTTPServer.Addr = taken.Addr().String()
+	events := NewTaskEvents()
+	w := newTestWebSocketClient(t, config, WithTaskEvents(events))
+	httpServer, err := NewHTTPServer(config.HTTPServer, w.client, w, events)
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	assert.Error(t, serveHTTP(httpServer, config.HTTPServer, logger), "the address is taken")
+
+	config.HTTPServer.Addr = "127.0.0.1:0"
+	httpServer, err = NewHTTPServer(config.HTTPServer, w.client, w, events)
+	require.NoError(t, err)
+	require.NoError(t, serveHTTP(httpServer, config.HTTPServer, logger))
+	require.NoError(t, httpServer.Shutdown(context.Background()))
+}
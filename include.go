@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeTag marks a scalar as the path of a YAML file to inline, relative
+// to the including file
+const includeTag = "!include"
+
+// loadYAMLWithIncludes parses path and replaces every !include node with
+// the contents of the file it names
+func loadYAMLWithIncludes(path string) (*yaml.Node, error) {
+	return loadIncludedYAML(path, nil)
+}
+
+// loadIncludedYAML parses path, with stack holding the files that include
+// it, outermost first
+func loadIncludedYAML(path string, stack []string) (*yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("circular include: %s", strings.Join(append(stack, abs), " -> "))
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := resolveIncludes(&doc, filepath.Dir(abs), stack); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func resolveIncludes(node *yaml.Node, dir string, stack []string) error {
+	if node.Tag == includeTag {
+		if node.Kind != yaml.ScalarNode || node.Value == "" {
+			return fmt.Errorf("line %d: %s needs a file path", node.Line, includeTag)
+		}
+		target := node.Value
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		included, err := loadIncludedYAML(target, stack)
+		if err != nil {
+			return fmt.Errorf("include %s: %w", node.Value, err)
+		}
+		if len(included.Content) == 0 {
+			*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+			return nil
+		}
+		*node = *included.Content[0]
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := resolveIncludes(child, dir, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFiles writes files into one temp dir and returns its path
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestLoadConfig_Include(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+version: 1
+server: !include shared/server.yaml
+models:
+  - <<: !include shared/sdxl.yaml
+    name: Portrait
+    string: portrait_xl
+  - <<: !include shared/sdxl.yaml
+    name: Landscape
+    string: landscape_xl
+    steps: 40
+`,
+		"shared/server.yaml": `
+host: tasks.example.com
+port: "8443"
+capabilities: !include capabilities.yaml
+`,
+		"shared/capabilities.yaml": "[sdxl, fp16]\n",
+		"shared/sdxl.yaml": `
+width: 1024
+height: 1024
+steps: 20
+cfgscale: 7
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "tasks.example.com", cfg.Server.Host)
+	assert.Equal(t, "8443", cfg.Server.Port)
+	assert.Equal(t, []string{"sdxl", "fp16"}, cfg.Server.Capabilities, "nested includes resolve relative to their file")
+
+	require.Len(t, cfg.Models, 2)
+	assert.Equal(t, "Portrait", cfg.Models[0].Name)
+	assert.Equal(t, "portrait_xl", cfg.Models[0].String)
+	assert.Equal(t, 1024, cfg.Models[0].Width)
+	assert.Equal(t, 20, cfg.Models[0].Steps)
+	assert.Equal(t, float32(7), cfg.Models[0].Cfgscale)
+	assert.Equal(t, "Landscape", cfg.Models[1].Name)
+	assert.Equal(t, 40, cfg.Models[1].Steps, "keys next to the merge win")
+	assert.Equal(t, 1024, cfg.Models[1].Height)
+}
+
+func TestLoadConfig_CircularInclude(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": "server: !include a.yaml\n",
+		"a.yaml":      "host: !include b.yaml\n",
+		"b.yaml":      "!include a.yaml\n",
+	})
+
+	_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular include")
+}
+
+func TestLoadConfig_IncludeErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"missing.yaml": "server: !include nowhere.yaml\n",
+		"mapping.yaml": "server: !include {path: a.yaml}\n",
+	})
+
+	_, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = LoadConfig(filepath.Join(dir, "mapping.yaml"))
+	assert.ErrorContains(t, err, "needs a file path")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultModelInventoryTTL is how long a model inventory file stays fresh
+// when model_inventory_ttl is unset
+const defaultModelInventoryTTL = time.Hour
+
+// modelInventory is the on-disk cache of FetchAvailableModels
+type modelInventory struct {
+	FetchedAt time.Time     `json:"fetched_at"`
+	Models    []RemoteModel `json:"models"`
+}
+
+func (c APIConfig) modelInventoryTTL() time.Duration {
+	if c.ModelInventoryTTL > 0 {
+		return time.Duration(c.ModelInventoryTTL) * time.Second
+	}
+	return defaultModelInventoryTTL
+}
+
+// AvailableModels lists the API's models, preferring a fresh model inventory
+// file over the network and refreshing the file when it is stale. Without
+// model_inventory_path it is FetchAvailableModels.
+func (c *Client) AvailableModels(ctx context.Context) ([]RemoteModel, error) {
+	path := c.config.API.ModelInventoryPath
+	if path == "" {
+		return c.FetchAvailableModels(ctx)
+	}
+	logger := loggerFromContext(ctx, c.logger)
+
+	inventory, err := loadModelInventory(path)
+	if err != nil {
+		logger.Warn("Could not read model inventory", "path", path, "error", err)
+	}
+	if inventory != nil && time.Since(inventory.FetchedAt) < c.config.API.modelInventoryTTL() {
+		logger.Debug("Using model inventory", "path", path, "fetched_at", inventory.FetchedAt)
+		return inventory.Models, nil
+	}
+
+	models, err := c.FetchAvailableModels(ctx)
+	if err != nil {
+		if inventory != nil {
+			logger.Warn("Could not refresh model inventory, using stale copy", "fetched_at", inventory.FetchedAt, "error", err)
+			return inventory.Models, nil
+		}
+		return nil, err
+	}
+	if err := saveModelInventory(path, modelInventory{FetchedAt: time.Now(), Models: models}); err != nil {
+		logger.Warn("Could not save model inventory", "path", path, "error", err)
+	}
+	return models, nil
+}
+
+// loadModelInventory returns nil without an error when the file doesn't exist
+func loadModelInventory(path string) (*modelInventory, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var inventory modelInventory
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse model inventory: %w", err)
+	}
+	return &inventory, nil
+}
+
+func saveModelInventory(path string, inventory modelInventory) error {
+	data, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
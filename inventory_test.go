@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inventoryClient(t *testing.T, mock *MockSwarmUIServer) (*Client, string) {
+	t.Helper()
+	server := mock.Start()
+	t.Cleanup(server.Close)
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.ModelInventoryPath = filepath.Join(t.TempDir(), "models.json")
+	return NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil))), config.API.ModelInventoryPath
+}
+
+func TestAvailableModels_PrefersFreshInventory(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetModels("remote.safetensors")
+	client, path := inventoryClient(t, mock)
+
+	cached := []RemoteModel{{Name: "cached.safetensors"}}
+	require.NoError(t, saveModelInventory(path, modelInventory{FetchedAt: time.Now().Add(-time.Minute), Models: cached}))
+
+	models, err := client.AvailableModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, cached, models)
+	assert.Zero(t, mock.CallCount("/API/ListModels"))
+}
+
+func TestAvailableModels_RefreshesStaleInventory(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetModels("remote.safetensors")
+	client, path := inventoryClient(t, mock)
+
+	require.NoError(t, saveModelInventory(path, modelInventory{
+		FetchedAt: time.Now().Add(-2 * time.Hour),
+		Models:    []RemoteModel{{Name: "cached.safetensors"}},
+	}))
+
+	models, err := client.AvailableModels(context.Background())
+	require.NoError(t, err)
+	want := []RemoteModel{{Name: "remote.safetensors"}}
+	assert.Equal(t, want, models)
+	assert.Equal(t, 1, mock.CallCount("/API/ListModels"))
+
+	inventory, err := loadModelInventory(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, inventory.Models)
+	assert.WithinDuration(t, time.Now(), inventory.FetchedAt, time.Minute)
+
+	// the refreshed file now answers
+	_, err = client.AvailableModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, mock.CallCount("/API/ListModels"))
+}
+
+func TestAvailableModels_StaleInventoryWhenAPIDown(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetFailOnNthRequest(1)
+	client, path := inventoryClient(t, mock)
+
+	cached := []RemoteModel{{Name: "cached.safetensors"}}
+	require.NoError(t, saveModelInventory(path, modelInventory{FetchedAt: time.Now().Add(-2 * time.Hour), Models: cached}))
+
+	models, err := client.AvailableModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, cached, models)
+}
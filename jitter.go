@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"time"
+)
+
+// requestJitter is the upper bound of the random delay before each
+// generation request, 0 when jitter is off
+func (c APIConfig) requestJitter() time.Duration {
+	return time.Duration(max(c.RequestJitterMs, 0)) * time.Millisecond
+}
+
+// sleepJitter waits a random duration below request_jitter_ms, so workers
+// started together don't hit the API at the same moment. The wait is
+// reported to the jitter hook on ctx.
+func (c *Client) sleepJitter(ctx context.Context) error {
+	limit := c.config.API.requestJitter()
+	if limit <= 0 {
+		return nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(limit)))
+	if err != nil {
+		return err
+	}
+	jitter := time.Duration(n.Int64())
+	jitterApplied(ctx, jitter)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(jitter):
+		return nil
+	}
+}
+
+// withJitterHook has generations made on behalf of ctx call hook with each
+// jitter delay they wait out
+func withJitterHook(ctx context.Context, hook func(time.Duration)) context.Context {
+	return context.WithValue(ctx, jitterHookKey, hook)
+}
+
+func jitterApplied(ctx context.Context, jitter time.Duration) {
+	if hook, ok := ctx.Value(jitterHookKey).(func(time.Duration)); ok {
+		hook(jitter)
+	}
+}
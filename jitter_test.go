@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_RequestJitter(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.RequestJitterMs = 100
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	result, err := client.Generate(context.Background(), GenerateRequest{Prompt: "test prompt", ModelID: 1})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Jitter, time.Duration(0))
+	assert.Less(t, result.Jitter, 100*time.Millisecond)
+
+	stats := client.GetModelStats()
+	require.Len(t, stats, 1)
+	assert.GreaterOrEqual(t, stats[0].TotalLatencyMs, result.Jitter.Milliseconds(), "latency includes the jitter")
+}
+
+func TestHandleTTITask_JitterMetadata(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.RequestJitterMs = 100
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+
+	require.Equal(t, StatusCompleted, task.Status())
+	jitter, ok := task.Metadata["jitter_ms"].(int64)
+	require.True(t, ok, "jitter_ms is set")
+	assert.True(t, jitter >= 0 && jitter < 100, "jitter_ms %d out of range", jitter)
+}
+
+func TestAPIConfig_RequestJitter(t *testing.T) {
+	assert.Zero(t, APIConfig{}.requestJitter())
+	assert.Zero(t, APIConfig{RequestJitterMs: -5}.requestJitter())
+	assert.Equal(t, 250*time.Millisecond, APIConfig{RequestJitterMs: 250}.requestJitter())
+}
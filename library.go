@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type PromptEntry struct {
+	Positive string   `yaml:"positive"`
+	Negative string   `yaml:"negative"`
+	Tags     []string `yaml:"tags"`
+}
+
+// PromptLibrary holds named prompt presets
+type PromptLibrary struct {
+	entries map[string]PromptEntry
+}
+
+func LoadPromptLibrary(path string) (*PromptLibrary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make(map[string]PromptEntry)
+	if err := yaml.NewDecoder(file).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode prompt library: %w", err)
+	}
+	return &PromptLibrary{entries: entries}, nil
+}
+
+// ResolvePrompt returns the positive and negative prompts stored under alias
+func (l *PromptLibrary) ResolvePrompt(alias string) (positive, negative string, err error) {
+	entry, ok := l.entries[alias]
+	if !ok {
+		return "", "", fmt.Errorf("unknown prompt alias: %q", alias)
+	}
+	return entry.Positive, entry.Negative, nil
+}
+
+type LoraEntry struct {
+	Name   string  `yaml:"name" json:"name"`
+	Weight float32 `yaml:"weight" json:"weight"`
+}
+
+// LoRALibrary holds named LoRA stacks
+type LoRALibrary struct {
+	presets map[string][]LoraEntry
+}
+
+func LoadLoRALibrary(path string) (*LoRALibrary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	presets := make(map[string][]LoraEntry)
+	if err := yaml.NewDecoder(file).Decode(&presets); err != nil {
+		return nil, fmt.Errorf("failed to decode lora library: %w", err)
+	}
+	return &LoRALibrary{presets: presets}, nil
+}
+
+func (l *LoRALibrary) Preset(name string) ([]LoraEntry, error) {
+	if l == nil {
+		return nil, fmt.Errorf("no lora library configured")
+	}
+	entries, ok := l.presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown lora preset: %q", name)
+	}
+	return entries, nil
+}
+
+// mergeLoras appends overrides to base, replacing the weight of LoRAs present in both
+func mergeLoras(base, overrides []LoraEntry) []LoraEntry {
+	merged := append([]LoraEntry(nil), base...)
+	for _, o := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == o.Name {
+				merged[i].Weight = o.Weight
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// parseLoras splits the comma separated model Loras, all sharing one weight
+func parseLoras(loras string, weight float32) []LoraEntry {
+	if weight == 0 {
+		weight = 1
+	}
+	var entries []LoraEntry
+	for _, name := range strings.Split(loras, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			entries = append(entries, LoraEntry{Name: name, Weight: weight})
+		}
+	}
+	return entries
+}
+
+// loraStack builds the effective LoRAs: model preset, then explicit model
+// LoRAs, then the task preset, later layers winning on conflicts
+func (c *Client) loraStack(model ModelConfig, taskPreset string) ([]LoraEntry, error) {
+	var stack []LoraEntry
+	if model.LoraPreset != "" {
+		preset, err := c.loras.Preset(model.LoraPreset)
+		if err != nil {
+			return nil, err
+		}
+		stack = mergeLoras(stack, preset)
+	}
+
+	stack = mergeLoras(stack, parseLoras(model.Loras, model.LoraWeights))
+	stack = mergeLoras(stack, model.LoraStack)
+
+	if taskPreset != "" {
+		preset, err := c.loras.Preset(taskPreset)
+		if err != nil {
+			return nil, err
+		}
+		stack = mergeLoras(stack, preset)
+	}
+	return stack, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+const testPromptLibrary = `
+portrait:
+  positive: "portrait photo, 85mm, soft light"
+  negative: "blurry, lowres"
+  tags: [photo, people]
+doodle:
+  positive: "pencil sketch"
+`
+
+func TestPromptLibrary_ResolvePrompt(t *testing.T) {
+	library, err := LoadPromptLibrary(writeTempFile(t, "prompts.yaml", testPromptLibrary))
+	require.NoError(t, err)
+
+	positive, negative, err := library.ResolvePrompt("portrait")
+	require.NoError(t, err)
+	assert.Equal(t, "portrait photo, 85mm, soft light", positive)
+	assert.Equal(t, "blurry, lowres", negative)
+
+	_, _, err = library.ResolvePrompt("missing")
+	assert.Error(t, err)
+}
+
+func TestWebSocketClient_ResolvePromptAlias(t *testing.T) {
+	library, err := LoadPromptLibrary(writeTempFile(t, "prompts.yaml", testPromptLibrary))
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+	w := NewWebSocketClient(config, NewClient(config, logger), logger, WithPromptLibrary(library))
+
+	task := NewTasukete(TTI, "an old sailor", 1)
+	task.PromptAlias = "portrait"
+	require.NoError(t, w.resolvePromptAlias(task))
+	assert.Equal(t, "portrait photo, 85mm, soft light, an old sailor", task.Prompt)
+	assert.Equal(t, "blurry, lowres", task.NegativePrompt)
+
+	task = NewTasukete(TTI, "", 1)
+	task.PromptAlias = "doodle"
+	task.NegativePrompt = "color"
+	require.NoError(t, w.resolvePromptAlias(task))
+	assert.Equal(t, "pencil sketch", task.Prompt)
+	assert.Equal(t, "color", task.NegativePrompt)
+}
+
+const testLoRALibrary = `
+anime:
+  - name: flat_colors
+    weight: 0.6
+  - name: big_eyes
+    weight: 0.8
+detail:
+  - name: big_eyes
+    weight: 0.3
+  - name: add_detail
+    weight: 1.2
+`
+
+func TestLoRALibrary_Merge(t *testing.T) {
+	library, err := LoadLoRALibrary(writeTempFile(t, "loras.yaml", testLoRALibrary))
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+	config.Models[0].Loras = "GyateGyate_pdxl_Incrs_v1"
+	config.Models[0].LoraWeights = 2.7
+	config.Models[0].LoraPreset = "anime"
+	client := NewClient(config, logger, WithLoRALibrary(library))
+
+	// model preset merged with explicit loras
+	stack, err := client.loraStack(config.Models[0], "")
+	require.NoError(t, err)
+	assert.Equal(t, []LoraEntry{
+		{Name: "flat_colors", Weight: 0.6},
+		{Name: "big_eyes", Weight: 0.8},
+		{Name: "GyateGyate_pdxl_Incrs_v1", Weight: 2.7},
+	}, stack)
+
+	// task preset wins on conflicts
+	stack, err = client.loraStack(config.Models[0], "detail")
+	require.NoError(t, err)
+	assert.Equal(t, []LoraEntry{
+		{Name: "flat_colors", Weight: 0.6},
+		{Name: "big_eyes", Weight: 0.3},
+		{Name: "GyateGyate_pdxl_Incrs_v1", Weight: 2.7},
+		{Name: "add_detail", Weight: 1.2},
+	}, stack)
+
+	params, err := client.generationParams(GenerateRequest{Prompt: "cat", ModelID: 1, LoraPreset: "detail"}, config.Models[0])
+	require.NoError(t, err)
+	body := params.requestBody("session")
+	assert.Equal(t, "flat_colors,big_eyes,GyateGyate_pdxl_Incrs_v1,add_detail", body["loras"])
+	assert.Equal(t, "0.6,0.3,2.7,1.2", body["loraweights"])
+
+	_, err = client.loraStack(config.Models[0], "missing")
+	assert.Error(t, err)
+}
+
+func TestGenerationParams_NoPresetKeepsLegacyLoras(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+	config.Models[0].Loras = "some_lora"
+	config.Models[0].LoraWeights = 0.5
+	client := NewClient(config, logger)
+
+	params, err := client.generationParams(GenerateRequest{Prompt: "cat", ModelID: 1}, config.Models[0])
+	require.NoError(t, err)
+	body := params.requestBody("session")
+	assert.Equal(t, "some_lora", body["loras"])
+	assert.Equal(t, float32(0.5), body["loraweights"])
+}
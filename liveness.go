@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	pingInterval       = 10 * time.Second
+	defaultPongTimeout = 30 * time.Second
+)
+
+// pongTimeout is how long the connection may go without a pong before it is
+// considered dead
+func (c ServerConfig) pongTimeout() time.Duration {
+	if c.PongTimeout > 0 {
+		return time.Duration(c.PongTimeout) * time.Second
+	}
+	return defaultPongTimeout
+}
+
+// trackPongs records every pong so the liveness check can spot a silent
+// disconnect. Pongs are only seen while handleMessages is reading.
+func (w *WebSocketClient) trackPongs(conn *websocket.Conn) {
+	w.lastPong.Store(time.Now().UnixNano())
+	conn.SetPongHandler(func(string) error {
+		w.lastPong.Store(time.Now().UnixNano())
+		return nil
+	})
+}
+
+// startLivenessCheck closes conn once no pong has arrived within the pong
+// timeout, which unblocks handleMessages and lets Start reconnect
+func (w *WebSocketClient) startLivenessCheck(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(w.pongTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			lag := time.Since(time.Unix(0, w.lastPong.Load()))
+			if lag > w.pongTimeout {
+				w.logger.Warn("No pong received, reconnecting", "lag", lag, "timeout", w.pongTimeout)
+				conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// newLivenessServer accepts one client, authenticates it, then either keeps
+// reading (answering pings) or goes silent
+func newLivenessServer(t *testing.T, answerPings bool) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var auth WebSocketMessage
+		if conn.ReadJSON(&auth) != nil {
+			return
+		}
+		conn.WriteJSON(WebSocketMessage{Type: "auth_success", Payload: []byte(`{"token":"t"}`)})
+
+		if !answerPings {
+			<-release
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func livenessClient(t *testing.T, server *httptest.Server) *WebSocketClient {
+	t.Helper()
+	config := MockConfig()
+	useWebSocketServer(config, server)
+	w := newTestWebSocketClient(t, config)
+	w.pingInterval = 10 * time.Millisecond
+	w.pongTimeout = 150 * time.Millisecond
+	return w
+}
+
+func TestLivenessCheck_ReconnectsWithoutPongs(t *testing.T) {
+	w := livenessClient(t, newLivenessServer(t, false))
+
+	done := make(chan error, 1)
+	go func() { done <- w.connect() }()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection without pongs was not dropped")
+	}
+}
+
+func TestLivenessCheck_KeepsAnsweredConnection(t *testing.T) {
+	w := livenessClient(t, newLivenessServer(t, true))
+
+	done := make(chan error, 1)
+	go func() { done <- w.connect() }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("live connection was dropped: %v", err)
+	case <-time.After(4 * w.pongTimeout):
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"log/slog"
-	"os"
+	"slices"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -18,16 +20,45 @@ type PrettyHandlerOptions struct {
 type PrettyHandler struct {
 	slog.Handler
 	l *log.Logger
+
+	attrs  []slog.Attr // added by WithAttrs, keys qualified by their groups
+	groups []string    // opened by WithGroup, qualifying later keys
 }
 
-func initLogger() *slog.Logger {
+func initLogger(out io.Writer, conf LogConfig) (*slog.Logger, error) {
+	level, err := parseLogLevel(conf.Level)
+	if err != nil {
+		return nil, err
+	}
 	opts := PrettyHandlerOptions{
 		SlogOpts: slog.HandlerOptions{
-			Level: slog.LevelDebug,
+			Level: level,
 		},
 	}
-	handler := NewPrettyHandler(os.Stdout, opts)
-	return slog.New(handler)
+
+	switch conf.Format {
+	case "", "text":
+		return slog.New(NewPrettyHandler(out, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(out, &opts.SlogOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", conf.Format)
+	}
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	switch level {
+	case "", "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level: %s", level)
+	}
 }
 
 func (h *PrettyHandler) Handle(ctx context.Context, r slog.Record) error {
@@ -44,9 +75,12 @@ func (h *PrettyHandler) Handle(ctx context.Context, r slog.Record) error {
 		level = color.RedString(level)
 	}
 
-	fields := make(map[string]interface{}, r.NumAttrs())
-	r.Attrs(func(a slog.Attr) bool {
+	fields := make(map[string]interface{}, len(h.attrs)+r.NumAttrs())
+	for _, a := range h.attrs {
 		fields[a.Key] = a.Value.Any()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		fields[h.qualify(a.Key)] = a.Value.Any()
 		return true
 	})
 
@@ -62,6 +96,32 @@ func (h *PrettyHandler) Handle(ctx context.Context, r slog.Record) error {
 	return nil
 }
 
+// WithAttrs keeps the pretty format for loggers made with Logger.With
+func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := slices.Clip(h.attrs)
+	for _, a := range attrs {
+		merged = append(merged, slog.Attr{Key: h.qualify(a.Key), Value: a.Value})
+	}
+	return &PrettyHandler{Handler: h.Handler.WithAttrs(attrs), l: h.l, attrs: merged, groups: h.groups}
+}
+
+// WithGroup keeps the pretty format for loggers made with Logger.WithGroup.
+// Keys logged in the group are shown as group.key.
+func (h *PrettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &PrettyHandler{Handler: h.Handler.WithGroup(name), l: h.l, attrs: h.attrs, groups: append(slices.Clip(h.groups), name)}
+}
+
+// qualify prefixes key with the open groups
+func (h *PrettyHandler) qualify(key string) string {
+	if len(h.groups) == 0 {
+		return key
+	}
+	return strings.Join(h.groups, ".") + "." + key
+}
+
 func NewPrettyHandler(out io.Writer, opts PrettyHandlerOptions) *PrettyHandler {
 	return &PrettyHandler{
 		Handler: slog.NewTextHandler(out, &opts.SlogOpts),
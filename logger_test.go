@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInitLoggerJSON verifies json format emits decodable records
+func TestInitLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := initLogger(&buf, LogConfig{Format: "json", Level: "info"})
+	require.NoError(t, err)
+
+	logger.Debug("hidden")
+	logger.Info("Task completed", "uuid", "abc")
+	logger.Error("Task failed", "error", "boom")
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]any
+		require.NoError(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 2, "debug record should be filtered at info level")
+	for _, key := range []string{"time", "level", "msg"} {
+		assert.Contains(t, records[0], key)
+	}
+	assert.Equal(t, "Task completed", records[0]["msg"])
+	assert.Equal(t, "abc", records[0]["uuid"])
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, "boom", records[1]["error"])
+}
+
+func TestInitLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := initLogger(&buf, LogConfig{})
+	require.NoError(t, err)
+
+	logger.Debug("Debug enabled by default")
+	assert.Contains(t, buf.String(), "Debug enabled by default")
+}
+
+func TestPrettyHandler_With(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := initLogger(&buf, LogConfig{})
+	require.NoError(t, err)
+
+	logger.With("request_id", "req-1").WithGroup("task").Info("Task completed", "uuid", "abc")
+	out := buf.String()
+	assert.Contains(t, out, "Task completed")
+	assert.Contains(t, out, `"request_id": "req-1"`, "pretty JSON fields, not key=value text")
+	assert.Contains(t, out, `"task.uuid": "abc"`)
+	assert.NotContains(t, out, "msg=")
+}
+
+func TestInitLoggerInvalid(t *testing.T) {
+	_, err := initLogger(&bytes.Buffer{}, LogConfig{Format: "xml"})
+	assert.Error(t, err)
+
+	_, err = initLogger(&bytes.Buffer{}, LogConfig{Level: "verbose"})
+	assert.Error(t, err)
+}
+
+// TestWithLogger verifies the injected logger replaces the constructor argument
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	injected := slog.New(slog.NewJSONHandler(&buf, nil))
+	discard := slog.New(slog.DiscardHandler)
+
+	config := MockConfig()
+	config.ModelSelectionStrategy = "bogus"
+	client := NewClient(config, discard, WithLogger(injected))
+	assert.Same(t, injected, client.logger)
+
+	w := NewWebSocketClient(config, client, discard, WithWebSocketLogger(injected))
+	assert.Same(t, injected, w.logger)
+	assert.True(t, strings.Contains(buf.String(), "Unknown model selection strategy"))
+}
@@ -2,24 +2,207 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	// Initialize logger
-	logger := initLogger()
+	generate := flag.Bool("generate", false, "generate a single image from a task and exit")
+	taskFile := flag.String("task-file", "-", "task JSON file for -generate, - reads STDIN")
+	output := flag.String("output", "-", "output PNG path for -generate and -upscale, - writes STDOUT")
+	upscale := flag.String("upscale", "", "upscale the image at this path and exit, - reads STDIN")
+	upscaleModel := flag.Int("upscale-model", 1, "model ID for -upscale")
+	upscaleScale := flag.Float64("upscale-scale", defaultUpscaleFactor, "scale factor for -upscale")
+	dryRun := flag.Bool("dry-run", false, "with -generate, write the API request body instead of calling the API")
+	exportModels := flag.Bool("export-models-md", false, "print the configured models as a markdown table and exit")
+	flag.Parse()
 
-	// Load configuration
-	conf, err := LoadConfig("./config.yaml")
+	// Initialize logger, keeping STDOUT free for image data and exports
+	logOut := os.Stdout
+	if *generate || *upscale != "" || *exportModels {
+		logOut = os.Stderr
+	}
+	logger, _ := initLogger(logOut, LogConfig{})
+
+	// Load configuration, letting GENCLIENT_* environment variables override the file
+	conf := &Config{}
+	err := FillConfig(conf, YAMLSource{Path: "./config.yaml"}, EnvSource{Prefix: "GENCLIENT"})
 	if err != nil {
 		logger.Error("Config load failed", "error", err)
 		os.Exit(1)
 	}
 
+	// Switch to the configured log format and level
+	logger, err = initLogger(logOut, conf.Log)
+	if err != nil {
+		slog.New(slog.NewTextHandler(logOut, nil)).Error("Logger init failed", "error", err)
+		os.Exit(1)
+	}
+
+	if *exportModels {
+		fmt.Print(ModelsToMarkdown(conf.Models))
+		return
+	}
+
+	var opts []ClientOption
+	if conf.API.BlocklistPath != "" {
+		filter, err := LoadWordlistFilter(conf.API.BlocklistPath)
+		if err != nil {
+			logger.Error("Blocklist load failed", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithPromptFilter(filter))
+	}
+	if conf.API.LoRALibraryPath != "" {
+		library, err := LoadLoRALibrary(conf.API.LoRALibraryPath)
+		if err != nil {
+			logger.Error("LoRA library load failed", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithLoRALibrary(library))
+	}
+
+	if conf.API.Budget.DailyStepLimit > 0 {
+		budget, err := NewBudgetEnforcer(conf.API.Budget, conf.API.BudgetCounterPath, logger)
+		if err != nil {
+			logger.Error("Budget counter load failed", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, WithBudget(budget))
+	}
+
+	// Write runtime config changes back to the config file on exit
+	watcher := NewConfigWatcher(conf, logger)
+	defer watcher.Close()
+	watcher.SaveOnExit()
+	opts = append(opts, WithConfigWatcher(watcher))
+
 	// Create client instance
-	client := NewClient(conf, logger)
+	client := NewClient(conf, logger, opts...)
+	client.DryRun = *generate && *dryRun
+
+	if *generate {
+		if err := runGenerate(client, *taskFile, *output); err != nil {
+			logger.Error("Generation failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *upscale != "" {
+		if err := runUpscale(client, *upscale, *upscaleModel, *upscaleScale, *output); err != nil {
+			logger.Error("Upscale failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := client.Preflight(context.Background()); err != nil {
+		logger.Error("Preflight check failed", "error", err)
+		os.Exit(1)
+	}
+
+	if len(conf.API.WarmupSchedule) > 0 {
+		warmup, err := NewWarmupScheduler(client, conf.API.WarmupSchedule, logger)
+		if err != nil {
+			logger.Error("Warmup schedule setup failed", "error", err)
+			os.Exit(1)
+		}
+		warmup.Start()
+		defer warmup.Stop()
+	}
+
+	var wsOpts []WebSocketOption
+	if conf.API.PromptLibraryPath != "" {
+		library, err := LoadPromptLibrary(conf.API.PromptLibraryPath)
+		if err != nil {
+			logger.Error("Prompt library load failed", "error", err)
+			os.Exit(1)
+		}
+		wsOpts = append(wsOpts, WithPromptLibrary(library))
+	}
+	if conf.API.TaskSchemaPath != "" {
+		schemas, err := LoadSchemaRegistry(conf.API.TaskSchemaPath)
+		if err != nil {
+			logger.Error("Task schema load failed", "error", err)
+			os.Exit(1)
+		}
+		wsOpts = append(wsOpts, WithSchemaRegistry(schemas))
+	}
+	if conf.API.TaskLogPath != "" {
+		taskLog, err := NewTaskLogger(conf.API.TaskLogPath)
+		if err != nil {
+			logger.Error("Task log open failed", "error", err)
+			os.Exit(1)
+		}
+		defer taskLog.Close()
+		wsOpts = append(wsOpts, WithTaskLogger(taskLog))
+	}
+
+	translator, err := newPromptTranslator(conf.API.Translation)
+	if err != nil {
+		logger.Error("Prompt translator setup failed", "error", err)
+		os.Exit(1)
+	}
+	wsOpts = append(wsOpts, WithPromptTranslator(translator))
+
+	pending, err := newPersistentQueue(conf)
+	if err != nil {
+		logger.Error("Persistent queue open failed", "error", err)
+		os.Exit(1)
+	}
+	if pending != nil {
+		defer pending.Close()
+		wsOpts = append(wsOpts, WithPersistentQueue(pending))
+	}
+
+	var events *TaskEvents
+	if conf.HTTPServer.Addr != "" {
+		events = NewTaskEvents()
+		wsOpts = append(wsOpts, WithTaskEvents(events))
+	}
+
+	wsClient := NewWebSocketClient(conf, client, logger, wsOpts...)
+
+	// Serve the dashboard and API handlers when an address is configured
+	var httpServer *http.Server
+	if conf.HTTPServer.Addr != "" {
+		httpServer, err = NewHTTPServer(conf.HTTPServer, client, wsClient, events)
+		if err == nil {
+			err = serveHTTP(httpServer, conf.HTTPServer, logger)
+		}
+		if err != nil {
+			logger.Error("HTTP server start failed", "error", err)
+			os.Exit(1)
+		}
+	}
 
-	// Start the WebSocket client
-	wsClient := NewWebSocketClient(conf, client, logger)
+	// Start the WebSocket client, stopping it and the HTTP server gracefully
+	// on SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sig := <-signals
+		logger.Info("Shutting down", "signal", sig)
+		if err := wsClient.GracefulStop(conf.Server.shutdownTimeout()); err != nil {
+			logger.Error("Graceful stop failed", "error", err)
+		}
+		if httpServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), conf.Server.shutdownTimeout())
+			defer cancel()
+			if err := httpServer.Shutdown(ctx); err != nil {
+				logger.Error("HTTP server shutdown failed", "error", err)
+				httpServer.Close()
+			}
+		}
+	}()
 	wsClient.Start()
+	<-stopped
 }
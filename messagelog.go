@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// messagePayloadLimit caps how much of each payload is logged
+const messagePayloadLimit = 512
+
+// redactedMessageTypes carry credentials, so their payloads are never logged
+var redactedMessageTypes = map[string]bool{"auth": true}
+
+// logIncoming logs a message read from the task server at debug level
+func (w *WebSocketClient) logIncoming(ctx context.Context, msg WebSocketMessage) {
+	w.logMessage(ctx, "incoming", msg)
+}
+
+// logOutgoing logs a message queued for the task server at debug level
+func (w *WebSocketClient) logOutgoing(ctx context.Context, msg WebSocketMessage) {
+	w.logMessage(ctx, "outgoing", msg)
+}
+
+func (w *WebSocketClient) logMessage(ctx context.Context, direction string, msg WebSocketMessage) {
+	logger := loggerFromContext(ctx, w.logger)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []any{
+		"direction", direction,
+		"message_type", msg.Type,
+		"payload_size", len(msg.Payload),
+		"correlation_id", requestIDFromContext(ctx),
+	}
+	if w.config.Log.LogPayloads {
+		attrs = append(attrs, "payload", messagePayload(msg))
+	}
+	logger.DebugContext(ctx, "WebSocket message", attrs...)
+}
+
+func messagePayload(msg WebSocketMessage) string {
+	if redactedMessageTypes[msg.Type] {
+		return "REDACTED"
+	}
+	if len(msg.Payload) > messagePayloadLimit {
+		return string(msg.Payload[:messagePayloadLimit]) + "..."
+	}
+	return string(msg.Payload)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messageLogEntries returns the logged WebSocket messages
+func messageLogEntries(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(line, &entry))
+		if entry["msg"] == "WebSocket message" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func newMessageLogClient(t *testing.T, logPayloads bool) (*WebSocketClient, *bytes.Buffer) {
+	t.Helper()
+	config := MockConfig()
+	config.Log.LogPayloads = logPayloads
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return newTestWebSocketClient(t, config, WithWebSocketLogger(logger)), &logs
+}
+
+func TestLogMessages(t *testing.T) {
+	w, logs := newMessageLogClient(t, true)
+
+	w.handleMessage(nil, WebSocketMessage{
+		Type:    "models_update",
+		Payload: json.RawMessage(`{"request_id":"req-1","models":[]}`),
+	})
+	require.NoError(t, w.requestModels(nil))
+
+	entries := messageLogEntries(t, logs)
+	require.Len(t, entries, 2)
+
+	incoming := entries[0]
+	assert.Equal(t, "DEBUG", incoming["level"])
+	assert.Equal(t, "incoming", incoming["direction"])
+	assert.Equal(t, "models_update", incoming["message_type"])
+	assert.Equal(t, float64(34), incoming["payload_size"])
+	assert.Equal(t, "req-1", incoming["correlation_id"])
+	assert.Equal(t, `{"request_id":"req-1","models":[]}`, incoming["payload"])
+
+	outgoing := entries[1]
+	assert.Equal(t, "outgoing", outgoing["direction"])
+	assert.Equal(t, "get_models", outgoing["message_type"])
+	assert.Equal(t, float64(0), outgoing["payload_size"])
+}
+
+func TestLogMessages_Payloads(t *testing.T) {
+	t.Run("omitted by default", func(t *testing.T) {
+		w, logs := newMessageLogClient(t, false)
+		w.logOutgoing(context.Background(), WebSocketMessage{Type: "task_update", Payload: json.RawMessage(`{"prompt":"a cat"}`)})
+
+		entries := messageLogEntries(t, logs)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "task_update", entries[0]["message_type"])
+		assert.NotContains(t, entries[0], "payload")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		w, logs := newMessageLogClient(t, true)
+		payload := `"` + strings.Repeat("a", 1000) + `"`
+		w.logOutgoing(context.Background(), WebSocketMessage{Type: "task_update", Payload: json.RawMessage(payload)})
+
+		entries := messageLogEntries(t, logs)
+		require.Len(t, entries, 1)
+		assert.Equal(t, float64(len(payload)), entries[0]["payload_size"])
+		assert.Equal(t, payload[:messagePayloadLimit]+"...", entries[0]["payload"])
+	})
+
+	t.Run("credentials redacted", func(t *testing.T) {
+		w, logs := newMessageLogClient(t, true)
+		w.logOutgoing(context.Background(), WebSocketMessage{Type: "auth", Payload: json.RawMessage(`{"password":"hunter2"}`)})
+
+		assert.NotContains(t, logs.String(), "hunter2")
+		assert.Equal(t, "REDACTED", messageLogEntries(t, logs)[0]["payload"])
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// currentConfigVersion is the config schema this build understands
+const currentConfigVersion = 1
+
+type configMigration struct {
+	summary string
+	migrate func(*Config)
+}
+
+// configMigrations[v] upgrades a version v config to v+1
+var configMigrations = []configMigration{
+	{
+		summary: "model loras/loraweights strings moved into the lora_stack list",
+		migrate: MigrateV0toV1,
+	},
+}
+
+// MigrateV0toV1 moves the comma-separated loras string and its shared
+// weight into lora_stack. Running it again changes nothing.
+func MigrateV0toV1(cfg *Config) {
+	for i := range cfg.Models {
+		m := &cfg.Models[i]
+		if m.Loras != "" {
+			m.LoraStack = mergeLoras(parseLoras(m.Loras, m.LoraWeights), m.LoraStack)
+			m.Loras = ""
+			m.LoraWeights = 0
+		}
+	}
+	cfg.Version = 1
+}
+
+// migrateConfig brings cfg up to currentConfigVersion
+func migrateConfig(cfg *Config) error {
+	if cfg.Version < 0 || cfg.Version > currentConfigVersion {
+		return fmt.Errorf("unsupported config version %d, this build supports up to %d", cfg.Version, currentConfigVersion)
+	}
+	for v := cfg.Version; v < currentConfigVersion; v++ {
+		configMigrations[v].migrate(cfg)
+		cfg.Version = v + 1
+	}
+	return nil
+}
+
+// PrintMigrationSummary explains what changed between two config versions
+func PrintMigrationSummary(oldVersion, newVersion int) {
+	writeMigrationSummary(os.Stderr, oldVersion, newVersion)
+}
+
+func writeMigrationSummary(w io.Writer, oldVersion, newVersion int) {
+	if oldVersion >= newVersion {
+		return
+	}
+	fmt.Fprintf(w, "Config migrated from version %d to %d in memory, update the file to silence this:\n", oldVersion, newVersion)
+	for v := oldVersion; v < newVersion && v < len(configMigrations); v++ {
+		fmt.Fprintf(w, "  v%d -> v%d: %s\n", v, v+1, configMigrations[v].summary)
+	}
+}
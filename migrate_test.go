@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateV0toV1(t *testing.T) {
+	cfg := &Config{Models: []ModelConfig{
+		{Name: "SD", Loras: "flat_colors, add_detail", LoraWeights: 0.8},
+		{Name: "Flux", LoraStack: []LoraEntry{{Name: "kept", Weight: 1}}},
+	}}
+
+	MigrateV0toV1(cfg)
+
+	assert.Equal(t, 1, cfg.Version)
+	assert.Empty(t, cfg.Models[0].Loras)
+	assert.Zero(t, cfg.Models[0].LoraWeights)
+	assert.Equal(t, []LoraEntry{{Name: "flat_colors", Weight: 0.8}, {Name: "add_detail", Weight: 0.8}}, cfg.Models[0].LoraStack)
+	assert.Equal(t, []LoraEntry{{Name: "kept", Weight: 1}}, cfg.Models[1].LoraStack)
+
+	// running it again is a no-op
+	before := cfg.Models[0].LoraStack
+	MigrateV0toV1(cfg)
+	assert.Equal(t, before, cfg.Models[0].LoraStack)
+	assert.Equal(t, 1, cfg.Version)
+}
+
+// TestMigrateV0toV1_SameRequest verifies migration doesn't change what is sent to the API
+func TestMigrateV0toV1_SameRequest(t *testing.T) {
+	legacy := MockConfig()
+	legacy.Models[0].Loras = "GyateGyate_pdxl_Incrs_v1"
+	legacy.Models[0].LoraWeights = 2.7
+
+	migrated := MockConfig()
+	migrated.Models[0].Loras = "GyateGyate_pdxl_Incrs_v1"
+	migrated.Models[0].LoraWeights = 2.7
+	MigrateV0toV1(migrated)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	req := GenerateRequest{Prompt: "a cat", ModelID: 1}
+	legacyParams, err := NewClient(legacy, logger).generationParams(req, legacy.Models[0])
+	require.NoError(t, err)
+	migratedParams, err := NewClient(migrated, logger).generationParams(req, migrated.Models[0])
+	require.NoError(t, err)
+
+	want := legacyParams.requestBody("s")
+	got := migratedParams.requestBody("s")
+	assert.Equal(t, want["prompt"], got["prompt"])
+	assert.Equal(t, "GyateGyate_pdxl_Incrs_v1", got["loras"])
+	assert.Equal(t, "2.7", got["loraweights"])
+}
+
+func TestLoadConfig_Migrates(t *testing.T) {
+	path := writeTempFile(t, "config.yaml", `
+models:
+  - name: SD
+    string: sd_xl
+    loras: "add_detail"
+    loraweights: 0.5
+`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, currentConfigVersion, cfg.Version)
+	assert.Equal(t, []LoraEntry{{Name: "add_detail", Weight: 0.5}}, cfg.Models[0].LoraStack)
+	assert.NotContains(t, cfg.Models[0].Options, "lora_stack")
+}
+
+func TestLoadConfig_NewerVersion(t *testing.T) {
+	path := writeTempFile(t, "config.yaml", "version: 99\n")
+	_, err := LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func TestWriteMigrationSummary(t *testing.T) {
+	var buf bytes.Buffer
+	writeMigrationSummary(&buf, 0, 1)
+	assert.Contains(t, buf.String(), "version 0 to 1")
+	assert.Contains(t, buf.String(), "lora_stack")
+
+	buf.Reset()
+	writeMigrationSummary(&buf, 1, 1)
+	assert.Empty(t, buf.String())
+}
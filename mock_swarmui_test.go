@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockSwarmUIServer is a fake SwarmUI API, plus the image upload endpoints
+// of the task server, for tests. Start it over HTTP for the API or over
+// TLS for uploads.
+type MockSwarmUIServer struct {
+	mu sync.Mutex
+
+	sessionLatency    time.Duration
+	generationLatency time.Duration
+	jitter            time.Duration
+	nextImage         []byte
+	failOnNth         map[int]mockError
+	version           string
+	models            []string
+	unavailable       map[string]bool
+	expired           map[string]bool
+	jobStatus         *JobStatus // GetJobStatus answers 404 when nil
+
+	requests    int
+	calls       map[string]int
+	userAgents  []string
+	generations []map[string]any
+	uploads     []mockUpload
+	active      map[string]int // generations in flight per session
+	maxActive   map[string]int
+}
+
+type mockError struct {
+	status     int
+	body       string
+	retryAfter string // Retry-After header, if any
+}
+
+type mockUpload struct {
+	Path string
+	Name string
+	Data []byte
+}
+
+// mockImage is the deterministic 16x16 grey gradient PNG served when no
+// response is queued, busy enough to pass the entropy check
+var mockImage = func() []byte {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}()
+
+func NewMockSwarmUIServer() *MockSwarmUIServer {
+	return &MockSwarmUIServer{
+		models:      []string{"default_model.safetensors"},
+		unavailable: make(map[string]bool),
+		failOnNth:   make(map[int]mockError),
+		expired:     make(map[string]bool),
+		calls:       make(map[string]int),
+		active:      make(map[string]int),
+		maxActive:   make(map[string]int),
+	}
+}
+
+// Start serves the mock over plain HTTP, the way the SwarmUI API is reached
+func (m *MockSwarmUIServer) Start() *httptest.Server {
+	return httptest.NewServer(m)
+}
+
+// StartTLS serves the mock over HTTPS, the way uploads are sent
+func (m *MockSwarmUIServer) StartTLS() *httptest.Server {
+	return httptest.NewTLSServer(m)
+}
+
+// SetSessionLatency delays every /API/GetNewSession response
+func (m *MockSwarmUIServer) SetSessionLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionLatency = d
+}
+
+// SetGenerationLatency delays every /API/GenerateText2Image response
+func (m *MockSwarmUIServer) SetGenerationLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generationLatency = d
+}
+
+// SetLatencyJitter adds a random delay up to d on top of the session and
+// generation latencies
+func (m *MockSwarmUIServer) SetLatencyJitter(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jitter = d
+}
+
+// SetNextImageResponse serves data for the next image download only
+func (m *MockSwarmUIServer) SetNextImageResponse(data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextImage = data
+}
+
+// SetFailOnNthRequest answers the nth requests (counting every endpoint from
+// the start, 1-based) with a 500
+func (m *MockSwarmUIServer) SetFailOnNthRequest(ns ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range ns {
+		m.failOnNth[n] = mockError{status: http.StatusInternalServerError, body: "injected failure\n"}
+	}
+}
+
+// SetErrorOnNthRequest answers the nth request with status and a JSON body
+func (m *MockSwarmUIServer) SetErrorOnNthRequest(n, status int, body string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failOnNth[n] = mockError{status: status, body: body}
+}
+
+// SetRateLimitOnNthRequest answers the nth request with a 429 carrying
+// retryAfter as its Retry-After header
+func (m *MockSwarmUIServer) SetRateLimitOnNthRequest(n int, retryAfter string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failOnNth[n] = mockError{status: http.StatusTooManyRequests, body: "rate limited\n", retryAfter: retryAfter}
+}
+
+// SetVersion sets the X-SwarmUI-Version header sent with every response
+func (m *MockSwarmUIServer) SetVersion(version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.version = version
+}
+
+// SetModels replaces the files listed by /API/ListModels
+func (m *MockSwarmUIServer) SetModels(names ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = names
+}
+
+// SetUnavailableModels makes generation with these model strings report the
+// model as not found
+func (m *MockSwarmUIServer) SetUnavailableModels(models ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, model := range models {
+		m.unavailable[model] = true
+	}
+}
+
+// ExpireSession makes generation with session id fail as SwarmUI does for
+// an unknown session
+func (m *MockSwarmUIServer) ExpireSession(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expired[id] = true
+}
+
+// CallCount returns how many requests hit path; image downloads are counted
+// under "/images/"
+func (m *MockSwarmUIServer) CallCount(path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[path]
+}
+
+// UserAgents returns the User-Agent header of every request, in order
+func (m *MockSwarmUIServer) UserAgents() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.userAgents...)
+}
+
+// Generations returns the decoded /API/GenerateText2Image request bodies
+func (m *MockSwarmUIServer) Generations() []map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]any(nil), m.generations...)
+}
+
+// SetJobStatus makes GetJobStatus report step current of total for every
+// session; until it is called GetJobStatus answers 404
+func (m *MockSwarmUIServer) SetJobStatus(current, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobStatus = &JobStatus{CurrentStep: current, TotalSteps: total}
+}
+
+// MaxConcurrentGenerations returns the most generations that were in
+// flight at once in the session
+func (m *MockSwarmUIServer) MaxConcurrentGenerations(sessionID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.maxActive[sessionID]
+}
+
+// Uploads returns the files received on /image and /images
+func (m *MockSwarmUIServer) Uploads() []mockUpload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mockUpload(nil), m.uploads...)
+}
+
+func (m *MockSwarmUIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if strings.HasPrefix(path, "/images/") {
+		path = "/images/"
+	}
+
+	m.mu.Lock()
+	m.requests++
+	m.calls[path]++
+	m.userAgents = append(m.userAgents, r.UserAgent())
+	failure, fail := m.failOnNth[m.requests]
+	version := m.version
+	m.mu.Unlock()
+
+	if version != "" {
+		w.Header().Set(swarmUIVersionHeader, version)
+	}
+	if fail {
+		if failure.retryAfter != "" {
+			w.Header().Set("Retry-After", failure.retryAfter)
+		}
+		w.WriteHeader(failure.status)
+		io.WriteString(w, failure.body)
+		return
+	}
+	if path == "/API/GetJobStatus" && r.Method == http.MethodGet {
+		m.handleJobStatus(w, r)
+		return
+	}
+	if path != "/images/" && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch path {
+	case "/API/GetNewSession":
+		m.handleSession(w)
+	case "/API/GenerateText2Image":
+		m.handleGenerate(w, r)
+	case "/API/ListModels":
+		m.handleListModels(w)
+	case "/images/":
+		m.handleDownload(w)
+	case "/image", "/images":
+		m.handleUpload(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// randomJitter must be called with m.mu held
+func (m *MockSwarmUIServer) randomJitter() time.Duration {
+	if m.jitter <= 0 {
+		return 0
+	}
+	return rand.N(m.jitter)
+}
+
+func (m *MockSwarmUIServer) handleSession(w http.ResponseWriter) {
+	m.mu.Lock()
+	latency := m.sessionLatency + m.randomJitter()
+	id := fmt.Sprintf("mock-session-%d", m.calls["/API/GetNewSession"])
+	m.mu.Unlock()
+
+	time.Sleep(latency)
+	json.NewEncoder(w).Encode(SessionResponse{SessionID: id})
+}
+
+func (m *MockSwarmUIServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	model, _ := body["model"].(string)
+	sessionID, _ := body["session_id"].(string)
+
+	m.mu.Lock()
+	latency := m.generationLatency + m.randomJitter()
+	m.generations = append(m.generations, body)
+	missing := m.unavailable[model]
+	expired := m.expired[sessionID]
+	n := len(m.generations)
+	m.active[sessionID]++
+	m.maxActive[sessionID] = max(m.maxActive[sessionID], m.active[sessionID])
+	m.mu.Unlock()
+
+	time.Sleep(latency)
+	m.mu.Lock()
+	m.active[sessionID]--
+	m.mu.Unlock()
+	if expired {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error_id": "invalid_session_id"})
+		return
+	}
+	if missing {
+		// SwarmUI reports this as a 200 with an error field
+		json.NewEncoder(w).Encode(map[string]string{"error": "Model not found: " + model})
+		return
+	}
+	count := 1
+	if images, ok := body["images"].(float64); ok && images > 1 {
+		count = int(images)
+	}
+	var resp ImageResponse
+	for i := 1; i <= count; i++ {
+		resp.Images = append(resp.Images, fmt.Sprintf("images/mock-%d-%d.png", n, i))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (m *MockSwarmUIServer) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	status := m.jobStatus
+	m.mu.Unlock()
+
+	if status == nil || r.URL.Query().Get("session_id") == "" {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func (m *MockSwarmUIServer) handleListModels(w http.ResponseWriter) {
+	m.mu.Lock()
+	var files []RemoteModel
+	for _, name := range m.models {
+		files = append(files, RemoteModel{Name: name})
+	}
+	m.mu.Unlock()
+
+	json.NewEncoder(w).Encode(listModelsResponse{Files: files})
+}
+
+func (m *MockSwarmUIServer) handleDownload(w http.ResponseWriter) {
+	m.mu.Lock()
+	data := mockImage
+	if m.nextImage != nil {
+		data, m.nextImage = m.nextImage, nil
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+func (m *MockSwarmUIServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.uploads = append(m.uploads, mockUpload{Path: r.URL.Path, Name: header.Filename, Data: data})
+	m.mu.Unlock()
+}
+
+// useMockAPI points the config's SwarmUI API at server
+func useMockAPI(config *Config, server *httptest.Server) {
+	config.API.Host = strings.TrimPrefix(server.URL, "http://")
+	config.API.Port = ""
+}
+
+// useMockUploads points the config's upload server at a StartTLS server
+func useMockUploads(config *Config, server *httptest.Server) {
+	config.Server.Host = strings.TrimPrefix(server.URL, "https://")
+	config.Server.Port = ""
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Stand-ins for the API's session and image URLs while generation is mocked
+const (
+	mockSessionID = "mock-session"
+	mockImageURL  = "mock://image"
+)
+
+// mockGeneration is the canned outcome of every generation
+type mockGeneration struct {
+	image []byte
+	err   error
+}
+
+// WithMockGeneration answers every generation with imgData, or fails it
+// with err, without contacting the API. Session, generation and download
+// are each stubbed, so prompt checks, caching, stats and post-processing
+// still run.
+func WithMockGeneration(imgData []byte, err error) ClientOption {
+	return func(c *Client) {
+		c.mock = &mockGeneration{image: imgData, err: err}
+	}
+}
+
+// imageURLs returns a mock URL per requested image, or the mocked error
+func (m *mockGeneration) imageURLs(params GenerationParams) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	urls := make([]string, max(params.Images, 1))
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/%d", mockImageURL, i)
+	}
+	return urls, nil
+}
+
+func (m *mockGeneration) download() []byte {
+	return bytes.Clone(m.image)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockGenerationClient builds a client with mocked generation whose API
+// fails the test if it's ever contacted
+func newMockGenerationClient(t *testing.T, image []byte, err error, opts ...ClientOption) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("mocked generation reached the API: %s %s", r.Method, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "SD"
+	opts = append(opts, WithMockGeneration(image, err))
+	return NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+}
+
+func TestWithMockGeneration(t *testing.T) {
+	placeholder := testPNG(t, 8, 8)
+	client := newMockGenerationClient(t, placeholder, nil)
+
+	image, err := client.GenerateImage("a cat", 1)
+	require.NoError(t, err)
+	assert.Equal(t, placeholder, image)
+
+	images, err := client.GenerateImages(context.Background(), "a cat", 1, 2)
+	require.NoError(t, err)
+	assert.Len(t, images, 2)
+
+	stats := client.GetModelStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "SD", stats[0].Name)
+	assert.Equal(t, int64(2), stats[0].TotalRequests)
+}
+
+func TestWithMockGeneration_Error(t *testing.T) {
+	injected := errors.New("backend exploded")
+	client := newMockGenerationClient(t, nil, injected)
+
+	_, err := client.GenerateImage("a cat", 1)
+	assert.ErrorIs(t, err, injected)
+	assert.Equal(t, int64(1), client.GetModelStats()[0].Failures)
+}
+
+func TestWithMockGeneration_Cache(t *testing.T) {
+	cache := NewLRUCache(4)
+	client := newMockGenerationClient(t, testPNG(t, 8, 8), nil, WithGenerationCache(cache))
+
+	req := GenerateRequest{Prompt: "a cat", ModelID: 1, Seed: 42}
+	_, err := client.Generate(context.Background(), req)
+	require.NoError(t, err)
+	_, err = client.Generate(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, cache.Len())
+	assert.Equal(t, int64(1), client.GetModelStats()[0].TotalRequests, "second generation is a cache hit")
+}
+
+func TestHandleTTITask_MockGeneration(t *testing.T) {
+	placeholder := testPNG(t, 8, 8)
+	client := newMockGenerationClient(t, placeholder, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewWebSocketClient(client.config, client, logger, WithConnectionLimiter(NewConnectionLimiter(1)))
+	w.out.Store(newOutbox(256, &w.droppedMessages, logger))
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+
+	assert.Equal(t, StatusCompleted, task.Status())
+	sent, image := sentResult(t, w)
+	assert.Equal(t, task.UUID, sent.UUID)
+	assert.Equal(t, placeholder, image)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// modelsMarkdownHeader is the header and delimiter row of ModelsToMarkdown
+const modelsMarkdownHeader = "| Name | String | Width | Height | Steps | Cfgscale | Loras | LoraWeights | Capabilities |\n" +
+	"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n"
+
+// MarkdownRow renders the model as a row of the ModelsToMarkdown table
+func (m ModelConfig) MarkdownRow() string {
+	cells := []string{
+		m.Name,
+		m.String,
+		strconv.Itoa(m.Width),
+		strconv.Itoa(m.Height),
+		strconv.Itoa(m.Steps),
+		strconv.FormatFloat(float64(m.Cfgscale), 'g', -1, 32),
+		m.Loras,
+		strconv.FormatFloat(float64(m.LoraWeights), 'g', -1, 32),
+		strings.Join(m.Capabilities, ", "),
+	}
+	for i, cell := range cells {
+		cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	return "| " + strings.Join(cells, " | ") + " |"
+}
+
+// ModelsToMarkdown renders the models as a GitHub-flavored markdown table
+func ModelsToMarkdown(models []ModelConfig) string {
+	var b strings.Builder
+	b.WriteString(modelsMarkdownHeader)
+	for _, m := range models {
+		b.WriteString(m.MarkdownRow())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelsToMarkdown(t *testing.T) {
+	models := []ModelConfig{
+		{Name: "sdxl", String: "sdxl_base.safetensors", Width: 1024, Height: 1024, Steps: 30, Cfgscale: 7.5, Capabilities: []string{"sdxl", "fp16"}},
+		{Name: "anime", String: "anime|v2.safetensors", Width: 512, Height: 768, Steps: 20, Cfgscale: 6, Loras: "detail", LoraWeights: 0.8},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(ModelsToMarkdown(models), "\n"), "\n")
+	assert.Equal(t, []string{
+		"| Name | String | Width | Height | Steps | Cfgscale | Loras | LoraWeights | Capabilities |",
+		"| --- | --- | --- | --- | --- | --- | --- | --- | --- |",
+		"| sdxl | sdxl_base.safetensors | 1024 | 1024 | 30 | 7.5 |  | 0 | sdxl, fp16 |",
+		`| anime | anime\|v2.safetensors | 512 | 768 | 20 | 6 | detail | 0.8 |  |`,
+	}, lines)
+}
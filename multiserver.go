@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MultiServerClient runs one WebSocketClient per task server
+type MultiServerClient struct {
+	workers map[string]*WebSocketClient // by task server address
+}
+
+// NewMultiServerClient groups workers, each of which must connect to a
+// different task server. Workers without a connection limiter share one,
+// sized by the first worker's server.max_connections.
+func NewMultiServerClient(workers ...*WebSocketClient) (*MultiServerClient, error) {
+	m := &MultiServerClient{workers: make(map[string]*WebSocketClient, len(workers))}
+	for _, w := range workers {
+		addr := w.serverAddr()
+		if _, ok := m.workers[addr]; ok {
+			return nil, fmt.Errorf("two workers for task server %s", addr)
+		}
+		m.workers[addr] = w
+	}
+	if len(workers) > 0 {
+		limiter := NewConnectionLimiter(workers[0].config.Server.maxConnections())
+		for _, w := range workers {
+			if w.limiter == nil {
+				w.limiter = limiter
+			}
+		}
+	}
+	return m, nil
+}
+
+// serverAddr is the host:port of the task server the client connects to
+func (w *WebSocketClient) serverAddr() string {
+	return net.JoinHostPort(w.config.Server.Host, w.config.Server.Port)
+}
+
+// Start keeps every worker connected until GracefulStop is called
+func (m *MultiServerClient) Start() {
+	var wg sync.WaitGroup
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Start()
+		}()
+	}
+	wg.Wait()
+}
+
+// GracefulStop stops every worker, giving each up to timeout to finish its
+// running task
+func (m *MultiServerClient) GracefulStop(timeout time.Duration) error {
+	errs := make(chan error, len(m.workers))
+	for addr, w := range m.workers {
+		go func() {
+			if err := w.GracefulStop(timeout); err != nil {
+				errs <- fmt.Errorf("%s: %w", addr, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	var all []error
+	for range m.workers {
+		all = append(all, <-errs)
+	}
+	return errors.Join(all...)
+}
+
+// HealthSummary is the combined state of a MultiServerClient's workers
+type HealthSummary struct {
+	TotalWorkers    int                  `json:"total_workers"`
+	Connected       int                  `json:"connected"`
+	Disconnected    int                  `json:"disconnected"`
+	TasksInFlight   int                  `json:"tasks_in_flight"`
+	QueueDepth      int                  `json:"queue_depth"`
+	LastConnectedAt map[string]time.Time `json:"last_connected_at"` // by task server address, for workers that ever connected
+
+	Workers []WorkerStatus `json:"workers"` // by task server address
+}
+
+// WorkerStatus is one worker's connection state, as listed in HealthSummary
+type WorkerStatus struct {
+	Server string `json:"server"` // task server address
+	ConnectionStatus
+}
+
+// HealthSummary snapshots each worker's connection and queue
+func (m *MultiServerClient) HealthSummary() HealthSummary {
+	summary := HealthSummary{
+		TotalWorkers:    len(m.workers),
+		LastConnectedAt: make(map[string]time.Time),
+		Workers:         make([]WorkerStatus, 0, len(m.workers)),
+	}
+	for addr, w := range m.workers {
+		status := w.ConnectionStatus()
+		if status.WebSocketConnected {
+			summary.Connected++
+		} else {
+			summary.Disconnected++
+		}
+		if status.LastConnectedAt != nil {
+			summary.LastConnectedAt[addr] = *status.LastConnectedAt
+		}
+		summary.QueueDepth += w.queueDepth()
+		summary.TasksInFlight += int(w.inFlight.Load())
+		summary.Workers = append(summary.Workers, WorkerStatus{Server: addr, ConnectionStatus: status})
+	}
+	slices.SortFunc(summary.Workers, func(a, b WorkerStatus) int { return strings.Compare(a.Server, b.Server) })
+	return summary
+}
+
+// HealthHandler serves the client's HealthSummary as JSON, with a 503 while
+// no worker is connected
+func HealthHandler(m *MultiServerClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		summary := m.HealthSummary()
+		w.Header().Set("Content-Type", "application/json")
+		if summary.Connected == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(summary)
+	})
+}
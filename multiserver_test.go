@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMultiServerClient_DuplicateServer(t *testing.T) {
+	config := MockConfig()
+	_, err := NewMultiServerClient(newTestWebSocketClient(t, config), newTestWebSocketClient(t, config))
+	assert.ErrorContains(t, err, "two workers for task server")
+}
+
+func TestMultiServerClient_HealthSummary(t *testing.T) {
+	var workers []*WebSocketClient
+	var releases []chan struct{}
+	for range 3 {
+		server, _, release := newCountingServer(t)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		w := newTestWebSocketClient(t, config)
+		w.reconnectDelay = time.Minute
+		workers = append(workers, w)
+		releases = append(releases, release)
+	}
+	m, err := NewMultiServerClient(workers...)
+	require.NoError(t, err)
+
+	initial := m.HealthSummary()
+	assert.Equal(t, 3, initial.TotalWorkers)
+	assert.Equal(t, 3, initial.Disconnected)
+	assert.Empty(t, initial.LastConnectedAt)
+	require.Len(t, initial.Workers, 3)
+	for _, worker := range initial.Workers {
+		assert.False(t, worker.WebSocketConnected)
+	}
+
+	go m.Start()
+	defer func() {
+		for _, release := range releases[1:] {
+			close(release)
+		}
+	}()
+	require.Eventually(t, func() bool { return m.HealthSummary().Connected == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// the first server drops its worker, which then waits out the reconnect delay
+	close(releases[0])
+	require.Eventually(t, func() bool { return m.HealthSummary().Connected == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	summary := m.HealthSummary()
+	assert.Equal(t, 3, summary.TotalWorkers)
+	assert.Equal(t, 1, summary.Disconnected)
+	assert.Len(t, summary.LastConnectedAt, 3, "the dropped worker keeps its last connection time")
+	for _, w := range workers {
+		assert.WithinDuration(t, time.Now(), summary.LastConnectedAt[w.serverAddr()], 5*time.Second)
+	}
+
+	server := httptest.NewServer(HealthHandler(m))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, float64(2), body["connected"])
+	assert.Equal(t, float64(1), body["disconnected"])
+
+	// each worker's own connection state
+	require.Len(t, body["workers"], 3)
+	var dropped map[string]any
+	for _, worker := range body["workers"].([]any) {
+		worker := worker.(map[string]any)
+		if worker["server"] == workers[0].serverAddr() {
+			dropped = worker
+		} else {
+			assert.Equal(t, true, worker["websocket_connected"])
+		}
+	}
+	require.NotNil(t, dropped)
+	assert.Equal(t, false, dropped["websocket_connected"])
+	assert.Contains(t, dropped, "reconnect_attempts")
+	assert.Contains(t, dropped, "next_retry_at")
+
+	assert.NoError(t, m.GracefulStop(time.Second))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GenerateOpenAPISpec describes the HTTP handlers as an OpenAPI 3.0 JSON
+// document. Response schemas are derived from the structs the handlers
+// encode, so they follow the code.
+func GenerateOpenAPISpec() []byte {
+	schemas := openAPISchemas{}
+	jsonResponse := func(description string, v any) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(v))},
+			},
+		}
+	}
+	textResponse := func(description string) map[string]any {
+		return map[string]any{"description": description}
+	}
+
+	paths := map[string]any{
+		"/ping": map[string]any{
+			"get": map[string]any{
+				"summary": "Check that the SwarmUI API is reachable",
+				"responses": map[string]any{
+					"200": jsonResponse("API reachable", PingResult{}),
+					"503": jsonResponse("API unreachable", PingResult{}),
+				},
+			},
+		},
+		"/health": map[string]any{
+			"get": map[string]any{
+				"summary": "Combined connection state of every worker",
+				"responses": map[string]any{
+					"200": jsonResponse("At least one worker connected", HealthSummary{}),
+					"503": jsonResponse("No worker connected", HealthSummary{}),
+				},
+			},
+		},
+		"/status": map[string]any{
+			"get": map[string]any{
+				"summary": "Task queue snapshot",
+				"responses": map[string]any{
+					"200": jsonResponse("Queue snapshot", DashboardStatus{}),
+				},
+			},
+		},
+		"/models": map[string]any{
+			"get": map[string]any{
+				"summary": "Configured models",
+				"responses": map[string]any{
+					"200": jsonResponse("Models in task model number order", []DashboardModel{}),
+				},
+			},
+		},
+		"/tasks": map[string]any{
+			"get": map[string]any{
+				"summary": "Tasks being handled",
+				"responses": map[string]any{
+					"200": jsonResponse("Tasks, oldest first", []RegisteredTask{}),
+				},
+			},
+		},
+		"/dashboard": map[string]any{
+			"get": map[string]any{
+				"summary": "Task queue dashboard page",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "HTML page",
+						"content":     map[string]any{"text/html": map[string]any{}},
+					},
+				},
+			},
+		},
+		"/dashboard.js": map[string]any{
+			"get": map[string]any{
+				"summary": "Dashboard script",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "JavaScript",
+						"content":     map[string]any{"text/javascript": map[string]any{}},
+					},
+				},
+			},
+		},
+		"/dashboard.css": map[string]any{
+			"get": map[string]any{
+				"summary": "Dashboard style sheet",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "CSS",
+						"content":     map[string]any{"text/css": map[string]any{}},
+					},
+				},
+			},
+		},
+		"/events/{uuid}": map[string]any{
+			"get": map[string]any{
+				"summary": "Stream a task's status changes until it finishes",
+				"parameters": []any{map[string]any{
+					"name":     "uuid",
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]any{"type": "string", "format": "uuid"},
+				}},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "Server-sent status_update events, each carrying the task as JSON",
+						"content":     map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}},
+					},
+					"400": textResponse("Invalid task uuid"),
+				},
+			},
+		},
+		"/benchmark/{model_id}": map[string]any{
+			"post": map[string]any{
+				"summary": "Time one generation with a model",
+				"parameters": []any{map[string]any{
+					"name":        "model_id",
+					"in":          "path",
+					"required":    true,
+					"description": "1-based index into the configured models",
+					"schema":      map[string]any{"type": "integer", "minimum": 1},
+				}},
+				"security": []any{
+					map[string]any{"bearerAuth": []any{}},
+					map[string]any{"apiKeyHeader": []any{}},
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("Benchmark result", BenchmarkResult{}),
+					"401": textResponse("Missing or wrong API key"),
+					"404": textResponse("Unknown model"),
+					"502": textResponse("Generation failed"),
+				},
+			},
+		},
+		"/openapi.json": map[string]any{
+			"get": map[string]any{
+				"summary": "This document",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "OpenAPI 3.0 document",
+						"content":     map[string]any{"application/json": map[string]any{}},
+					},
+				},
+			},
+		},
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "genclient",
+			"version": packageVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth":   map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		panic(err) // only maps, slices and strings, which always encode
+	}
+	return data
+}
+
+// OpenAPIHandler serves GenerateOpenAPISpec at GET /openapi.json
+func OpenAPIHandler() http.Handler {
+	spec := GenerateOpenAPISpec()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+}
+
+// openAPISchemas collects the component schemas of the structs a spec
+// refers to, by type name
+type openAPISchemas map[string]any
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	uuidType          = reflect.TypeFor[uuid.UUID]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// ref returns a reference to t's component schema, adding it first
+func (s openAPISchemas) ref(t reflect.Type) map[string]any {
+	if _, ok := s[t.Name()]; !ok {
+		s[t.Name()] = nil // claimed, in case t refers to itself
+		s[t.Name()] = s.structSchema(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+// structSchema describes the JSON object encoding/json makes of t
+func (s openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schema describes the JSON value encoding/json makes of t
+func (s openAPISchemas) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case t.Implements(jsonMarshalerType), t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schema(t.Elem())
+	case reflect.Struct:
+		return s.ref(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	default:
+		return map[string]any{}
+	}
+}
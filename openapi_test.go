@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(GenerateOpenAPISpec(), &spec))
+
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Equal(t, packageVersion, spec.Info.Version)
+	// every route the HTTP server serves is described, with its method
+	w := newTestWebSocketClient(t, MockConfig())
+	health, err := NewMultiServerClient(w)
+	require.NoError(t, err)
+	routes := httpRoutes(HTTPServerConfig{}, w.client, w, health, NewTaskEvents())
+	for pattern := range routes {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		require.Contains(t, spec.Paths, path)
+		if method != "" {
+			assert.Contains(t, spec.Paths[path], strings.ToLower(method), pattern)
+		}
+	}
+	assert.Len(t, spec.Paths, len(routes), "no paths that aren't served")
+	assert.Contains(t, spec.Paths["/benchmark/{model_id}"], "post")
+	assert.Contains(t, spec.Paths["/events/{uuid}"]["get"].(map[string]any)["responses"].(map[string]any)["200"].(map[string]any)["content"], "text/event-stream")
+
+	ping := spec.Components.Schemas["PingResult"]
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, ping.Properties["client_time"])
+	assert.Equal(t, map[string]any{"type": "integer", "format": "int64"}, ping.Properties["backend_latency_ms"])
+	assert.NotContains(t, ping.Required, "backend_error", "omitempty fields are optional")
+
+	// nested structs become their own components
+	status := spec.Components.Schemas["DashboardStatus"]
+	assert.Equal(t, "#/components/schemas/InFlightTask", status.Properties["in_flight"]["items"].(map[string]any)["$ref"])
+	assert.Contains(t, spec.Components.Schemas, "InFlightTask")
+	assert.Equal(t, "uuid", spec.Components.Schemas["InFlightTask"].Properties["uuid"]["format"])
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	server := httptest.NewServer(OpenAPIHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(GenerateOpenAPISpec()), string(body))
+
+	resp, err = http.Post(server.URL+"/openapi.json", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// orientationJPEGQuality is the quality reoriented JPEGs are re-encoded with
+const orientationJPEGQuality = 95
+
+// CorrectOrientation applies a JPEG's EXIF orientation to its pixels, so
+// the API sees the photo the way it is displayed. Images without EXIF
+// orientation, or already upright, are returned unchanged.
+func CorrectOrientation(imageData []byte) ([]byte, error) {
+	x, err := exif.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return imageData, nil // not a JPEG, or no EXIF
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return imageData, nil
+	}
+	orientation, err := tag.Int(0)
+	if err != nil || orientation <= 1 || orientation > 8 {
+		return imageData, nil
+	}
+
+	src, err := jpeg.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, reorient(src, orientation), &jpeg.Options{Quality: orientationJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// reorient returns src transformed as EXIF orientation 2-8 describes:
+// mirrored, rotated, or both
+func reorient(src image.Image, orientation int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	size := image.Pt(w, h)
+	if orientation >= 5 {
+		size = image.Pt(h, w) // rotated a quarter turn
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 270 clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
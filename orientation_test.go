@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orientedJPEG encodes a 32x16 image, red on top and blue below, with an
+// EXIF orientation tag when orientation is non-zero
+func orientedJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if y >= 8 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}))
+	if orientation == 0 {
+		return buf.Bytes()
+	}
+
+	// big-endian TIFF with one IFD entry: Orientation (0x0112), SHORT, 1 value
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	data := buf.Bytes()
+	var out bytes.Buffer
+	out.Write(data[:2]) // SOI
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+// isRed tells the red and blue halves apart despite JPEG noise
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > b
+}
+
+func TestCorrectOrientation(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		width       int
+		height      int
+		redAt       image.Point // a pixel from the original top rows
+		blueAt      image.Point // a pixel from the original bottom rows
+	}{
+		{3, 32, 16, image.Pt(16, 12), image.Pt(16, 3)},
+		{6, 16, 32, image.Pt(12, 16), image.Pt(3, 16)},
+		{8, 16, 32, image.Pt(3, 16), image.Pt(12, 16)},
+	}
+	for _, tt := range tests {
+		corrected, err := CorrectOrientation(orientedJPEG(t, tt.orientation))
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(corrected))
+		require.NoError(t, err)
+
+		assert.Equal(t, image.Pt(tt.width, tt.height), img.Bounds().Size(), "orientation %d", tt.orientation)
+		assert.True(t, isRed(img.At(tt.redAt.X, tt.redAt.Y)), "orientation %d: top rows moved to %v", tt.orientation, tt.redAt)
+		assert.False(t, isRed(img.At(tt.blueAt.X, tt.blueAt.Y)), "orientation %d: bottom rows moved to %v", tt.orientation, tt.blueAt)
+	}
+}
+
+func TestCorrectOrientation_Unchanged(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"no exif":   orientedJPEG(t, 0),
+		"upright":   orientedJPEG(t, 1),
+		"png":       testPNG(t, 8, 8),
+		"not image": []byte("hello"),
+	} {
+		corrected, err := CorrectOrientation(data)
+		require.NoError(t, err, name)
+		assert.Equal(t, data, corrected, name)
+	}
+}
+
+func TestControlNetParams_CorrectsOrientation(t *testing.T) {
+	photo := orientedJPEG(t, 8)
+	_, image, err := controlNetParams(GenerateRequest{
+		ControlNet:      &ControlNetConfig{Model: "canny"},
+		ControlNetImage: photo,
+	}, ModelConfig{})
+	require.NoError(t, err)
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(image))
+	require.NoError(t, err)
+	assert.Equal(t, 16, config.Width)
+	assert.Equal(t, 32, config.Height)
+}
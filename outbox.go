@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultSendBufferSize = 64
+	// controlBufferSize bounds the control messages waiting to jump the queue
+	controlBufferSize = 8
+	// defaultOutboundRate is the outbound messages per second when
+	// server.max_outbound_msg_per_sec is unset
+	defaultOutboundRate = 100.0
+)
+
+var errSendQueueFull = errors.New("send queue full")
+
+type outboundMessage struct {
+	ctx         context.Context // cancels the message while it waits for the rate limiter
+	messageType int
+	data        []byte
+	control     bool // skips the data queue and the rate limiter
+}
+
+// outbox serializes all writes for a single connection through one goroutine
+// so that a slow receiver can't pile up blocked senders. Control messages
+// such as pings and heartbeat replies go through their own channel, which
+// run drains first, so a backlog of data messages can't delay them.
+type outbox struct {
+	queue   chan outboundMessage
+	control chan outboundMessage
+	done    chan struct{}
+	once    sync.Once
+	dropped *atomic.Int64
+	logger  *slog.Logger
+
+	limiter *rate.Limiter      // paces data messages, nil for no limit
+	ctx     context.Context    // cancelled by close
+	cancel  context.CancelFunc // cancels ctx
+}
+
+func newOutbox(size int, dropped *atomic.Int64, logger *slog.Logger) *outbox {
+	if size <= 0 {
+		size = defaultSendBufferSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &outbox{
+		queue:   make(chan outboundMessage, size),
+		control: make(chan outboundMessage, controlBufferSize),
+		done:    make(chan struct{}),
+		dropped: dropped,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// newOutboundLimiter converts max_outbound_msg_per_sec, returning nil when
+// it's negative
+func newOutboundLimiter(config ServerConfig) *rate.Limiter {
+	switch {
+	case config.MaxOutboundMsgPerSec < 0:
+		return nil
+	case config.MaxOutboundMsgPerSec == 0:
+		return rate.NewLimiter(defaultOutboundRate, 1)
+	default:
+		return rate.NewLimiter(rate.Limit(config.MaxOutboundMsgPerSec), 1)
+	}
+}
+
+// enqueue schedules a message for sending, dropping it if the buffer is full.
+// Ping, pong and close frames are sent as control messages.
+func (o *outbox) enqueue(messageType int, data []byte) error {
+	return o.enqueueContext(context.Background(), messageType, data)
+}
+
+// enqueueContext is enqueue for a message that is dropped if ctx is
+// cancelled before the rate limiter lets it through
+func (o *outbox) enqueueContext(ctx context.Context, messageType int, data []byte) error {
+	return o.send(outboundMessage{ctx: ctx, messageType: messageType, data: data, control: isControl(messageType)})
+}
+
+// enqueueControl schedules a data frame, such as a heartbeat reply, ahead of
+// queued data messages and without waiting for the rate limiter
+func (o *outbox) enqueueControl(messageType int, data []byte) error {
+	return o.send(outboundMessage{messageType: messageType, data: data, control: true})
+}
+
+func (o *outbox) send(msg outboundMessage) error {
+	select {
+	case <-o.done:
+		return errors.New("connection closed")
+	default:
+	}
+
+	queue := o.queue
+	if msg.control {
+		queue = o.control
+	}
+	select {
+	case queue <- msg:
+		return nil
+	default:
+		dropped := o.dropped.Add(1)
+		o.logger.Warn("Outbound queue full, dropping message", "dropped_messages", dropped)
+		return errSendQueueFull
+	}
+}
+
+// run drains the queues until close is called or a write fails, sending
+// any waiting control message before the next data message
+func (o *outbox) run(write func(messageType int, data []byte) error) error {
+	for {
+		var msg outboundMessage
+		select {
+		case <-o.done:
+			return nil
+		case msg = <-o.control:
+		default:
+			select {
+			case <-o.done:
+				return nil
+			case msg = <-o.control:
+			case msg = <-o.queue:
+			}
+		}
+		if err := o.write(msg, write); err != nil {
+			return err
+		}
+	}
+}
+
+// write sends one message, skipping it if it's cancelled while rate
+// limited. Once the outbox is closed, run stops on its next pass.
+func (o *outbox) write(msg outboundMessage, write func(messageType int, data []byte) error) error {
+	if err := o.wait(msg); err != nil {
+		if o.ctx.Err() == nil {
+			o.logger.Debug("Outbound message cancelled while rate limited", "error", err)
+		}
+		return nil
+	}
+	return write(msg.messageType, msg.data)
+}
+
+// wait holds a data message until the rate limiter allows it. Control
+// messages skip the limiter so liveness checks aren't delayed.
+func (o *outbox) wait(msg outboundMessage) error {
+	if o.limiter == nil || msg.control {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(o.ctx)
+	defer cancel()
+	if msg.ctx != nil {
+		defer context.AfterFunc(msg.ctx, cancel)()
+	}
+	return o.limiter.Wait(ctx)
+}
+
+func (o *outbox) close() {
+	o.once.Do(func() {
+		close(o.done)
+		o.cancel()
+	})
+}
+
+func isControl(messageType int) bool {
+	return messageType == websocket.PingMessage || messageType == websocket.PongMessage || messageType == websocket.CloseMessage
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestOutbox_SlowReceiverIsBounded(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var dropped atomic.Int64
+	out := newOutbox(8, &dropped, logger)
+
+	// receiver takes the first message and then never returns
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	go out.run(func(messageType int, data []byte) error {
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	})
+	defer close(release)
+	defer out.close()
+
+	assert.NoError(t, out.enqueue(websocket.TextMessage, []byte("first")))
+	<-started
+
+	for i := 0; i < 1000; i++ {
+		out.enqueue(websocket.TextMessage, []byte("update"))
+	}
+
+	assert.Equal(t, 8, len(out.queue))
+	assert.Equal(t, int64(1000-8), dropped.Load())
+	assert.ErrorIs(t, out.enqueue(websocket.TextMessage, nil), errSendQueueFull)
+}
+
+func TestOutbox_DrainsInOrder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var dropped atomic.Int64
+	out := newOutbox(0, &dropped, logger)
+	assert.Equal(t, defaultSendBufferSize, cap(out.queue))
+
+	got := make(chan string, 3)
+	go out.run(func(messageType int, data []byte) error {
+		got <- string(data)
+		return nil
+	})
+	defer out.close()
+
+	for _, m := range []string{"a", "b", "c"} {
+		assert.NoError(t, out.enqueue(websocket.TextMessage, []byte(m)))
+	}
+	assert.Equal(t, "a", <-got)
+	assert.Equal(t, "b", <-got)
+	assert.Equal(t, "c", <-got)
+	assert.Zero(t, dropped.Load())
+}
+
+func TestOutbox_ControlFirst(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var dropped atomic.Int64
+	out := newOutbox(8, &dropped, logger)
+	out.limiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	defer out.close()
+
+	for _, m := range []string{"a", "b", "c"} {
+		require.NoError(t, out.enqueue(websocket.TextMessage, []byte(m)))
+	}
+	require.NoError(t, out.enqueue(websocket.PingMessage, nil))
+	require.NoError(t, out.enqueueControl(websocket.TextMessage, []byte("heartbeat")))
+
+	got := make(chan string, 5)
+	go out.run(func(messageType int, data []byte) error {
+		if messageType == websocket.PingMessage {
+			data = []byte("ping")
+		}
+		got <- string(data)
+		return nil
+	})
+
+	// control messages skip both the queued data and the rate limiter, which
+	// only lets "a" through
+	assert.Equal(t, "ping", <-got)
+	assert.Equal(t, "heartbeat", <-got)
+	assert.Equal(t, "a", <-got)
+	select {
+	case m := <-got:
+		t.Fatalf("rate limited message %q was sent", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSendTaskUpdate_RateLimited(t *testing.T) {
+	config := MockConfig()
+	config.Server.MaxOutboundMsgPerSec = 10
+	w := newTestWebSocketClient(t, config)
+	w.out.Load().limiter = newOutboundLimiter(config.Server)
+
+	var sent []time.Time
+	done := make(chan struct{})
+	go w.out.Load().run(func(messageType int, data []byte) error {
+		sent = append(sent, time.Now())
+		if len(sent) == 50 {
+			close(done)
+		}
+		return nil
+	})
+	defer w.out.Load().close()
+
+	start := time.Now()
+	task := NewTasukete(TTI, "a cat", 1)
+	for range 50 {
+		w.sendTaskUpdate(context.Background(), nil, task)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("updates were not all sent")
+	}
+	elapsed := sent[49].Sub(start)
+	assert.InDelta(t, 4.9, elapsed.Seconds(), 0.5, "50 updates at 10/s take about 5s")
+	assert.Less(t, sent[9].Sub(start), 1500*time.Millisecond, "updates are spread out, not held back")
+}
+
+func TestOutbox_RateLimitCancellation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var dropped atomic.Int64
+	out := newOutbox(8, &dropped, logger)
+	out.limiter = newOutboundLimiter(ServerConfig{MaxOutboundMsgPerSec: 1})
+
+	got := make(chan string, 8)
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- out.run(func(messageType int, data []byte) error {
+			got <- string(data)
+			return nil
+		})
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, out.enqueueContext(ctx, websocket.TextMessage, []byte("first")))
+	assert.Equal(t, "first", <-got)
+	require.NoError(t, out.enqueueContext(ctx, websocket.TextMessage, []byte("cancelled")))
+	require.NoError(t, out.enqueueContext(ctx, websocket.TextMessage, []byte("queued")))
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.NoError(t, out.enqueue(websocket.PingMessage, nil))
+	assert.Equal(t, "", <-got, "pings skip the limiter once the cancelled message is dropped")
+	select {
+	case m := <-got:
+		t.Fatalf("cancelled message %q was sent", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// closing the outbox stops a rate limited wait
+	require.NoError(t, out.enqueue(websocket.TextMessage, []byte("pending")))
+	require.NoError(t, out.enqueue(websocket.TextMessage, []byte("pending")))
+	time.Sleep(50 * time.Millisecond)
+	out.close()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("run kept waiting on the limiter after close")
+	}
+}
+
+func TestNewOutboundLimiter(t *testing.T) {
+	assert.Equal(t, rate.Limit(defaultOutboundRate), newOutboundLimiter(ServerConfig{}).Limit())
+	assert.Equal(t, rate.Limit(10), newOutboundLimiter(ServerConfig{MaxOutboundMsgPerSec: 10}).Limit())
+	assert.Nil(t, newOutboundLimiter(ServerConfig{MaxOutboundMsgPerSec: -1}))
+}
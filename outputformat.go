@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnsupportedOutputFormat means the requested output MIME type can't be
+// produced, either because it isn't one we know or because the API sent
+// back something else
+var ErrUnsupportedOutputFormat = errors.New("unsupported output format")
+
+// outputFormats maps the MIME types tasks may request to the API's
+// imageformat values
+var outputFormats = map[string]string{
+	"image/png":  "PNG",
+	"image/jpeg": "JPG",
+	"image/webp": "WEBP",
+}
+
+// apiImageFormat returns the imageformat value for a requested MIME type
+func apiImageFormat(mimeType string) (string, error) {
+	format, ok := outputFormats[mimeType]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedOutputFormat, mimeType)
+	}
+	return format, nil
+}
+
+// checkOutputFormat verifies the API produced the requested MIME type
+func checkOutputFormat(mimeType string, images [][]byte) error {
+	if mimeType == "" {
+		return nil
+	}
+	for i, data := range images {
+		if got := http.DetectContentType(data); got != mimeType {
+			return fmt.Errorf("%w: requested %s, image %d is %s", ErrUnsupportedOutputFormat, mimeType, i, got)
+		}
+	}
+	return nil
+}
+
+// outputExtension is the file extension for a requested MIME type, .png
+// when the task leaves it to the model
+func outputExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testImageOfType returns bytes sniffed as the given MIME type
+func testImageOfType(t *testing.T, mimeType string) []byte {
+	t.Helper()
+	switch mimeType {
+	case "image/jpeg":
+		img, _, err := image.Decode(bytes.NewReader(mockImage))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+		return buf.Bytes()
+	case "image/webp":
+		return append([]byte("RIFF\x24\x00\x00\x00WEBPVP8 "), make([]byte, 24)...)
+	default:
+		return mockImage
+	}
+}
+
+func TestHandleTTITask_OutputMimeType(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Options = map[string]any{"imageformat": "PNG"} // the model default
+	w := newTestWebSocketClient(t, config)
+
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{"image/png", "PNG"},
+		{"image/jpeg", "JPG"},
+		{"image/webp", "WEBP"},
+	}
+	for i, tt := range tests {
+		mock.SetNextImageResponse(testImageOfType(t, tt.mimeType))
+		task := NewTasukete(TTI, "a cat", 1)
+		task.OutputMimeType = tt.mimeType
+		w.handleTTITask(context.Background(), nil, task)
+
+		require.Equal(t, StatusCompleted, task.Status(), tt.mimeType)
+		assert.Equal(t, tt.want, mock.Generations()[i]["imageformat"], tt.mimeType)
+		_, image := sentResult(t, w)
+		assert.Equal(t, testImageOfType(t, tt.mimeType), image, tt.mimeType)
+	}
+}
+
+func TestGenerate_UnsupportedOutputFormat(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// not a format we can ask for
+	_, err := client.Generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1, OutputMimeType: "image/gif"})
+	assert.ErrorIs(t, err, ErrUnsupportedOutputFormat)
+	assert.Empty(t, mock.Generations())
+
+	// the API ignored the requested format and sent a PNG
+	_, err = client.Generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1, OutputMimeType: "image/jpeg"})
+	assert.ErrorIs(t, err, ErrUnsupportedOutputFormat)
+	assert.Len(t, mock.Generations(), 1)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"runtime/debug"
+)
+
+// recoverGoroutine runs fn, recovering a panic so it can't take the whole
+// process down. The panic is logged with its stack, and the result reports
+// whether there was one.
+func recoverGoroutine(fn func(), label string, logger *slog.Logger) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			logger.Error("Goroutine panicked", "goroutine", label, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	fn()
+	return false
+}
+
+// goRecover runs fn in a goroutine under recoverGoroutine. A panic drops
+// the current connection, so Start reconnects and replaces the goroutines
+// that died with it.
+func (w *WebSocketClient) goRecover(label string, fn func()) {
+	go func() {
+		if recoverGoroutine(fn, label, w.logger) {
+			if conn := w.conn.Load(); conn != nil {
+				conn.Close()
+			}
+		}
+	}()
+}
+
+// goRecoverTask runs fn in a goroutine under recoverGoroutine, for work on
+// behalf of a single task such as webhooks and progress polling. A panic
+// there only loses that work, so the connection stays up.
+func (w *WebSocketClient) goRecoverTask(label string, fn func()) {
+	go recoverGoroutine(fn, label, w.logger)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// panicLog records "Goroutine panicked" log lines, safe for concurrent use
+type panicLog struct {
+	mu      sync.Mutex
+	records []map[string]any
+}
+
+func (l *panicLog) Enabled(context.Context, slog.Level) bool { return true }
+func (l *panicLog) WithAttrs([]slog.Attr) slog.Handler       { return l }
+func (l *panicLog) WithGroup(string) slog.Handler            { return l }
+
+func (l *panicLog) Handle(_ context.Context, r slog.Record) error {
+	if r.Message != "Goroutine panicked" {
+		return nil
+	}
+	record := map[string]any{}
+	r.Attrs(func(a slog.Attr) bool {
+		record[a.Key] = a.Value.Any()
+		return true
+	})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+	return nil
+}
+
+func (l *panicLog) panics() []map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]map[string]any(nil), l.records...)
+}
+
+func TestRecoverGoroutine(t *testing.T) {
+	log := &panicLog{}
+	logger := slog.New(log)
+
+	assert.False(t, recoverGoroutine(func() {}, "quiet", logger))
+	assert.Empty(t, log.panics())
+
+	assert.True(t, recoverGoroutine(func() { panic("boom") }, "noisy", logger))
+	panics := log.panics()
+	require.Len(t, panics, 1)
+	assert.Equal(t, "noisy", panics[0]["goroutine"])
+	assert.Equal(t, "boom", panics[0]["panic"])
+	assert.Contains(t, panics[0]["stack"], "TestRecoverGoroutine")
+}
+
+func TestTaskWorker_RecoversPanickingTask(t *testing.T) {
+	server, _, release := newCountingServer(t)
+	defer close(release)
+	config := MockConfig()
+	useWebSocketServer(config, server)
+
+	log := &panicLog{}
+	logger := slog.New(log)
+	w := NewWebSocketClient(config, NewClient(config, logger), logger, WithConnectionLimiter(NewConnectionLimiter(1)))
+	w.RegisterTaskHandler(TTI, TaskHandlerFunc(func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+		panic("bad task")
+	}))
+
+	errs := make(chan error, 1)
+	go func() { errs <- w.connect() }()
+	require.Eventually(t, func() bool { return w.ConnectionStatus().WebSocketConnected }, 5*time.Second, 10*time.Millisecond)
+
+	w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+
+	// the panic is logged and the connection dropped for a reconnect
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection was not dropped after the panic")
+	}
+	panics := log.panics()
+	require.Len(t, panics, 1)
+	assert.Equal(t, "task worker", panics[0]["goroutine"])
+	assert.Equal(t, "bad task", panics[0]["panic"])
+	assert.True(t, strings.Contains(panics[0]["stack"].(string), "runTaskWorker"), "stack shows where it panicked")
+
+	// the task's bookkeeping is undone despite the panic
+	assert.Zero(t, w.inFlight.Load())
+	assert.Empty(t, w.DashboardStatus().InFlight)
+	w.preemption.mu.Lock()
+	assert.Nil(t, w.preemption.running)
+	w.preemption.mu.Unlock()
+}
+
+func TestGoRecoverTask_KeepsConnection(t *testing.T) {
+	server, _, release := newCountingServer(t)
+	defer close(release)
+	config := MockConfig()
+	useWebSocketServer(config, server)
+
+	log := &panicLog{}
+	logger := slog.New(log)
+	w := NewWebSocketClient(config, NewClient(config, logger), logger, WithConnectionLimiter(NewConnectionLimiter(1)))
+
+	errs := make(chan error, 1)
+	go func() { errs <- w.connect() }()
+	require.Eventually(t, func() bool { return w.ConnectionStatus().WebSocketConnected }, 5*time.Second, 10*time.Millisecond)
+
+	w.goRecoverTask("webhook", func() { panic("bad webhook") })
+	require.Eventually(t, func() bool { return len(log.panics()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "webhook", log.panics()[0]["goroutine"])
+
+	select {
+	case err := <-errs:
+		t.Fatalf("connection dropped after a task goroutine panicked: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	assert.True(t, w.ConnectionStatus().WebSocketConnected)
+	w.disconnect()
+	<-errs
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrQueueEmpty is returned by Dequeue when no task arrived within the timeout
+var ErrQueueEmpty = errors.New("queue empty")
+
+// PersistentQueue holds tasks outside the process, so they survive restarts
+// and, depending on the backend, can be shared between workers
+type PersistentQueue interface {
+	// Enqueue adds task to the back of the queue
+	Enqueue(ctx context.Context, task *Tasukete) error
+	// Dequeue removes and returns the task at the front of the queue,
+	// waiting up to timeout for one to arrive
+	Dequeue(ctx context.Context, timeout time.Duration) (*Tasukete, error)
+	// Len returns the number of queued tasks
+	Len(ctx context.Context) (int, error)
+	Close() error
+}
+
+// Persistent queue backends for queue.backend
+const (
+	QueueBackendFile  = "file"
+	QueueBackendRedis = "redis"
+)
+
+// QueueConfig selects where queued tasks are persisted. The file backend
+// uses server.pending_tasks_path.
+type QueueConfig struct {
+	Backend  string `yaml:"backend"`   // "file" (default) or "redis"
+	RedisURL string `yaml:"redis_url"` // e.g. redis://localhost:6379/0
+}
+
+// newPersistentQueue opens the configured backend, returning nil when the
+// file backend has no path
+func newPersistentQueue(config *Config) (PersistentQueue, error) {
+	switch config.Queue.Backend {
+	case "", QueueBackendFile:
+		if config.Server.PendingTasksPath == "" {
+			return nil, nil
+		}
+		return NewFileQueue(config.Server.PendingTasksPath), nil
+	case QueueBackendRedis:
+		return NewRedisQueue(config.Queue.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown queue backend: %s", config.Queue.Backend)
+	}
+}
+
+// filePollInterval is how often a blocked FileQueue.Dequeue rereads the file
+const filePollInterval = 100 * time.Millisecond
+
+// FileQueue keeps tasks as JSON lines in a file. It is safe for concurrent
+// use within one process only.
+type FileQueue struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileQueue(path string) *FileQueue {
+	return &FileQueue{path: path}
+}
+
+func (q *FileQueue) Enqueue(ctx context.Context, task *Tasukete) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	file, err := os.OpenFile(q.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (q *FileQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Tasukete, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		task, err := q.pop()
+		if err != nil || task != nil {
+			return task, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, ErrQueueEmpty
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(filePollInterval, time.Until(deadline))):
+		}
+	}
+}
+
+func (q *FileQueue) Len(ctx context.Context) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lines, err := q.readLines()
+	return len(lines), err
+}
+
+func (q *FileQueue) Close() error {
+	return nil
+}
+
+// pop removes the first task from the file, returning nil when it's empty
+func (q *FileQueue) pop() (*Tasukete, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lines, err := q.readLines()
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+	var task Tasukete
+	if err := json.Unmarshal(lines[0], &task); err != nil {
+		return nil, fmt.Errorf("failed to decode queued task: %w", err)
+	}
+
+	// write the rest next to the file and swap it in, so a crash can't
+	// leave a half-written queue
+	var rest bytes.Buffer
+	for _, line := range lines[1:] {
+		rest.Write(line)
+		rest.WriteByte('\n')
+	}
+	if err := writeFileAtomic(q.path, rest.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (q *FileQueue) readLines() ([][]byte, error) {
+	file, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20) // tasks may carry base64 guidance images
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, bytes.Clone(line))
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// persistentQueues opens every backend against throwaway storage
+func persistentQueues(t *testing.T) map[string]PersistentQueue {
+	t.Helper()
+	redisServer := miniredis.RunT(t)
+	redisQueue, err := NewRedisQueue("redis://" + redisServer.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { redisQueue.Close() })
+
+	return map[string]PersistentQueue{
+		QueueBackendFile:  NewFileQueue(filepath.Join(t.TempDir(), "pending.jsonl")),
+		QueueBackendRedis: redisQueue,
+	}
+}
+
+func TestPersistentQueue(t *testing.T) {
+	for name, queue := range persistentQueues(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			first := NewTasukete(TTI, "a cat", 1)
+			first.AddMetadata("seed", float64(42))
+			second := NewTasukete(TTI, "a dog", 2)
+			require.NoError(t, queue.Enqueue(ctx, first))
+			require.NoError(t, queue.Enqueue(ctx, second))
+
+			n, err := queue.Len(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			got, err := queue.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, first.UUID, got.UUID)
+			assert.Equal(t, "a cat", got.Prompt)
+			assert.Equal(t, float64(42), got.Metadata["seed"])
+
+			got, err = queue.Dequeue(ctx, time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, second.UUID, got.UUID)
+			assert.Equal(t, 2, got.Model)
+
+			_, err = queue.Dequeue(ctx, 10*time.Millisecond)
+			assert.ErrorIs(t, err, ErrQueueEmpty)
+		})
+	}
+}
+
+func TestPersistentQueue_DequeueWaits(t *testing.T) {
+	for name, queue := range persistentQueues(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			task := NewTasukete(TTI, "a cat", 1)
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				queue.Enqueue(ctx, task)
+			}()
+
+			got, err := queue.Dequeue(ctx, 2*time.Second)
+			require.NoError(t, err)
+			assert.Equal(t, task.UUID, got.UUID)
+		})
+	}
+}
+
+func TestRedisQueue_ConcurrentDequeue(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	queue, err := NewRedisQueue("redis://" + redisServer.Addr())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	ctx := context.Background()
+	const tasks = 20
+	for range tasks {
+		require.NoError(t, queue.Enqueue(ctx, NewTasukete(TTI, "a cat", 1)))
+	}
+
+	var mu sync.Mutex
+	seen := make(map[uuid.UUID]int)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task, err := queue.Dequeue(ctx, 0)
+				if errors.Is(err, ErrQueueEmpty) {
+					return
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				seen[task.UUID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, tasks)
+	for id, n := range seen {
+		assert.Equal(t, 1, n, "task %s dequeued %d times", id, n)
+	}
+	assert.False(t, redisServer.Exists(redisTasksKey), "the hash is emptied along with the list")
+}
+
+func TestRedisQueue_MissingTask(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	queue, err := NewRedisQueue("redis://" + redisServer.Addr())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	redisServer.RPush(redisQueueKey, "0b7e6a7e-0000-4000-8000-000000000000")
+	_, err = queue.Dequeue(context.Background(), 0)
+	assert.ErrorContains(t, err, "failed to load task")
+}
+
+func TestNewPersistentQueue(t *testing.T) {
+	config := MockConfig()
+	queue, err := newPersistentQueue(config)
+	require.NoError(t, err)
+	assert.Nil(t, queue, "file backend without a path")
+
+	config.Server.PendingTasksPath = filepath.Join(t.TempDir(), "pending.jsonl")
+	queue, err = newPersistentQueue(config)
+	require.NoError(t, err)
+	assert.IsType(t, &FileQueue{}, queue)
+
+	config.Queue = QueueConfig{Backend: QueueBackendRedis, RedisURL: "redis://" + miniredis.RunT(t).Addr()}
+	queue, err = newPersistentQueue(config)
+	require.NoError(t, err)
+	assert.IsType(t, &RedisQueue{}, queue)
+	queue.Close()
+
+	config.Queue = QueueConfig{Backend: "kafka"}
+	_, err = newPersistentQueue(config)
+	assert.Error(t, err)
+}
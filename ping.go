@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// PingResult reports whether the SwarmUI API answers and how fast
+type PingResult struct {
+	ClientTime       time.Time `json:"client_time"`
+	BackendLatencyMs int64     `json:"backend_latency_ms"`
+	BackendReachable bool      `json:"backend_reachable"`
+	BackendError     string    `json:"backend_error,omitempty"`
+}
+
+// Ping times a session request to the API. Latency is rounded up to whole
+// milliseconds, so a reachable API never reports 0.
+func (c *Client) Ping(ctx context.Context) PingResult {
+	result := PingResult{ClientTime: time.Now().UTC()}
+	start := time.Now()
+	_, err := c.getNewSession(ctx)
+	latency := time.Since(start)
+	result.BackendLatencyMs = int64((latency + time.Millisecond - 1) / time.Millisecond)
+	if err != nil {
+		result.BackendError = err.Error()
+		return result
+	}
+	result.BackendReachable = true
+	return result
+}
+
+// PingHandler serves GET /ping with the client's Ping result, answering 503
+// when the API is unreachable
+func PingHandler(c *Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result := c.Ping(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !result.BackendReachable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+}
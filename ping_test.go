@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getPing(t *testing.T, client *Client) (*http.Response, map[string]any) {
+	t.Helper()
+	server := httptest.NewServer(PingHandler(client))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ping")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp, body
+}
+
+func TestPingHandler(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetSessionLatency(20 * time.Millisecond)
+	backend := mock.Start()
+	defer backend.Close()
+
+	config := MockConfig()
+	useMockAPI(config, backend)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	resp, body := getPing(t, client)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, true, body["backend_reachable"])
+	assert.NotContains(t, body, "backend_error")
+
+	latency, ok := body["backend_latency_ms"].(float64)
+	require.True(t, ok, "backend_latency_ms is a number")
+	assert.Equal(t, float64(int64(latency)), latency, "backend_latency_ms is an integer")
+	assert.GreaterOrEqual(t, latency, float64(20))
+
+	clientTime, err := time.Parse(time.RFC3339, body["client_time"].(string))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), clientTime, 5*time.Second)
+	assert.Equal(t, 1, mock.CallCount("/API/GetNewSession"))
+}
+
+func TestPingHandler_BackendUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	config := MockConfig()
+	useMockAPI(config, backend)
+	backend.Close()
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	resp, body := getPing(t, client)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, false, body["backend_reachable"])
+	assert.NotEmpty(t, body["backend_error"])
+}
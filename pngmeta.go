@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// pngMetadataKeyword is the iTXt keyword generation parameters are stored under
+const pngMetadataKeyword = "parameters"
+
+var pngSignature = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+
+// EmbedPNGMetadata stores params as JSON in an iTXt chunk right after IHDR
+func EmbedPNGMetadata(imageData []byte, params GenerationParams) ([]byte, error) {
+	if !bytes.HasPrefix(imageData, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+
+	// IHDR is always the first chunk: length(4) + type(4) + data(13) + crc(4)
+	ihdrEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if len(imageData) < ihdrEnd || string(imageData[12:16]) != "IHDR" {
+		return nil, errors.New("malformed PNG: missing IHDR")
+	}
+
+	text, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// keyword, null, compression flag, compression method, language tag, null, translated keyword, null
+	var data bytes.Buffer
+	data.WriteString(pngMetadataKeyword)
+	data.Write([]byte{0, 0, 0, 0, 0})
+	data.Write(text)
+
+	out := make([]byte, 0, len(imageData)+data.Len()+12)
+	out = append(out, imageData[:ihdrEnd]...)
+	out = appendPNGChunk(out, "iTXt", data.Bytes())
+	out = append(out, imageData[ihdrEnd:]...)
+	return out, nil
+}
+
+// ExtractPNGMetadata reads generation parameters written by EmbedPNGMetadata
+func ExtractPNGMetadata(imageData []byte) (GenerationParams, error) {
+	var params GenerationParams
+	if !bytes.HasPrefix(imageData, pngSignature) {
+		return params, errors.New("not a PNG image")
+	}
+
+	prefix := append([]byte(pngMetadataKeyword), 0, 0, 0, 0, 0)
+	for pos := len(pngSignature); pos+8 <= len(imageData); {
+		length := int(binary.BigEndian.Uint32(imageData[pos:]))
+		chunkType := string(imageData[pos+4 : pos+8])
+		end := pos + 8 + length + 4
+		if end > len(imageData) {
+			return params, errors.New("malformed PNG: truncated chunk")
+		}
+		data := imageData[pos+8 : pos+8+length]
+		if chunkType == "iTXt" && bytes.HasPrefix(data, prefix) {
+			if err := json.Unmarshal(data[len(prefix):], &params); err != nil {
+				return params, fmt.Errorf("failed to decode metadata: %w", err)
+			}
+			return params, nil
+		}
+		pos = end
+	}
+	return params, errors.New("no generation metadata found")
+}
+
+func appendPNGChunk(out []byte, chunkType string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	start := len(out)
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestEmbedPNGMetadata(t *testing.T) {
+	params := GenerationParams{
+		Prompt:   "a cat in a hat, ünïcödé",
+		Model:    "OfficialStableDiffusion/sd_xl_base_1.0",
+		Width:    1024,
+		Height:   1024,
+		Steps:    4,
+		Cfgscale: 1.0,
+		Options:  map[string]any{"seed": float64(12345)},
+	}
+
+	out, err := EmbedPNGMetadata(testPNG(t, 4, 3), params)
+	require.NoError(t, err)
+
+	// the result must still be a valid PNG with the same pixels
+	img, err := png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 3), img.Bounds())
+
+	got, err := ExtractPNGMetadata(out)
+	require.NoError(t, err)
+	assert.Equal(t, params, got)
+}
+
+func TestEmbedPNGMetadata_NotPNG(t *testing.T) {
+	_, err := EmbedPNGMetadata([]byte("definitely not a png"), GenerationParams{})
+	assert.Error(t, err)
+}
+
+func TestExtractPNGMetadata_Missing(t *testing.T) {
+	_, err := ExtractPNGMetadata(testPNG(t, 2, 2))
+	assert.Error(t, err)
+}
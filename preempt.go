@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// preemptionState tracks the running task so a higher priority one can
+// take its place
+type preemptionState struct {
+	mu        sync.Mutex
+	running   *queuedTask
+	queued    *Tasukete // running task as the worker took it, for GracefulStop to persist
+	cancel    context.CancelFunc
+	preempted bool
+}
+
+// runQueuedTask handles a task from the queue under a context that
+// preemption can cancel
+func (w *WebSocketClient) runQueuedTask(qt queuedTask) {
+	ctx, cancel := context.WithCancel(qt.ctx)
+	defer cancel()
+
+	p := &w.preemption
+	p.mu.Lock()
+	p.running, p.queued, p.cancel, p.preempted = &qt, qt.task.snapshot(), cancel, false
+	p.mu.Unlock()
+
+	w.inFlight.Add(1)
+	w.taskStarted(qt.task)
+	// deferred so a panicking task doesn't leave the bookkeeping behind
+	defer func() {
+		w.taskFinished(qt.task)
+		w.inFlight.Add(-1)
+
+		p.mu.Lock()
+		p.running, p.queued, p.cancel = nil, nil, nil
+		p.mu.Unlock()
+	}()
+	w.handleTask(ctx, qt.conn, qt.task)
+}
+
+// preempt cancels the running task in favor of task when preemption is
+// enabled and task has the higher priority. task then runs as soon as the
+// worker is free, ahead of the queue.
+func (w *WebSocketClient) preempt(ctx context.Context, conn *websocket.Conn, task *Tasukete) bool {
+	if !w.config.Server.EnablePreemption {
+		return false
+	}
+
+	p := &w.preemption
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == nil || p.preempted || task.Priority <= p.running.task.Priority {
+		return false
+	}
+	select {
+	case w.urgent <- queuedTask{ctx: ctx, conn: conn, task: task}:
+	default:
+		return false
+	}
+
+	loggerFromContext(ctx, w.logger).Info("Preempting running task",
+		"uuid", task.UUID, "priority", task.Priority,
+		"preempted", p.running.task.UUID, "preempted_priority", p.running.task.Priority)
+	p.preempted = true
+	p.cancel()
+	return true
+}
+
+// requeuePreempted puts the task back in the queue as pending if it was
+// stopped by preemption, reporting whether it did. It runs on the worker,
+// alongside handleMessages enqueueing new tasks.
+func (w *WebSocketClient) requeuePreempted(task *Tasukete) bool {
+	p := &w.preemption
+	p.mu.Lock()
+	if p.running == nil || p.running.task != task || !p.preempted {
+		p.mu.Unlock()
+		return false
+	}
+	qt := *p.running
+	p.mu.Unlock()
+
+	logger := loggerFromContext(qt.ctx, w.logger)
+	if task.Status() != StatusPending {
+		if err := task.UpdateStatus(StatusPending); err != nil {
+			logger.Error("Failed to requeue preempted task", "uuid", task.UUID, "error", err)
+			return false
+		}
+	}
+	// the worker is this task's goroutine, so it can't block on its own
+	// queue: a full queue puts the task in the backlog instead
+	queued, err := w.pushTask(qt)
+	if err != nil {
+		logger.Info("Not requeueing preempted task while stopping", "uuid", task.UUID)
+		task.AddMetadata("error", taskShuttingDown)
+		return false
+	}
+	if !queued {
+		logger.Warn("Task queue full, holding preempted task in backlog", "uuid", task.UUID, "backlog", w.backlog.len())
+	}
+	task.AddMetadata("preempted", true)
+	w.sendTaskUpdate(qt.ctx, qt.conn, task)
+	logger.Info("Preempted task requeued", "uuid", task.UUID, "retry_count", task.RetryCount)
+	return true
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreemption(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(300 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.EnablePreemption = true
+	config.API.Retry = RetryConfig{MaxRetries: 2}
+	w := newTestWebSocketClient(t, config)
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	low := NewTasukete(TTI, "low", 1)
+	low.RetryCount = 1
+	w.enqueueTask(context.Background(), nil, low)
+	require.Eventually(t, func() bool { return mock.CallCount("/API/GenerateText2Image") == 1 }, 5*time.Second, time.Millisecond)
+
+	high := NewTasukete(TTI, "high", 1)
+	high.Priority = 10
+	w.enqueueTask(context.Background(), nil, high)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, high.Wait(ctx))
+	assert.Equal(t, StatusCompleted, high.Status())
+
+	require.NoError(t, low.Wait(ctx))
+	assert.Equal(t, StatusCompleted, low.Status())
+	assert.Equal(t, 1, low.RetryCount, "requeued task keeps its retry count")
+	assert.Equal(t, true, low.Metadata["preempted"])
+
+	// the low priority task's results come after the high priority one's
+	first, _ := sentResult(t, w)
+	second, _ := sentResult(t, w)
+	assert.Equal(t, high.UUID, first.UUID)
+	assert.Equal(t, low.UUID, second.UUID)
+}
+
+func TestPreemption_EqualPriorityWaits(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(100 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.EnablePreemption = true
+	w := newTestWebSocketClient(t, config)
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	first := NewTasukete(TTI, "first", 1)
+	w.enqueueTask(context.Background(), nil, first)
+	require.Eventually(t, func() bool { return mock.CallCount("/API/GenerateText2Image") == 1 }, 5*time.Second, time.Millisecond)
+	second := NewTasukete(TTI, "second", 1)
+	w.enqueueTask(context.Background(), nil, second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, second.Wait(ctx))
+	require.NoError(t, first.Wait(ctx))
+	assert.Equal(t, StatusCompleted, first.Status())
+	assert.NotContains(t, first.Metadata, "preempted")
+}
+
+func TestPreemption_FullQueue(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(300 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.EnablePreemption = true
+	config.Server.QueueDepth = 1
+	config.API.Retry = RetryConfig{MaxRetries: 2}
+	w := newTestWebSocketClient(t, config)
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	low := NewTasukete(TTI, "low", 1)
+	w.enqueueTask(context.Background(), nil, low)
+	require.Eventually(t, func() bool { return mock.CallCount("/API/GenerateText2Image") == 1 }, 5*time.Second, time.Millisecond)
+	queued := NewTasukete(TTI, "queued", 1)
+	w.enqueueTask(context.Background(), nil, queued)
+	require.Len(t, w.queue, 1)
+
+	high := NewTasukete(TTI, "high", 1)
+	high.Priority = 10
+	w.enqueueTask(context.Background(), nil, high)
+
+	// the preempted task can't go back into the full queue, so it waits in
+	// the backlog instead of failing
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, task := range []*Tasukete{high, queued, low} {
+		require.NoError(t, task.Wait(ctx))
+		assert.Equal(t, StatusCompleted, task.Status(), task.Prompt)
+	}
+	assert.Equal(t, true, low.Metadata["preempted"])
+
+	var order []string
+	for range 3 {
+		result, _ := sentResult(t, w)
+		order = append(order, result.Prompt)
+	}
+	assert.Equal(t, []string{"high", "queued", "low"}, order)
+	assert.Zero(t, w.queueDepth())
+}
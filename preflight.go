@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const swarmUIVersionHeader = "X-SwarmUI-Version"
+
+// RemoteModel is a model reported by the SwarmUI API
+type RemoteModel struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+}
+
+type listModelsResponse struct {
+	Files []RemoteModel `json:"files"`
+}
+
+// FetchAvailableModels lists the Stable Diffusion models known to the API.
+// When the API tags the list with an ETag, an unchanged list is answered
+// from the last response.
+func (c *Client) FetchAvailableModels(ctx context.Context) ([]RemoteModel, error) {
+	url := fmt.Sprintf("http://%s:%s/API/ListModels", c.config.API.Host, c.config.API.Port)
+	body := []byte(`{"path":"","depth":10}`)
+
+	cache := &c.modelList
+	cache.mu.Lock()
+	prevETag := cache.etag
+	cache.mu.Unlock()
+
+	data, etag, notModified, err := c.downloadWithConditional(ctx, url, body, prevETag)
+	if err != nil {
+		return nil, fmt.Errorf("list models failed: %w", err)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if notModified {
+		c.logger.Debug("Model list not modified", "etag", etag)
+		return slices.Clone(cache.models), nil
+	}
+
+	var listResp listModelsResponse
+	if err := json.Unmarshal(data, &listResp); err != nil {
+		return nil, fmt.Errorf("failed to decode list models response: %w", err)
+	}
+	cache.etag, cache.models = etag, listResp.Files
+	return slices.Clone(listResp.Files), nil
+}
+
+// Preflight checks that the API is reachable, new enough, and knows the configured models
+func (c *Client) Preflight(ctx context.Context) error {
+	if _, err := c.getNewSession(ctx); err != nil {
+		return fmt.Errorf("API unreachable: %w", err)
+	}
+
+	version, _ := c.apiVersion.Load().(string)
+	c.logger.Info("Connected to SwarmUI", "version", version)
+
+	if min := c.config.API.MinSwarmUIVersion; min != "" {
+		if version == "" {
+			return fmt.Errorf("API did not report its version, need at least %s", min)
+		}
+		if compareVersions(version, min) < 0 {
+			return fmt.Errorf("API version %s is older than required %s", version, min)
+		}
+	}
+
+	remote, err := c.AvailableModels(ctx)
+	if err != nil {
+		c.logger.Warn("Could not list remote models", "error", err)
+		return nil
+	}
+
+	available := make(map[string]bool, len(remote))
+	for _, m := range remote {
+		available[strings.TrimSuffix(m.Name, path.Ext(m.Name))] = true
+	}
+	configured := make(map[string]bool, len(c.config.Models))
+	for _, m := range c.config.Models {
+		configured[m.String] = true
+		if !available[m.String] {
+			c.logger.Warn("Configured model not found on API", "model", m.Name, "string", m.String)
+		}
+	}
+	if c.config.API.SyncRemoteModels {
+		c.addRemoteModels(remote, configured)
+	}
+	return nil
+}
+
+// addRemoteModels adds the remote models missing from the config, with
+// the first configured model's size, steps and CFG scale
+func (c *Client) addRemoteModels(remote []RemoteModel, configured map[string]bool) {
+	if len(c.config.Models) == 0 {
+		c.logger.Warn("Not syncing remote models: no configured model to copy settings from")
+		return
+	}
+	var added []ModelConfig
+	template := c.config.Models[0]
+	for _, m := range remote {
+		name := strings.TrimSuffix(m.Name, path.Ext(m.Name))
+		if configured[name] {
+			continue
+		}
+		configured[name] = true
+		added = append(added, ModelConfig{
+			Name:     name,
+			String:   name,
+			Width:    template.Width,
+			Height:   template.Height,
+			Steps:    template.Steps,
+			Cfgscale: template.Cfgscale,
+		})
+		c.logger.Info("Added remote model to config", "model", name)
+	}
+	if len(added) == 0 {
+		return
+	}
+	c.updateConfig(func(config *Config) {
+		config.Models = append(config.Models, added...)
+	})
+}
+
+// compareVersions compares dotted numeric versions, ignoring any non-numeric suffix
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = leadingInt(as[i])
+		}
+		if i < len(bs) {
+			y = leadingInt(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPreflightServer(version string) *httptest.Server {
+	mock := NewMockSwarmUIServer()
+	mock.SetVersion(version)
+	return mock.Start()
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		minVersion string
+		wantErr    bool
+	}{
+		{"healthy", "0.9.5.1", "0.9.2", false},
+		{"no minimum", "", "", false},
+		{"version mismatch", "0.8.1", "0.9", true},
+		{"missing version", "", "0.9", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPreflightServer(tt.version)
+			defer server.Close()
+
+			var logs bytes.Buffer
+			config := MockConfig()
+			useMockAPI(config, server)
+			config.API.MinSwarmUIVersion = tt.minVersion
+			config.Models = append(config.Models, ModelConfig{Name: "ghost", String: "Missing/model"})
+			client := NewClient(config, slog.New(slog.NewTextHandler(&logs, nil)))
+
+			err := client.Preflight(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, logs.String(), "Configured model not found on API")
+			assert.Contains(t, logs.String(), "Missing/model")
+			assert.NotContains(t, logs.String(), "string=default_model")
+		})
+	}
+}
+
+func TestPreflight_Unreachable(t *testing.T) {
+	server := newPreflightServer("1.0")
+	config := MockConfig()
+	useMockAPI(config, server)
+	server.Close()
+
+	client := NewClient(config, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	assert.Error(t, client.Preflight(context.Background()))
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.2.3", "1.2.3"))
+	assert.Equal(t, 1, compareVersions("1.10", "1.9"))
+	assert.Equal(t, -1, compareVersions("0.9", "0.9.1"))
+	assert.Equal(t, 0, compareVersions("0.9.0-beta", "0.9"))
+}
+
+func TestPreflight_SyncRemoteModels(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetModels("default_model.safetensors", "Flux/new.safetensors")
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.SyncRemoteModels = true
+	watcher := NewConfigWatcher(config, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	client := NewClient(config, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), WithConfigWatcher(watcher))
+
+	require.NoError(t, client.Preflight(context.Background()))
+	require.Len(t, config.Models, 2)
+	added := config.Models[1]
+	assert.Equal(t, "Flux/new", added.Name)
+	assert.Equal(t, "Flux/new", added.String)
+	assert.Equal(t, 512, added.Width)
+	assert.Equal(t, 20, added.Steps)
+	assert.Len(t, watcher.changes, 1, "the change goes through the watcher")
+
+	require.NoError(t, client.Preflight(context.Background()))
+	assert.Len(t, config.Models, 2, "models are added once")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// defaultProgressInterval is how often running tasks poll their job status
+// when api.progress_polling_interval is unset
+const defaultProgressInterval = 2 * time.Second
+
+// JobStatus is the GetJobStatus response for a session's running generation
+type JobStatus struct {
+	CurrentStep int `json:"current_step"`
+	TotalSteps  int `json:"total_steps"`
+}
+
+func (s JobStatus) percent() int {
+	if s.TotalSteps <= 0 {
+		return 0
+	}
+	return min(s.CurrentStep*100/s.TotalSteps, 100)
+}
+
+// TaskProgress is the payload of a "task_progress" message
+type TaskProgress struct {
+	UUID            uuid.UUID `json:"uuid"`
+	ProgressPercent int       `json:"progress_percent"`
+	CurrentStep     int       `json:"current_step"`
+	TotalSteps      int       `json:"total_steps"`
+}
+
+// progressInterval converts progress_polling_interval, returning 0 when
+// polling is disabled
+func (c APIConfig) progressInterval() time.Duration {
+	switch {
+	case c.ProgressPollingInterval < 0:
+		return 0
+	case c.ProgressPollingInterval == 0:
+		return defaultProgressInterval
+	default:
+		return time.Duration(c.ProgressPollingInterval) * time.Second
+	}
+}
+
+// JobStatus asks the API how far the session's current generation got
+func (c *Client) JobStatus(ctx context.Context, sessionID string) (JobStatus, error) {
+	endpoint := fmt.Sprintf("http://%s:%s/API/GetJobStatus?session_id=%s", c.config.API.Host, c.config.API.Port, url.QueryEscape(sessionID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return JobStatus{}, parseAPIError(resp)
+	}
+
+	var status JobStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return JobStatus{}, fmt.Errorf("failed to decode job status: %w", err)
+	}
+	return status, nil
+}
+
+// withSessionHook has generateWithRetry call hook with the session it
+// generates in on behalf of ctx
+func withSessionHook(ctx context.Context, hook func(sessionID string)) context.Context {
+	return context.WithValue(ctx, sessionHookKey, hook)
+}
+
+func sessionStarted(ctx context.Context, sessionID string) {
+	if hook, ok := ctx.Value(sessionHookKey).(func(string)); ok {
+		hook(sessionID)
+	}
+}
+
+// trackProgress reports the task's progress to the server while it
+// generates. Polling starts once the generation has a session and follows
+// it if the session is replaced. The returned function stops polling and
+// waits for the poller to exit, so no progress follows the result.
+func (w *WebSocketClient) trackProgress(ctx context.Context, conn *websocket.Conn, task *Tasukete) (context.Context, func()) {
+	if w.progressInterval <= 0 {
+		return ctx, func() {}
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	var (
+		session atomic.Pointer[string]
+		mu      sync.Mutex
+		stopped bool
+		wg      sync.WaitGroup
+	)
+	ctx = withSessionHook(ctx, func(sessionID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if session.Swap(&sessionID) == nil && !stopped {
+			wg.Add(1)
+			w.goRecoverTask("progress poller", func() {
+				defer wg.Done()
+				w.pollProgress(pollCtx, conn, task, &session)
+			})
+		}
+	})
+	return ctx, func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (w *WebSocketClient) pollProgress(ctx context.Context, conn *websocket.Conn, task *Tasukete, session *atomic.Pointer[string]) {
+	logger := loggerFromContext(ctx, w.logger)
+	ticker := time.NewTicker(w.progressInterval)
+	defer ticker.Stop()
+
+	lastStep := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, err := w.client.JobStatus(ctx, *session.Load())
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			logger.Debug("Job status not supported, not reporting progress", "uuid", task.UUID)
+			return
+		}
+		if err != nil {
+			logger.Debug("Failed to poll job status", "uuid", task.UUID, "error", err)
+			continue
+		}
+		if status.CurrentStep == lastStep {
+			continue
+		}
+		lastStep = status.CurrentStep
+
+		if ctx.Err() != nil { // the task finished while we polled
+			return
+		}
+		msg := WebSocketMessage{
+			Type: "task_progress",
+			Payload: must(json.Marshal(TaskProgress{
+				UUID:            task.UUID,
+				ProgressPercent: status.percent(),
+				CurrentStep:     status.CurrentStep,
+				TotalSteps:      status.TotalSteps,
+			})),
+		}
+		if err := w.writeJSON(ctx, conn, msg); err != nil {
+			logger.Error("Failed to send task progress", "error", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func progressMessages(t *testing.T, w *WebSocketClient) []TaskProgress {
+	t.Helper()
+	var progress []TaskProgress
+	for _, msg := range sentMessages(t, w) {
+		if msg.Type != "task_progress" {
+			continue
+		}
+		var p TaskProgress
+		require.NoError(t, json.Unmarshal(msg.Payload, &p))
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+func TestHandleTTITask_ReportsProgress(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(200 * time.Millisecond)
+	mock.SetJobStatus(5, 20)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+	w.progressInterval = 10 * time.Millisecond
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+	require.Equal(t, StatusCompleted, task.Status())
+
+	progress := progressMessages(t, w)
+	require.Len(t, progress, 1, "unchanged steps are reported once")
+	assert.Equal(t, TaskProgress{UUID: task.UUID, ProgressPercent: 25, CurrentStep: 5, TotalSteps: 20}, progress[0])
+	assert.Positive(t, mock.CallCount("/API/GetJobStatus"))
+}
+
+func TestHandleTTITask_ProgressNotSupported(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(100 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+	w.progressInterval = 10 * time.Millisecond
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+	require.Equal(t, StatusCompleted, task.Status())
+
+	assert.Empty(t, progressMessages(t, w))
+	assert.Equal(t, 1, mock.CallCount("/API/GetJobStatus"), "polling stops after a 404")
+}
+
+func TestTrackProgress_StopWaitsForPoller(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetJobStatus(5, 20)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+	w.progressInterval = time.Millisecond
+
+	ctx, stop := w.trackProgress(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+	sessionStarted(ctx, "mock-session-1")
+	require.Eventually(t, func() bool { return mock.CallCount("/API/GetJobStatus") > 0 }, time.Second, time.Millisecond)
+	stop()
+
+	sent := len(w.out.Load().queue)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, sent, len(w.out.Load().queue), "progress sent after stop")
+}
+
+func TestAPIConfig_ProgressInterval(t *testing.T) {
+	assert.Equal(t, defaultProgressInterval, APIConfig{}.progressInterval())
+	assert.Equal(t, 5*time.Second, APIConfig{ProgressPollingInterval: 5}.progressInterval())
+	assert.Zero(t, APIConfig{ProgressPollingInterval: -1}.progressInterval())
+}
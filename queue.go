@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultQueueDepth   = 256
+	queueReportInterval = 30 * time.Second
+	// defaultTaskETA stands in for the generation time until one has been measured
+	defaultTaskETA = 30 * time.Second
+)
+
+// queuedTask is a task waiting for the worker, along with its request scope
+type queuedTask struct {
+	ctx  context.Context
+	conn *websocket.Conn
+	task *Tasukete
+}
+
+func newTaskQueue(depth int) chan queuedTask {
+	if depth <= 0 {
+		depth = defaultQueueDepth
+	}
+	return make(chan queuedTask, depth)
+}
+
+// taskBacklog holds tasks that arrived at a full queue, oldest first, until
+// the worker frees a slot for them
+type taskBacklog struct {
+	mu    sync.Mutex
+	tasks []queuedTask
+}
+
+func (b *taskBacklog) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.tasks)
+}
+
+// enqueueTask buffers a task for the worker so the message loop never blocks
+// on generation. Tasks that have to wait are reported as queued with an ETA.
+// Tasks arriving at a full queue wait in the backlog behind it.
+func (w *WebSocketClient) enqueueTask(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	logger := loggerFromContext(ctx, w.logger)
+
+	if w.rejectWhileStopping(ctx, conn, task) {
+		return
+	}
+	if w.preempt(ctx, conn, task) {
+		return
+	}
+
+	if ahead := w.tasksAhead(); ahead > 0 {
+		eta := w.estimateWait(ahead)
+		if err := task.UpdateStatus(StatusQueued); err != nil {
+			logger.Error("Failed to queue task", "uuid", task.UUID, "error", err)
+			return
+		}
+		task.AddMetadata("queue_position", ahead)
+		task.AddMetadata("eta_seconds", int(eta.Seconds()))
+		w.sendTaskUpdate(ctx, conn, task)
+		logger.Debug("Task queued", "uuid", task.UUID, "position", ahead, "eta", eta)
+	}
+
+	queued, err := w.pushTask(queuedTask{ctx: ctx, conn: conn, task: task})
+	switch {
+	case err != nil:
+		w.rejectStopping(ctx, conn, task)
+	case !queued:
+		logger.Warn("Task queue full, holding task in backlog", "uuid", task.UUID, "depth", cap(w.queue), "backlog", w.backlog.len())
+	}
+}
+
+// pushTask hands qt to the worker's queue, or appends it to the backlog when
+// the queue is full or older tasks already wait there. It reports whether qt
+// went straight into the queue. Both producers, enqueueTask on the message
+// loop and requeuePreempted on the worker, go through it: the send never
+// blocks and the backlog lock keeps their tasks in order. Checking stopping
+// under the lock drainQueue takes makes the check and the send one step, so
+// once GracefulStop has drained the queue, nothing else gets in.
+func (w *WebSocketClient) pushTask(qt queuedTask) (bool, error) {
+	b := &w.backlog
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w.stopping.Load() {
+		return false, errClientStopping
+	}
+	w.depth.Add(1)
+	if len(b.tasks) == 0 {
+		select {
+		case w.queue <- qt:
+			return true, nil
+		default:
+		}
+	}
+	b.tasks = append(b.tasks, qt)
+	return false, nil
+}
+
+// promoteBacklog moves backlogged tasks into the queue, oldest first, for as
+// long as it has room
+func (w *WebSocketClient) promoteBacklog() {
+	b := &w.backlog
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.tasks) > 0 {
+		select {
+		case w.queue <- b.tasks[0]:
+			b.tasks[0] = queuedTask{}
+			b.tasks = b.tasks[1:]
+		default:
+			return
+		}
+	}
+}
+
+// startTaskWorker runs the task worker for a connection until done is
+// closed. The worker of the previous connection may still be finishing its
+// task, so the new one waits for it to return first: only one task runs at
+// a time, which preemption and GracefulStop rely on. Only connect calls it,
+// from the Start loop, so workerDone needs no lock.
+func (w *WebSocketClient) startTaskWorker(done <-chan struct{}) {
+	prev := w.workerDone
+	workerDone := make(chan struct{})
+	w.workerDone = workerDone
+	w.goRecover("task worker", func() {
+		defer close(workerDone)
+		if prev != nil {
+			<-prev
+		}
+		w.runTaskWorker(w.queue, done)
+	})
+}
+
+// runTaskWorker processes queued tasks one at a time until done is closed,
+// taking tasks that preempted the last one first. Each task it takes from the
+// queue frees a slot for the backlog.
+func (w *WebSocketClient) runTaskWorker(queue chan queuedTask, done <-chan struct{}) {
+	for {
+		// select picks at random among ready cases, so check done on its
+		// own first, or a closed connection could still take queued tasks
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		select {
+		case qt := <-w.urgent:
+			w.runQueuedTask(qt)
+			continue
+		default:
+		}
+
+		select {
+		case <-done:
+			return
+		case qt := <-w.urgent:
+			w.runQueuedTask(qt)
+		case qt := <-queue:
+			w.depth.Add(-1)
+			w.promoteBacklog()
+			w.runQueuedTask(qt)
+		}
+	}
+}
+
+// startQueueReporter logs the queue depth and ETA until done is closed
+func (w *WebSocketClient) startQueueReporter(done <-chan struct{}) {
+	ticker := time.NewTicker(queueReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			depth := w.queueDepth()
+			ahead := depth + int(w.inFlight.Load())
+			w.logger.Info("Task queue status",
+				"depth", depth,
+				"in_flight", w.inFlight.Load(),
+				"eta", w.estimateWait(ahead),
+			)
+		}
+	}
+}
+
+// queueDepth counts the tasks waiting for the worker, backlog included. It
+// reads the depth counter the producers and the worker keep, which is safe
+// from any goroutine, unlike len of the queue mid-handoff.
+func (w *WebSocketClient) queueDepth() int {
+	return int(w.depth.Load())
+}
+
+// tasksAhead counts tasks that will run before a newly queued one
+func (w *WebSocketClient) tasksAhead() int {
+	return w.queueDepth() + int(w.inFlight.Load())
+}
+
+// estimateWait guesses how long n tasks take from the average generation time
+func (w *WebSocketClient) estimateWait(n int) time.Duration {
+	avg := w.client.averageLatency()
+	if avg == 0 {
+		avg = defaultTaskETA
+	}
+	return time.Duration(n) * avg
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueTask_QueuedWhileBusy(t *testing.T) {
+	config := MockConfig()
+	config.Server.QueueDepth = 2
+	w := newTestWebSocketClient(t, config)
+	w.inFlight.Store(1) // the worker is busy with another task
+
+	tasks := []*Tasukete{
+		NewTasukete(TTI, "first", 1),
+		NewTasukete(TTI, "second", 1),
+		NewTasukete(TTI, "third", 1),
+	}
+	for _, task := range tasks {
+		w.enqueueTask(context.Background(), nil, task)
+	}
+	assert.Len(t, w.queue, 2)
+	assert.Equal(t, 1, w.backlog.len(), "the task arriving at a full queue waits in the backlog")
+	assert.Equal(t, 3, w.queueDepth())
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 3)
+
+	var updates []Tasukete
+	for _, msg := range messages {
+		assert.Equal(t, "task_update", msg.Type)
+		var update Tasukete
+		require.NoError(t, json.Unmarshal(msg.Payload, &update))
+		updates = append(updates, update)
+	}
+
+	// nothing measured yet, so each task ahead counts as defaultTaskETA
+	assert.Equal(t, StatusQueued, updates[0].Status())
+	assert.Equal(t, float64(1), updates[0].Metadata["queue_position"])
+	assert.Equal(t, defaultTaskETA.Seconds(), updates[0].Metadata["eta_seconds"])
+	assert.Equal(t, StatusQueued, updates[1].Status())
+	assert.Equal(t, 2*defaultTaskETA.Seconds(), updates[1].Metadata["eta_seconds"])
+
+	assert.Equal(t, tasks[2].UUID, updates[2].UUID)
+	assert.Equal(t, StatusQueued, updates[2].Status())
+	assert.Equal(t, float64(3), updates[2].Metadata["queue_position"])
+	assert.Equal(t, 3*defaultTaskETA.Seconds(), updates[2].Metadata["eta_seconds"])
+}
+
+func TestRunTaskWorker_PromotesBacklog(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.QueueDepth = 1
+	w := newTestWebSocketClient(t, config)
+
+	prompts := []string{"first", "second", "third", "fourth"}
+	for _, prompt := range prompts {
+		w.enqueueTask(context.Background(), nil, NewTasukete(TTI, prompt, 1))
+	}
+	require.Len(t, w.queue, 1)
+	require.Equal(t, 3, w.backlog.len())
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	require.Eventually(t, func() bool {
+		return mock.CallCount("/images/") == len(prompts) && len(w.queue) == 0 && w.backlog.len() == 0 && w.inFlight.Load() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, w.queueDepth())
+
+	generations := mock.Generations()
+	require.Len(t, generations, len(prompts))
+	for i, prompt := range prompts {
+		assert.Equal(t, prompt, generations[i]["prompt"])
+	}
+}
+
+func TestEnqueueTask_IdleWorkerSkipsQueuedStatus(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+
+	assert.Len(t, w.queue, 1)
+	assert.Empty(t, sentMessages(t, w))
+}
+
+func TestRunTaskWorker_ProcessesInOrder(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+
+	done := make(chan struct{})
+	defer close(done)
+	go w.runTaskWorker(w.queue, done)
+
+	w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "first", 1))
+	w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "second", 1))
+
+	require.Eventually(t, func() bool {
+		return mock.CallCount("/images/") == 2 && len(w.queue) == 0 && w.inFlight.Load() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	generations := mock.Generations()
+	require.Len(t, generations, 2)
+	assert.Equal(t, "first", generations[0]["prompt"])
+	assert.Equal(t, "second", generations[1]["prompt"])
+}
+
+func TestStartTaskWorker_WaitsForPreviousWorker(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(100 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	w := newTestWebSocketClient(t, config)
+	for _, prompt := range []string{"first", "second", "third"} {
+		w.enqueueTask(context.Background(), nil, NewTasukete(TTI, prompt, 1))
+	}
+
+	// a reconnect while the first connection's worker is mid-task
+	firstConn := make(chan struct{})
+	w.startTaskWorker(firstConn)
+	require.Eventually(t, func() bool { return w.inFlight.Load() == 1 }, 5*time.Second, time.Millisecond)
+	close(firstConn)
+	secondConn := make(chan struct{})
+	defer close(secondConn)
+	w.startTaskWorker(secondConn)
+
+	var maxInFlight int32
+	require.Eventually(t, func() bool {
+		maxInFlight = max(maxInFlight, w.inFlight.Load())
+		return mock.CallCount("/images/") == 3 && w.inFlight.Load() == 0
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), maxInFlight, "tasks ran one at a time")
+}
+
+func TestEstimateWait_UsesMeasuredLatency(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	w.client.recordModelStats("SD", 2*time.Second, nil)
+	w.client.recordModelStats("SD", 4*time.Second, nil)
+
+	assert.Equal(t, 9*time.Second, w.estimateWait(3))
+}
+
+func TestConcurrentTaskEnqueue(t *testing.T) {
+	t.Parallel()
+	const producers, perProducer = 10, 20
+
+	config := MockConfig()
+	config.Server.QueueDepth = producers * perProducer
+	w := newTestWebSocketClient(t, config)
+
+	var wg sync.WaitGroup
+	for range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perProducer {
+				w.enqueueTask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, w.queue, producers*perProducer)
+	assert.Equal(t, producers*perProducer, w.queueDepth())
+	seen := make(map[uuid.UUID]bool)
+	for len(w.queue) > 0 {
+		qt := <-w.queue
+		assert.False(t, seen[qt.task.UUID], "task %s queued twice", qt.task.UUID)
+		seen[qt.task.UUID] = true
+	}
+	assert.Len(t, seen, producers*perProducer)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxRetryAfter caps Retry-After when max_retry_after_seconds is unset
+const defaultMaxRetryAfter = 300 * time.Second
+
+func (c APIConfig) maxRetryAfter() time.Duration {
+	if c.MaxRetryAfter > 0 {
+		return time.Duration(c.MaxRetryAfter) * time.Second
+	}
+	return defaultMaxRetryAfter
+}
+
+// rateLimited records a 429 from the API and returns how long its
+// Retry-After header asks us to wait
+func (c *Client) rateLimited(ctx context.Context, retryAfter string) time.Duration {
+	count := c.rateLimitedCount.Add(1)
+	wait := parseRetryAfter(retryAfter, time.Now())
+	loggerFromContext(ctx, c.logger).Warn("Rate limited by API", "retry_after", wait, "rate_limited", count)
+	return wait
+}
+
+// RateLimited returns how many 429 responses the API has sent
+func (c *Client) RateLimited() int64 {
+	return c.rateLimitedCount.Load()
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning 0 when it's missing, malformed or already past
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{" 120 ", 2 * time.Minute},
+		{"-5", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseRetryAfter(tt.value, now), "Retry-After %q", tt.value)
+	}
+}
+
+func TestGenerate_HonorsRetryAfter(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	// request 1 is the session, 2 the first generation attempt
+	mock.SetRateLimitOnNthRequest(2, "2")
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 1, BackoffMs: 10}
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	start := time.Now()
+	_, err := client.GenerateImage("test prompt", 1)
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.InDelta(t, 2*time.Second, elapsed, float64(500*time.Millisecond))
+	assert.Equal(t, int64(1), client.RateLimited())
+	assert.Equal(t, 2, mock.CallCount("/API/GenerateText2Image"))
+}
+
+func TestGenerate_CapsRetryAfter(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	mock.SetRateLimitOnNthRequest(2, "3600")
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 1}
+	config.API.MaxRetryAfter = 1
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	start := time.Now()
+	_, err := client.GenerateImage("test prompt", 1)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reconnectDelay is how long Start waits between connection attempts
+const reconnectDelay = 5 * time.Second
+
+// reconnectState tracks the WebSocket connection across reconnect attempts
+type reconnectState struct {
+	connected atomic.Bool
+	attempts  atomic.Int64
+
+	mu            sync.Mutex
+	nextRetry     time.Time
+	lastConnected time.Time
+}
+
+// ConnectionStatus is a snapshot of the WebSocket connection, shaped for a
+// health endpoint
+type ConnectionStatus struct {
+	WebSocketConnected bool       `json:"websocket_connected"`
+	ReconnectAttempts  int64      `json:"reconnect_attempts"`
+	NextRetryAt        *time.Time `json:"next_retry_at,omitempty"`
+	LastConnectedAt    *time.Time `json:"last_connected_at,omitempty"`
+}
+
+// ConnectionStatus reports whether the WebSocket is connected and, if not,
+// how many reconnects have failed and when the next one is due
+func (w *WebSocketClient) ConnectionStatus() ConnectionStatus {
+	status := ConnectionStatus{
+		WebSocketConnected: w.reconnect.connected.Load(),
+		ReconnectAttempts:  w.reconnect.attempts.Load(),
+	}
+	w.reconnect.mu.Lock()
+	defer w.reconnect.mu.Unlock()
+	if !w.reconnect.nextRetry.IsZero() {
+		next := w.reconnect.nextRetry
+		status.NextRetryAt = &next
+	}
+	if !w.reconnect.lastConnected.IsZero() {
+		last := w.reconnect.lastConnected
+		status.LastConnectedAt = &last
+	}
+	return status
+}
+
+// ResetReconnectCounter clears the failed attempt count and pending retry time
+func (w *WebSocketClient) ResetReconnectCounter() {
+	w.reconnect.attempts.Store(0)
+	w.reconnect.mu.Lock()
+	defer w.reconnect.mu.Unlock()
+	w.reconnect.nextRetry = time.Time{}
+}
+
+// markConnected records a connection ready for tasks, ending the current
+// run of reconnect attempts
+func (w *WebSocketClient) markConnected() {
+	w.ResetReconnectCounter()
+	w.reconnect.mu.Lock()
+	w.reconnect.lastConnected = time.Now()
+	w.reconnect.mu.Unlock()
+	w.reconnect.connected.Store(true)
+}
+
+// connectOnce runs one connection until it drops and returns how long to
+// wait before the next attempt
+func (w *WebSocketClient) connectOnce() time.Duration {
+	err := w.connect()
+	w.reconnect.connected.Store(false)
+	if w.stopping.Load() {
+		return 0
+	}
+
+	attempt := w.reconnect.attempts.Add(1)
+	w.reconnect.mu.Lock()
+	w.reconnect.nextRetry = time.Now().Add(w.reconnectDelay)
+	w.reconnect.mu.Unlock()
+
+	if err != nil {
+		w.logger.Error("WebSocket connection failed", "error", err, "attempt", attempt, "retry_in", w.reconnectDelay)
+	} else {
+		w.logger.Info("WebSocket connection closed", "attempt", attempt, "retry_in", w.reconnectDelay)
+	}
+	return w.reconnectDelay
+}
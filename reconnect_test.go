@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectOnce_CountsRejectedConnections(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "go away", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	config := MockConfig()
+	useWebSocketServer(config, server)
+	w := newTestWebSocketClient(t, config)
+	w.reconnectDelay = time.Minute
+
+	assert.Equal(t, ConnectionStatus{}, w.ConnectionStatus())
+
+	for i := 1; i <= 3; i++ {
+		before := time.Now()
+		assert.Equal(t, time.Minute, w.connectOnce())
+
+		status := w.ConnectionStatus()
+		assert.False(t, status.WebSocketConnected)
+		assert.Equal(t, int64(i), status.ReconnectAttempts)
+		require.NotNil(t, status.NextRetryAt)
+		assert.WithinDuration(t, before.Add(time.Minute), *status.NextRetryAt, time.Second)
+	}
+
+	data, err := json.Marshal(w.ConnectionStatus())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"reconnect_attempts":3`)
+	assert.Contains(t, string(data), `"next_retry_at":"`)
+
+	w.ResetReconnectCounter()
+	assert.Equal(t, ConnectionStatus{}, w.ConnectionStatus())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys shared by every worker using the queue
+const (
+	redisQueueKey = "genclient:queue" // list of task UUIDs, oldest first
+	redisTasksKey = "genclient:tasks" // hash of task UUID to task JSON
+)
+
+// redisPollInterval is how often a blocked RedisQueue.Dequeue tries again
+const redisPollInterval = 100 * time.Millisecond
+
+// redisPop takes the first task off the list and out of the hash in one
+// step, so a crash or a second worker can't split the two. It returns the
+// UUID and task JSON, or nil when the list is empty.
+var redisPop = redis.NewScript(`
+local id = redis.call("LPOP", KEYS[1])
+if not id then
+	return nil
+end
+local data = redis.call("HGET", KEYS[2], id)
+redis.call("HDEL", KEYS[2], id)
+return {id, data}
+`)
+
+// RedisQueue keeps tasks in Redis so several worker processes can share
+// them. The list holds UUIDs and the hash holds the tasks themselves.
+type RedisQueue struct {
+	client *redis.Client
+}
+
+// NewRedisQueue connects to the Redis server at url, e.g.
+// redis://localhost:6379/0
+func NewRedisQueue(url string) (*RedisQueue, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	return &RedisQueue{client: redis.NewClient(opts)}, nil
+}
+
+func (q *RedisQueue) Enqueue(ctx context.Context, task *Tasukete) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+	id := task.UUID.String()
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisTasksKey, id, data)
+		pipe.RPush(ctx, redisQueueKey, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Tasukete, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		task, err := q.pop(ctx)
+		if err != nil || task != nil {
+			return task, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, ErrQueueEmpty
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(redisPollInterval, time.Until(deadline))):
+		}
+	}
+}
+
+// pop runs redisPop, returning nil when the queue is empty
+func (q *RedisQueue) pop(ctx context.Context) (*Tasukete, error) {
+	result, err := redisPop.Run(ctx, q.client, []string{redisQueueKey, redisTasksKey}).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dequeue task: %w", err)
+	}
+	id, _ := result[0].(string)
+	data, ok := result[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("failed to load task %s: not in %s", id, redisTasksKey)
+	}
+
+	var task Tasukete
+	if err := json.Unmarshal([]byte(data), &task); err != nil {
+		return nil, fmt.Errorf("failed to decode task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+func (q *RedisQueue) Len(ctx context.Context) (int, error) {
+	n, err := q.client.LLen(ctx, redisQueueKey).Result()
+	return int(n), err
+}
+
+func (q *RedisQueue) Close() error {
+	return q.client.Close()
+}
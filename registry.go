@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskRegistry tracks the tasks being handled, so they can be inspected
+// and cancelled from outside the goroutine running them
+type TaskRegistry struct {
+	mu      sync.RWMutex
+	tasks   map[uuid.UUID]*Tasukete
+	cancels map[uuid.UUID]context.CancelFunc
+}
+
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{
+		tasks:   make(map[uuid.UUID]*Tasukete),
+		cancels: make(map[uuid.UUID]context.CancelFunc),
+	}
+}
+
+// Register adds a task along with the function that cancels its context
+func (r *TaskRegistry) Register(task *Tasukete, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[task.UUID] = task
+	r.cancels[task.UUID] = cancel
+}
+
+func (r *TaskRegistry) Unregister(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tasks, id)
+	delete(r.cancels, id)
+}
+
+func (r *TaskRegistry) Get(id uuid.UUID) (*Tasukete, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	task, ok := r.tasks[id]
+	return task, ok
+}
+
+// List returns the registered tasks, oldest first
+func (r *TaskRegistry) List() []*Tasukete {
+	r.mu.RLock()
+	tasks := make([]*Tasukete, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		tasks = append(tasks, task)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(tasks, func(a, b *Tasukete) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return tasks
+}
+
+// Cancel cancels a registered task's context, reporting whether the task
+// was found
+func (r *TaskRegistry) Cancel(id uuid.UUID) bool {
+	r.mu.RLock()
+	cancel, ok := r.cancels[id]
+	r.mu.RUnlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// RegisteredTask is a task being handled, as listed at /tasks. It only
+// holds fields that don't change while the task runs.
+type RegisteredTask struct {
+	UUID        uuid.UUID `json:"uuid"`
+	Type        Type      `json:"type"`
+	Model       int       `json:"model"`
+	Prompt      string    `json:"prompt"`
+	RequestedBy string    `json:"requested_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RegisteredTasks snapshots the tasks being handled, oldest first
+func (w *WebSocketClient) RegisteredTasks() []RegisteredTask {
+	tasks := w.registry.List()
+	registered := make([]RegisteredTask, len(tasks))
+	for i, task := range tasks {
+		registered[i] = RegisteredTask{
+			UUID:        task.UUID,
+			Type:        task.Type,
+			Model:       task.Model,
+			Prompt:      truncate(task.Prompt, dashboardPromptLimit),
+			RequestedBy: task.RequestedBy,
+			CreatedAt:   task.CreatedAt,
+		}
+	}
+	return registered
+}
+
+// cancelTaskPayload is the payload of a cancel_task message
+type cancelTaskPayload struct {
+	UUID uuid.UUID `json:"uuid"`
+}
+
+// handleCancelTask cancels the context of a running task. The task fails
+// once its handler sees the cancellation.
+func (w *WebSocketClient) handleCancelTask(ctx context.Context, message WebSocketMessage) error {
+	logger := loggerFromContext(ctx, w.logger)
+	var payload cancelTaskPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		return malformedPayload(message.Type, err)
+	}
+	if !w.registry.Cancel(payload.UUID) {
+		logger.Warn("Cancel for unknown task", "uuid", payload.UUID)
+		return nil
+	}
+	logger.Info("Task cancelled", "uuid", payload.UUID)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskRegistry_Concurrent(t *testing.T) {
+	registry := NewTaskRegistry()
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			task := NewTasukete(TTI, fmt.Sprintf("prompt %d", i), 1)
+			registry.Register(task, func() {})
+			got, ok := registry.Get(task.UUID)
+			assert.True(t, ok)
+			assert.Same(t, task, got)
+			registry.List()
+			if i%2 == 0 {
+				registry.Unregister(task.UUID)
+				_, ok := registry.Get(task.UUID)
+				assert.False(t, ok)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tasks := registry.List()
+	assert.Len(t, tasks, 25)
+	for i := 1; i < len(tasks); i++ {
+		assert.False(t, tasks[i].CreatedAt.Before(tasks[i-1].CreatedAt), "oldest first")
+	}
+	assert.False(t, registry.Cancel(uuid.New()))
+}
+
+func TestHandleMessage_CancelTask(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	started := make(chan struct{})
+	w.RegisterTaskHandler(TTI, TaskHandlerFunc(func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	server := httptest.NewServer(w.DashboardHandler())
+	defer server.Close()
+
+	task := NewTasukete(TTI, "a cat", 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.handleTask(context.Background(), nil, task)
+	}()
+	<-started
+
+	resp, err := http.Get(server.URL + "/tasks")
+	require.NoError(t, err)
+	var listed []RegisteredTask
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed, 1)
+	assert.Equal(t, task.UUID, listed[0].UUID)
+
+	payload, err := json.Marshal(cancelTaskPayload{UUID: task.UUID})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(nil, WebSocketMessage{Type: "cancel_task", Payload: payload}))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled task is still running")
+	}
+	_, ok := w.registry.Get(task.UUID)
+	assert.False(t, ok, "finished tasks are unregistered")
+	assert.Empty(t, w.RegisteredTasks())
+
+	// unknown tasks are ignored, bad payloads aren't
+	assert.NoError(t, w.handleMessage(nil, WebSocketMessage{Type: "cancel_task", Payload: payload}))
+	assert.Error(t, w.handleMessage(nil, WebSocketMessage{Type: "cancel_task", Payload: []byte(`{"uuid":7}`)}))
+}
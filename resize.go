@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// defaultInitImageCreativity is how far img2img generations may stray from
+// the init image, SwarmUI's own default
+const defaultInitImageCreativity = 0.6
+
+// lanczos3 is the Lanczos kernel with a = 3, which x/image/draw doesn't ship
+var lanczos3 = &draw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	if t <= -3 || t >= 3 {
+		return 0
+	}
+	pt := math.Pi * t
+	return 3 * math.Sin(pt) * math.Sin(pt/3) / (pt * pt)
+}}
+
+// ResizeToModelDimensions scales an init image to the model's width and
+// height with Lanczos resampling, keeping its aspect ratio and letterboxing
+// the rest in black. The result is PNG encoded.
+func ResizeToModelDimensions(imageData []byte, model ModelConfig) ([]byte, error) {
+	if err := validateDimensions(model.Width, model.Height); err != nil {
+		return nil, fmt.Errorf("model %q: %w", model.Name, err)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, model.Width, model.Height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	lanczos3.Scale(dst, letterbox(src.Bounds().Size(), dst.Bounds().Size()), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// letterbox returns the largest rectangle with src's aspect ratio that fits
+// in dst, centered
+func letterbox(src, dst image.Point) image.Rectangle {
+	scale := math.Min(float64(dst.X)/float64(src.X), float64(dst.Y)/float64(src.Y))
+	w := max(1, int(math.Round(float64(src.X)*scale)))
+	h := max(1, int(math.Round(float64(src.Y)*scale)))
+	x, y := (dst.X-w)/2, (dst.Y-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
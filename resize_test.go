@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func whitePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestResizeToModelDimensions(t *testing.T) {
+	model := ModelConfig{Name: "Wide", Width: 512, Height: 256}
+
+	out, err := ResizeToModelDimensions(whitePNG(t, 100, 100), model)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(512, 256), img.Bounds().Size())
+
+	// the square lands in the middle 256×256, with black bars either side
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y }
+	assert.Equal(t, uint8(0), gray(10, 128))
+	assert.Equal(t, uint8(0), gray(501, 128))
+	assert.Equal(t, uint8(0xff), gray(256, 128))
+	assert.Equal(t, uint8(0xff), gray(130, 5))
+}
+
+func TestLetterbox(t *testing.T) {
+	assert.Equal(t, image.Rect(128, 0, 384, 256), letterbox(image.Pt(100, 100), image.Pt(512, 256)))
+	assert.Equal(t, image.Rect(0, 64, 512, 192), letterbox(image.Pt(200, 50), image.Pt(512, 256)))
+	assert.Equal(t, image.Rect(0, 0, 512, 256), letterbox(image.Pt(1024, 512), image.Pt(512, 256)))
+}
+
+func TestResizeToModelDimensions_Errors(t *testing.T) {
+	_, err := ResizeToModelDimensions([]byte("not an image"), ModelConfig{Width: 512, Height: 512})
+	assert.Error(t, err)
+
+	_, err = ResizeToModelDimensions(whitePNG(t, 10, 10), ModelConfig{Width: 0, Height: 512})
+	assert.Error(t, err)
+}
+
+func TestGenerateImageFromImage_ResizesInitImage(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := client.GenerateImageFromImage(context.Background(), "a cat", 1, whitePNG(t, 100, 100))
+	require.NoError(t, err)
+
+	generations := mock.Generations()
+	require.Len(t, generations, 1)
+	initImage, ok := generations[0]["initimage"].(string)
+	require.True(t, ok, "no init image sent")
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(initImage, "data:image/png;base64,"))
+	require.NoError(t, err)
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, config.Models[0].Width, cfg.Width)
+	assert.Equal(t, config.Models[0].Height, cfg.Height)
+	assert.Equal(t, defaultInitImageCreativity, generations[0]["initimagecreativity"])
+}
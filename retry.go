@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RetryConfig controls how failed generation requests are retried
+type RetryConfig struct {
+	MaxRetries int `yaml:"max_retries"` // retries after the first attempt, 0 disables
+	BackoffMs  int `yaml:"backoff_ms"`  // delay before each retry
+}
+
+func (r RetryConfig) backoff() time.Duration {
+	return time.Duration(r.BackoffMs) * time.Millisecond
+}
+
+// perModelRetryConfig applies the model's retry budget over the global one.
+// MaxRetries 0 keeps the global budget, negative disables retries.
+func perModelRetryConfig(model ModelConfig, global RetryConfig) RetryConfig {
+	effective := global
+	switch {
+	case model.MaxRetries > 0:
+		effective.MaxRetries = model.MaxRetries
+	case model.MaxRetries < 0:
+		effective.MaxRetries = 0
+	}
+	return effective
+}
+
+// generateWithRetry calls generateImage, retrying transient failures
+func (c *Client) generateWithRetry(ctx context.Context, sessionID string, model ModelConfig, params GenerationParams) ([]string, error) {
+	logger := loggerFromContext(ctx, c.logger)
+	retry := perModelRetryConfig(model, c.config.API.Retry)
+	sessionStarted(ctx, sessionID)
+
+	for attempt := 0; ; attempt++ {
+		imageURLs, err := c.generateImage(ctx, sessionID, model, params)
+		if err == nil || attempt >= retry.MaxRetries || !isRetryable(ctx, err) {
+			return imageURLs, err
+		}
+
+		delay := retry.backoff()
+		if genErr := (GenerationError{}); errors.As(err, &genErr) && genErr.RetryAfter > 0 {
+			delay = min(genErr.RetryAfter, c.config.API.maxRetryAfter())
+		}
+		logger.Warn("Generation failed, retrying", "model", model.Name, "attempt", attempt+1, "delay", delay, "error", err)
+		notifyRetry(ctx, err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// withRetryHook has retries made on behalf of ctx call hook with the
+// failure first
+func withRetryHook(ctx context.Context, hook func(err error)) context.Context {
+	return context.WithValue(ctx, retryHookKey, hook)
+}
+
+// notifyRetry calls the retry hook on ctx, if any, with the failure
+func notifyRetry(ctx context.Context, err error) {
+	if hook, ok := ctx.Value(retryHookKey).(func(error)); ok {
+		hook(err)
+	}
+}
+
+// isRetryable reports whether a generation error is likely transient:
+// a transport failure, rate limiting or a server-side error status
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrModelNotLoaded) || errors.Is(err, ErrSessionExpired) {
+		return false
+	}
+	var genErr GenerationError
+	if !errors.As(err, &genErr) {
+		return false
+	}
+	return genErr.StatusCode == 0 || genErr.StatusCode == http.StatusTooManyRequests ||
+		genErr.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerModelRetryConfig(t *testing.T) {
+	global := RetryConfig{MaxRetries: 3, BackoffMs: 10}
+
+	assert.Equal(t, global, perModelRetryConfig(ModelConfig{}, global))
+	assert.Equal(t, RetryConfig{MaxRetries: 1, BackoffMs: 10}, perModelRetryConfig(ModelConfig{MaxRetries: 1}, global))
+	assert.Equal(t, RetryConfig{MaxRetries: 0, BackoffMs: 10}, perModelRetryConfig(ModelConfig{MaxRetries: -1}, global))
+}
+
+func TestGenerateRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		modelRetry  int
+		wantErr     bool
+		wantAttempt int
+	}{
+		// requests 2 and 3 are the first two generation attempts
+		{name: "global budget", modelRetry: 0, wantAttempt: 3},
+		{name: "model budget", modelRetry: 1, wantErr: true, wantAttempt: 2},
+		{name: "never retry", modelRetry: -1, wantErr: true, wantAttempt: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockSwarmUIServer()
+			server := mock.Start()
+			defer server.Close()
+			mock.SetFailOnNthRequest(2, 3)
+
+			config := MockConfig()
+			useMockAPI(config, server)
+			config.API.Retry = RetryConfig{MaxRetries: 3}
+			config.Models[0].MaxRetries = tt.modelRetry
+
+			client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			_, err := client.GenerateImage("test prompt", 1)
+
+			if tt.wantErr {
+				var genErr GenerationError
+				require.True(t, errors.As(err, &genErr), "expected GenerationError, got %v", err)
+				assert.Equal(t, http.StatusInternalServerError, genErr.StatusCode)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempt, mock.CallCount("/API/GenerateText2Image"))
+		})
+	}
+}
+
+func TestHandleTTITask_RetryCount(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	// session, then three failed generations before one succeeds
+	mock.SetFailOnNthRequest(2, 3, 4)
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 3}
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+
+	var retryCounts []int
+	for _, message := range sentMessages(t, w) {
+		var update Tasukete
+		require.NoError(t, json.Unmarshal(message.Payload, &update))
+		retryCounts = append(retryCounts, update.RetryCount)
+	}
+	// processing, then one update per retry
+	assert.Equal(t, []int{0, 1, 2, 3}, retryCounts)
+	assert.Equal(t, 3, task.RetryCount)
+	assert.Equal(t, StatusCompleted, task.Status())
+}
+
+func TestHandleTask_RetryCountOverLimit(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Retry = RetryConfig{MaxRetries: 2}
+	w := newTestWebSocketClient(t, config)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	task.RetryCount = 3
+	w.handleTask(context.Background(), nil, task)
+
+	assert.Equal(t, StatusFailed, task.Status())
+	assert.Zero(t, mock.CallCount("/API/GenerateText2Image"))
+
+	task.RetryCount = -1
+	assert.Error(t, task.Validate())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TaskSchema lists the metadata fields a task type needs, mapped to their
+// kind: "string", "int", "number" or "bool"
+type TaskSchema struct {
+	Required map[string]string `yaml:"required"`
+}
+
+// SchemaRegistry holds the metadata schema for each task type
+type SchemaRegistry map[Type]TaskSchema
+
+// defaultSchemaRegistry is used when no task_schema_path is configured
+var defaultSchemaRegistry = SchemaRegistry{
+	TTI:   {},
+	LLM:   {Required: map[string]string{"max_tokens": "int"}},
+	Recon: {Required: map[string]string{"image_url": "string"}},
+}
+
+// SchemaValidationError lists every way a task's metadata breaks its schema
+type SchemaValidationError struct {
+	Type       Type
+	Violations []string
+}
+
+func (e SchemaValidationError) Error() string {
+	return fmt.Sprintf("%s task metadata invalid: %s", e.Type, strings.Join(e.Violations, "; "))
+}
+
+// LoadSchemaRegistry reads per-type schemas from a YAML file keyed by task
+// type name. Types missing from the file keep their default schema.
+func LoadSchemaRegistry(path string) (SchemaRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task schemas: %w", err)
+	}
+
+	var raw map[string]TaskSchema
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse task schemas: %w", err)
+	}
+
+	registry := make(SchemaRegistry, len(defaultSchemaRegistry))
+	for taskType, schema := range defaultSchemaRegistry {
+		registry[taskType] = schema
+	}
+	for name, schema := range raw {
+		taskType, err := ParseType(name)
+		if err != nil {
+			return nil, err
+		}
+		for field, kind := range schema.Required {
+			if !validSchemaKind(kind) {
+				return nil, fmt.Errorf("task schema %s: unknown kind %q for field %q", name, kind, field)
+			}
+		}
+		registry[taskType] = schema
+	}
+	return registry, nil
+}
+
+// Check returns a SchemaValidationError when the task's metadata doesn't
+// satisfy the schema for its type
+func (r SchemaRegistry) Check(task *Tasukete) error {
+	schema, ok := r[task.Type]
+	if !ok {
+		return nil
+	}
+
+	fields := make([]string, 0, len(schema.Required))
+	for field := range schema.Required {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var violations []string
+	for _, field := range fields {
+		kind := schema.Required[field]
+		value, ok := task.Metadata[field]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("missing required field %q", field))
+			continue
+		}
+		if !matchesKind(value, kind) {
+			violations = append(violations, fmt.Sprintf("field %q must be %s, got %T", field, kind, value))
+		}
+	}
+
+	if len(violations) > 0 {
+		return SchemaValidationError{Type: task.Type, Violations: violations}
+	}
+	return nil
+}
+
+func validSchemaKind(kind string) bool {
+	switch kind {
+	case "string", "int", "number", "bool":
+		return true
+	}
+	return false
+}
+
+func matchesKind(value any, kind string) bool {
+	switch kind {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "int":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			// JSON numbers decode as float64
+			return v == math.Trunc(v)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	}
+	return false
+}
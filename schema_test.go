@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_DefaultSchemas(t *testing.T) {
+	tests := []struct {
+		name       string
+		taskType   Type
+		metadata   map[string]any
+		violations int
+	}{
+		{"tti needs nothing", TTI, nil, 0},
+		{"llm ok", LLM, map[string]any{"max_tokens": float64(256)}, 0},
+		{"llm missing max_tokens", LLM, nil, 1},
+		{"llm fractional max_tokens", LLM, map[string]any{"max_tokens": 2.5}, 1},
+		{"llm string max_tokens", LLM, map[string]any{"max_tokens": "256"}, 1},
+		{"recon ok", Recon, map[string]any{"image_url": "https://example.com/a.png"}, 0},
+		{"recon missing image_url", Recon, map[string]any{"max_tokens": 1}, 1},
+		{"recon wrong image_url type", Recon, map[string]any{"image_url": 42}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := NewTasukete(tt.taskType, "prompt", 1)
+			task.Metadata = tt.metadata
+
+			err := task.Validate()
+			if tt.violations == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var schemaErr SchemaValidationError
+			require.True(t, errors.As(err, &schemaErr))
+			assert.Equal(t, tt.taskType, schemaErr.Type)
+			assert.Len(t, schemaErr.Violations, tt.violations)
+		})
+	}
+}
+
+func TestLoadSchemaRegistry(t *testing.T) {
+	path := writeTempFile(t, "schemas.yaml", `
+TTI:
+  required:
+    seed: int
+    style: string
+`)
+	registry, err := LoadSchemaRegistry(path)
+	require.NoError(t, err)
+
+	task := NewTasukete(TTI, "a cat", 1)
+	err = task.ValidateWith(registry)
+	var schemaErr SchemaValidationError
+	require.True(t, errors.As(err, &schemaErr))
+	assert.Equal(t, []string{`missing required field "seed"`, `missing required field "style"`}, schemaErr.Violations)
+
+	task.AddMetadata("seed", float64(7))
+	task.AddMetadata("style", "anime")
+	assert.NoError(t, task.ValidateWith(registry))
+
+	// types not in the file keep the defaults
+	assert.Error(t, NewTasukete(LLM, "hi", 1).ValidateWith(registry))
+}
+
+func TestLoadSchemaRegistry_Invalid(t *testing.T) {
+	_, err := LoadSchemaRegistry(writeTempFile(t, "bad_type.yaml", "VIDEO: {}\n"))
+	assert.Error(t, err)
+
+	_, err = LoadSchemaRegistry(writeTempFile(t, "bad_kind.yaml", "TTI:\n  required:\n    seed: uint\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ModelSelector picks a model ID (1-based) for tasks that don't specify one.
+// stats holds one entry per configured model, in config order.
+type ModelSelector interface {
+	Select(taskType Type, stats []ModelStats) int
+}
+
+// FirstSelector always picks the first configured model
+type FirstSelector struct{}
+
+func (FirstSelector) Select(taskType Type, stats []ModelStats) int {
+	return 1
+}
+
+// LatencyBased picks the model with the lowest average latency so far.
+// Models that haven't served a request yet are tried first, so every model
+// gets a sample.
+type LatencyBased struct{}
+
+func (LatencyBased) Select(taskType Type, stats []ModelStats) int {
+	best, bestLatency := 1, int64(-1)
+	for i, s := range stats {
+		if s.TotalRequests == 0 {
+			return i + 1
+		}
+		if bestLatency < 0 || s.AvgLatencyMs() < bestLatency {
+			best, bestLatency = i+1, s.AvgLatencyMs()
+		}
+	}
+	return best
+}
+
+// RandomSelector picks a model uniformly at random. It is safe for
+// concurrent use.
+type RandomSelector struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewRandomSelector(seed int64) *RandomSelector {
+	return &RandomSelector{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *RandomSelector) Select(taskType Type, stats []ModelStats) int {
+	if len(stats) == 0 {
+		return 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(len(stats)) + 1
+}
+
+// newModelSelector builds the selector for the configured strategy,
+// returning false if the strategy is unknown
+func newModelSelector(strategy string) (ModelSelector, bool) {
+	switch strategy {
+	case "", "first":
+		return FirstSelector{}, true
+	case "latency":
+		return LatencyBased{}, true
+	case "random":
+		return NewRandomSelector(time.Now().UnixNano()), true
+	default:
+		return FirstSelector{}, false
+	}
+}
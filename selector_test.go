@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyBased_PicksFastestModel(t *testing.T) {
+	config := MockConfig()
+	config.Models = []ModelConfig{{Name: "slow"}, {Name: "fast"}, {Name: "untried"}}
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	client.recordModelStats("slow", 900*time.Millisecond, nil)
+	client.recordModelStats("slow", 1100*time.Millisecond, nil)
+	client.recordModelStats("fast", 200*time.Millisecond, nil)
+
+	got := LatencyBased{}.Select(TTI, client.modelStatsByID())
+	assert.Equal(t, 3, got, "untried models go first")
+
+	client.recordModelStats("untried", 500*time.Millisecond, nil)
+	got = LatencyBased{}.Select(TTI, client.modelStatsByID())
+	assert.Equal(t, 2, got)
+}
+
+func TestLatencyBased_NoStatsFallsBackToFirst(t *testing.T) {
+	stats := []ModelStats{{Name: "a"}, {Name: "b"}}
+	assert.Equal(t, 1, LatencyBased{}.Select(TTI, stats))
+}
+
+func TestRandomSelector_StaysInRange(t *testing.T) {
+	stats := []ModelStats{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	selector := NewRandomSelector(42)
+	for i := 0; i < 100; i++ {
+		id := selector.Select(TTI, stats)
+		assert.True(t, id >= 1 && id <= len(stats), "id %d out of range", id)
+	}
+}
+
+func TestRandomSelector_Concurrent(t *testing.T) {
+	stats := []ModelStats{{Name: "a"}, {Name: "b"}}
+	selector := NewRandomSelector(42)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				selector.Select(TTI, stats)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewModelSelector(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     ModelSelector
+		ok       bool
+	}{
+		{"", FirstSelector{}, true},
+		{"first", FirstSelector{}, true},
+		{"latency", LatencyBased{}, true},
+		{"bogus", FirstSelector{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := newModelSelector(tt.strategy)
+		assert.Equal(t, tt.ok, ok, tt.strategy)
+		assert.Equal(t, tt.want, got, tt.strategy)
+	}
+
+	got, ok := newModelSelector("random")
+	assert.True(t, ok)
+	assert.IsType(t, &RandomSelector{}, got)
+}
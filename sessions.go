@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrSessionExpired means the API no longer accepts the session ID
+var ErrSessionExpired = errors.New("session expired")
+
+func isSessionExpired(statusCode int, body []byte) bool {
+	return statusCode == http.StatusUnauthorized || bytes.Contains(body, []byte("invalid_session_id"))
+}
+
+type cachedSession struct {
+	ID        string
+	CreatedAt time.Time
+	slots     chan struct{} // bounds concurrent generations, nil when unbounded
+}
+
+// acquire waits for a free generation slot in the session
+func (s cachedSession) acquire(ctx context.Context) error {
+	if s.slots == nil {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s cachedSession) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// sessionAffinity keeps one long-lived session per model name, for SwarmUI
+// setups that load models per session
+type sessionAffinity struct {
+	mu       sync.Mutex
+	sessions map[string]cachedSession
+	creating map[string]chan struct{} // closed once the model's session request finishes
+}
+
+// modelSession returns the model's session, creating one on first use.
+// Concurrent first uses wait for a single session request, which is made
+// without holding the lock.
+func (c *Client) modelSession(ctx context.Context, modelName string) (cachedSession, error) {
+	for {
+		c.sessions.mu.Lock()
+		if session, ok := c.sessions.sessions[modelName]; ok {
+			c.sessions.mu.Unlock()
+			return session, nil
+		}
+		if wait, ok := c.sessions.creating[modelName]; ok {
+			c.sessions.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return cachedSession{}, ctx.Err()
+			}
+		}
+		if c.sessions.creating == nil {
+			c.sessions.creating = make(map[string]chan struct{})
+		}
+		done := make(chan struct{})
+		c.sessions.creating[modelName] = done
+		c.sessions.mu.Unlock()
+
+		return c.createModelSession(ctx, modelName, done)
+	}
+}
+
+// createModelSession requests the model's session and stores it, closing
+// done to wake callers waiting in modelSession
+func (c *Client) createModelSession(ctx context.Context, modelName string, done chan struct{}) (cachedSession, error) {
+	id, err := c.getNewSession(ctx)
+
+	c.sessions.mu.Lock()
+	defer c.sessions.mu.Unlock()
+	delete(c.sessions.creating, modelName)
+	close(done)
+	if err != nil {
+		return cachedSession{}, err
+	}
+
+	session := cachedSession{ID: id, CreatedAt: time.Now()}
+	if n := c.config.API.SessionParallelism; n > 1 {
+		session.slots = make(chan struct{}, n)
+	}
+	if c.sessions.sessions == nil {
+		c.sessions.sessions = make(map[string]cachedSession)
+	}
+	c.sessions.sessions[modelName] = session
+	return session, nil
+}
+
+// dropModelSession forgets the model's session if it is still id, so the
+// next modelSession call creates a fresh one
+func (c *Client) dropModelSession(modelName, id string) {
+	c.sessions.mu.Lock()
+	defer c.sessions.mu.Unlock()
+	if c.sessions.sessions[modelName].ID == id {
+		delete(c.sessions.sessions, modelName)
+	}
+}
+
+// generateInModelSession runs a generation in the model's own session,
+// refreshing the session once if the API reports it expired. With
+// SessionParallelism set, it waits for one of the session's slots first.
+func (c *Client) generateInModelSession(ctx context.Context, model ModelConfig, params GenerationParams) ([]string, error) {
+	logger := loggerFromContext(ctx, c.logger)
+
+	for refreshed := false; ; refreshed = true {
+		session, err := c.modelSession(ctx, model.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		if err := session.acquire(ctx); err != nil {
+			return nil, err
+		}
+		imageURLs, err := c.generateWithRetry(ctx, session.ID, model, params)
+		session.release()
+		if refreshed || !errors.Is(err, ErrSessionExpired) {
+			return imageURLs, err
+		}
+		logger.Info("Model session expired, refreshing", "model", model.Name, "session_id", session.ID)
+		c.dropModelSession(model.Name, session.ID)
+	}
+}
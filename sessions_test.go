@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionAffinityClient(t *testing.T, mock *MockSwarmUIServer) *Client {
+	t.Helper()
+	server := mock.Start()
+	t.Cleanup(server.Close)
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.SessionAffinityMode = true
+	config.Models = append(config.Models, ModelConfig{Name: "Other", String: "other_model", Width: 512, Height: 512, Steps: 4})
+	config.Models[0].Name = "Default"
+	return NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func generationSessions(mock *MockSwarmUIServer) []any {
+	var sessions []any
+	for _, g := range mock.Generations() {
+		sessions = append(sessions, g["session_id"])
+	}
+	return sessions
+}
+
+func TestSessionAffinity_ReusesSessionPerModel(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	client := sessionAffinityClient(t, mock)
+
+	for _, modelID := range []int{1, 1, 2, 2} {
+		_, err := client.GenerateImage("a cat", modelID)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, mock.CallCount("/API/GetNewSession"))
+	assert.Equal(t, []any{"mock-session-1", "mock-session-1", "mock-session-2", "mock-session-2"}, generationSessions(mock))
+}
+
+func TestSessionAffinity_RefreshesExpiredSession(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	client := sessionAffinityClient(t, mock)
+
+	_, err := client.GenerateImage("a cat", 1)
+	require.NoError(t, err)
+
+	mock.ExpireSession("mock-session-1")
+	_, err = client.GenerateImage("a cat", 1)
+	require.NoError(t, err)
+	_, err = client.GenerateImage("a cat", 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, mock.CallCount("/API/GetNewSession"))
+	assert.Equal(t, []any{"mock-session-1", "mock-session-1", "mock-session-2", "mock-session-2"}, generationSessions(mock))
+}
+
+func TestSessionAffinity_Disabled(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	client := sessionAffinityClient(t, mock)
+	client.config.API.SessionAffinityMode = false
+
+	for range 2 {
+		_, err := client.GenerateImage("a cat", 1)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, mock.CallCount("/API/GetNewSession"))
+}
+
+func TestSessionAffinity_LimitsParallelism(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(50 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.SessionAffinityMode = true
+	config.API.SessionParallelism = 2
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GenerateImage(fmt.Sprintf("a cat %d", i), 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, mock.CallCount("/API/GetNewSession"))
+	assert.Len(t, mock.Generations(), 4)
+	assert.Equal(t, 2, mock.MaxConcurrentGenerations("mock-session-1"))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// drainPollInterval is how often GracefulStop checks for in-flight tasks
+	drainPollInterval = 50 * time.Millisecond
+	// defaultShutdownTimeout is how long main gives GracefulStop when
+	// server.shutdown_timeout_seconds is unset
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+func (c ServerConfig) shutdownTimeout() time.Duration {
+	if c.ShutdownTimeout > 0 {
+		return time.Duration(c.ShutdownTimeout) * time.Second
+	}
+	return defaultShutdownTimeout
+}
+
+// taskShuttingDown is the error on tasks that arrive or wait in the queue
+// while the client stops
+const taskShuttingDown = "client shutting down"
+
+// errClientStopping is returned by pushTask once GracefulStop has begun
+var errClientStopping = errors.New(taskShuttingDown)
+
+// GracefulStop stops accepting tasks, hands off the queued ones and waits
+// up to timeout for the running task to finish before disconnecting.
+// Queued tasks go to the persistent queue when there is one and are failed
+// back to the server otherwise. A task still running at the timeout is
+// persisted too, as it was queued, so it runs again after the restart.
+// Start returns once the client has stopped.
+func (w *WebSocketClient) GracefulStop(timeout time.Duration) error {
+	if !w.stopping.CompareAndSwap(false, true) {
+		return fmt.Errorf("client already stopping")
+	}
+	defer close(w.stopped)
+
+	pending := w.drainQueue()
+	var persistErr error
+	if len(pending) > 0 {
+		persistErr = w.handOffTasks(pending)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for w.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	inFlight := w.inFlight.Load()
+	if inFlight > 0 && w.pending != nil {
+		if err := w.persistRunning(); err != nil && persistErr == nil {
+			persistErr = err
+		}
+	}
+
+	w.disconnect()
+
+	if persistErr != nil {
+		return persistErr
+	}
+	if inFlight > 0 {
+		return fmt.Errorf("%d tasks still in flight after %s", inFlight, timeout)
+	}
+	w.logger.Info("WebSocket client stopped", "handed_off", len(pending))
+	return nil
+}
+
+// drainQueue takes every task still waiting for the worker, the backlog
+// last. Holding the backlog lock keeps the worker from promoting meanwhile.
+func (w *WebSocketClient) drainQueue() []queuedTask {
+	b := &w.backlog
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var pending []queuedTask
+	for {
+		select {
+		case qt := <-w.urgent:
+			pending = append(pending, qt)
+		case qt := <-w.queue:
+			w.depth.Add(-1)
+			pending = append(pending, qt)
+		default:
+			w.depth.Add(-int64(len(b.tasks)))
+			pending = append(pending, b.tasks...)
+			b.tasks = nil
+			return pending
+		}
+	}
+}
+
+// handOffTasks persists queued tasks so they survive the restart, or fails
+// them so the server can hand them to another worker
+func (w *WebSocketClient) handOffTasks(pending []queuedTask) error {
+	if w.pending == nil {
+		for _, qt := range pending {
+			qt.task.AddMetadata("error", taskShuttingDown)
+			w.failTask(qt.ctx, qt.conn, qt.task)
+		}
+		return nil
+	}
+
+	for _, qt := range pending {
+		if err := w.pending.Enqueue(qt.ctx, qt.task); err != nil {
+			return fmt.Errorf("failed to persist queued tasks: %w", err)
+		}
+	}
+	w.logger.Info("Queued tasks persisted", "count", len(pending))
+	return nil
+}
+
+// persistRunning writes the running task to the persistent queue as it was
+// when the worker took it
+func (w *WebSocketClient) persistRunning() error {
+	p := &w.preemption
+	p.mu.Lock()
+	queued := p.queued
+	p.mu.Unlock()
+	if queued == nil {
+		return nil
+	}
+	if err := w.pending.Enqueue(context.Background(), queued); err != nil {
+		return fmt.Errorf("failed to persist running task: %w", err)
+	}
+	w.logger.Warn("Task still running at shutdown timeout, persisted", "uuid", queued.UUID)
+	return nil
+}
+
+// reloadPending moves tasks from the persistent queue back into the task
+// queue after a (re)connect, as far as the queue has room. Tasks left over
+// stay persisted for the next connect or, with Redis, another worker.
+func (w *WebSocketClient) reloadPending(conn *websocket.Conn) {
+	if w.pending == nil {
+		return
+	}
+	reloaded := 0
+	for w.queueDepth() < cap(w.queue) {
+		task, err := w.pending.Dequeue(context.Background(), 0)
+		if errors.Is(err, ErrQueueEmpty) {
+			break
+		}
+		if err != nil {
+			w.logger.Error("Failed to reload persisted task", "error", err)
+			break
+		}
+		ctx := newMessageContext(context.Background(), task.UUID.String(), w.logger)
+		if _, err := w.pushTask(queuedTask{ctx: ctx, conn: conn, task: task}); err != nil {
+			// stopping: put it back for the next start
+			if err := w.pending.Enqueue(ctx, task); err != nil {
+				w.logger.Error("Failed to persist task again", "uuid", task.UUID, "error", err)
+			}
+			break
+		}
+		reloaded++
+	}
+	if reloaded > 0 {
+		w.logger.Info("Persisted tasks reloaded", "count", reloaded)
+	}
+}
+
+// disconnect closes the current connection, if any, with a normal closure
+func (w *WebSocketClient) disconnect() {
+	conn := w.conn.Load()
+	if conn == nil {
+		return
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, taskShuttingDown)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// rejectWhileStopping fails tasks that arrive after GracefulStop was called.
+// pushTask checks again under the backlog lock, so a task that slips past
+// here can't land in the queue after it was drained.
+func (w *WebSocketClient) rejectWhileStopping(ctx context.Context, conn *websocket.Conn, task *Tasukete) bool {
+	if !w.stopping.Load() {
+		return false
+	}
+	w.rejectStopping(ctx, conn, task)
+	return true
+}
+
+func (w *WebSocketClient) rejectStopping(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	loggerFromContext(ctx, w.logger).Info("Rejecting task while stopping", "uuid", task.UUID)
+	task.AddMetadata("error", taskShuttingDown)
+	w.failTask(ctx, conn, task)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readPendingTasks(t *testing.T, path string) []uuid.UUID {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var ids []uuid.UUID
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var task Tasukete
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &task))
+		ids = append(ids, task.UUID)
+	}
+	require.NoError(t, scanner.Err())
+	return ids
+}
+
+func TestGracefulStop_PersistsQueuedTasks(t *testing.T) {
+	config := MockConfig()
+	config.Server.PendingTasksPath = filepath.Join(t.TempDir(), "pending.jsonl")
+	config.Server.QueueDepth = 3 // the last two wait in the backlog
+	w := newTestWebSocketClient(t, config, WithPersistentQueue(NewFileQueue(config.Server.PendingTasksPath)))
+
+	var queued []uuid.UUID
+	for range 5 {
+		task := NewTasukete(TTI, "a cat", 1)
+		w.enqueueTask(context.Background(), nil, task)
+		queued = append(queued, task.UUID)
+	}
+
+	require.NoError(t, w.GracefulStop(10*time.Second))
+	assert.Equal(t, queued, readPendingTasks(t, config.Server.PendingTasksPath))
+	assert.Empty(t, w.queue)
+	assert.Zero(t, w.backlog.len())
+
+	late := NewTasukete(TTI, "a dog", 1)
+	w.enqueueTask(context.Background(), nil, late)
+	assert.Equal(t, StatusFailed, late.Status())
+	assert.Error(t, w.GracefulStop(time.Second), "already stopping")
+}
+
+func TestGracefulStop_WaitsForRunningTask(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(200 * time.Millisecond)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.PendingTasksPath = filepath.Join(t.TempDir(), "pending.jsonl")
+	w := newTestWebSocketClient(t, config, WithPersistentQueue(NewFileQueue(config.Server.PendingTasksPath)))
+	go w.runTaskWorker(w.queue, w.out.Load().done)
+
+	running := NewTasukete(TTI, "a cat", 1)
+	w.enqueueTask(context.Background(), nil, running)
+	require.Eventually(t, func() bool { return w.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+	queued := NewTasukete(TTI, "a dog", 1)
+	w.enqueueTask(context.Background(), nil, queued)
+
+	require.NoError(t, w.GracefulStop(10*time.Second))
+	assert.Equal(t, StatusCompleted, running.Status())
+	assert.Equal(t, []uuid.UUID{queued.UUID}, readPendingTasks(t, config.Server.PendingTasksPath))
+}
+
+func TestGracefulStop_FailsQueuedTasksWithoutPath(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	task := NewTasukete(TTI, "a cat", 1)
+	w.enqueueTask(context.Background(), nil, task)
+
+	require.NoError(t, w.GracefulStop(time.Second))
+	assert.Equal(t, StatusFailed, task.Status())
+	errMsg, _ := task.GetMetadata("error")
+	assert.Equal(t, taskShuttingDown, errMsg)
+}
+
+func TestGracefulStop_Timeout(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	w.inFlight.Add(1)
+
+	start := time.Now()
+	err := w.GracefulStop(100 * time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 tasks still in flight")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestGracefulStop_PersistsRunningTaskAtTimeout(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	mock.SetGenerationLatency(2 * time.Second)
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Server.PendingTasksPath = filepath.Join(t.TempDir(), "pending.jsonl")
+	w := newTestWebSocketClient(t, config, WithPersistentQueue(NewFileQueue(config.Server.PendingTasksPath)))
+	go w.runTaskWorker(w.queue, w.out.Load().done)
+
+	running := NewTasukete(TTI, "a cat", 1)
+	w.enqueueTask(context.Background(), nil, running)
+	require.Eventually(t, func() bool { return w.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	err := w.GracefulStop(100 * time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 tasks still in flight")
+	assert.Equal(t, []uuid.UUID{running.UUID}, readPendingTasks(t, config.Server.PendingTasksPath))
+}
+
+func TestPushTask_AfterStop(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	require.NoError(t, w.GracefulStop(time.Second))
+
+	// a task that got past rejectWhileStopping before the stop still can't
+	// land in the drained queue
+	queued, err := w.pushTask(queuedTask{ctx: context.Background(), task: NewTasukete(TTI, "a cat", 1)})
+	assert.False(t, queued)
+	assert.ErrorIs(t, err, errClientStopping)
+	assert.Empty(t, w.queue)
+	assert.Zero(t, w.queueDepth())
+}
+
+func TestServerConfig_ShutdownTimeout(t *testing.T) {
+	assert.Equal(t, defaultShutdownTimeout, ServerConfig{}.shutdownTimeout())
+	assert.Equal(t, 5*time.Second, ServerConfig{ShutdownTimeout: 5}.shutdownTimeout())
+}
+
+func TestReloadPending(t *testing.T) {
+	config := MockConfig()
+	config.Server.PendingTasksPath = filepath.Join(t.TempDir(), "pending.jsonl")
+	config.Server.QueueDepth = 2
+	pending := NewFileQueue(config.Server.PendingTasksPath)
+	w := newTestWebSocketClient(t, config, WithPersistentQueue(pending))
+
+	var persisted []uuid.UUID
+	for range 3 {
+		task := NewTasukete(TTI, "a cat", 1)
+		require.NoError(t, pending.Enqueue(context.Background(), task))
+		persisted = append(persisted, task.UUID)
+	}
+
+	// only as many as the queue has room for are reloaded, oldest first
+	w.reloadPending(nil)
+	require.Len(t, w.queue, 2)
+	assert.Equal(t, 2, w.queueDepth())
+	assert.Equal(t, persisted[0], (<-w.queue).task.UUID)
+	assert.Equal(t, persisted[1], (<-w.queue).task.UUID)
+	assert.Equal(t, persisted[2:], readPendingTasks(t, config.Server.PendingTasksPath))
+}
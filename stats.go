@@ -0,0 +1,162 @@
+package main
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ModelStats is a snapshot of generation statistics for a single model
+type ModelStats struct {
+	Name           string `json:"name"`
+	TotalRequests  int64  `json:"total_requests"`
+	Failures       int64  `json:"failures"`
+	TotalLatencyMs int64  `json:"total_latency_ms"`
+}
+
+// AvgLatencyMs returns the mean generation latency, or 0 if nothing was recorded
+func (s ModelStats) AvgLatencyMs() int64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	return s.TotalLatencyMs / s.TotalRequests
+}
+
+// SuccessRate returns the share of requests that succeeded, or 0 if nothing was recorded
+func (s ModelStats) SuccessRate() float64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	return float64(s.TotalRequests-s.Failures) / float64(s.TotalRequests)
+}
+
+type modelCounters struct {
+	totalRequests  atomic.Int64
+	failures       atomic.Int64
+	totalLatencyMs atomic.Int64
+}
+
+func (c *Client) recordModelStats(name string, latency time.Duration, err error) {
+	v, _ := c.modelStats.LoadOrStore(name, &modelCounters{})
+	counters := v.(*modelCounters)
+	counters.totalRequests.Add(1)
+	counters.totalLatencyMs.Add(latency.Milliseconds())
+	if err != nil {
+		counters.failures.Add(1)
+	}
+}
+
+// GetModelStats returns a snapshot of the per-model statistics sorted by model name
+func (c *Client) GetModelStats() []ModelStats {
+	var stats []ModelStats
+	c.modelStats.Range(func(key, value any) bool {
+		counters := value.(*modelCounters)
+		stats = append(stats, ModelStats{
+			Name:           key.(string),
+			TotalRequests:  counters.totalRequests.Load(),
+			Failures:       counters.failures.Load(),
+			TotalLatencyMs: counters.totalLatencyMs.Load(),
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// averageLatency is the mean generation latency across all models, 0 before any generation
+func (c *Client) averageLatency() time.Duration {
+	var requests, latencyMs int64
+	c.modelStats.Range(func(_, value any) bool {
+		counters := value.(*modelCounters)
+		requests += counters.totalRequests.Load()
+		latencyMs += counters.totalLatencyMs.Load()
+		return true
+	})
+	if requests == 0 {
+		return 0
+	}
+	return time.Duration(latencyMs/requests) * time.Millisecond
+}
+
+// modelStatsByID returns stats for every configured model in config order,
+// so that index i describes model ID i+1
+func (c *Client) modelStatsByID() []ModelStats {
+	stats := make([]ModelStats, len(c.config.Models))
+	for i, m := range c.config.Models {
+		stats[i].Name = m.Name
+		if v, ok := c.modelStats.Load(m.Name); ok {
+			counters := v.(*modelCounters)
+			stats[i].TotalRequests = counters.totalRequests.Load()
+			stats[i].Failures = counters.failures.Load()
+			stats[i].TotalLatencyMs = counters.totalLatencyMs.Load()
+		}
+	}
+	return stats
+}
+
+// Size histogram buckets are exponential: 64 B, 128 B, ... 128 KiB
+const (
+	sizeBucketStart  = 64
+	sizeBucketFactor = 2
+	sizeBucketCount  = 12
+)
+
+// SizeHistogram is a snapshot of a size distribution. Counts[i] is the
+// number of observations no larger than UpperBounds[i]; observations above
+// the last bound only appear in Count and Sum.
+type SizeHistogram struct {
+	UpperBounds []int64 `json:"upper_bounds"`
+	Counts      []int64 `json:"counts"` // cumulative
+	Count       int64   `json:"count"`
+	Sum         int64   `json:"sum"`
+}
+
+type sizeHistogram struct {
+	bounds  []int64
+	buckets []atomic.Int64 // per bucket, not cumulative
+	count   atomic.Int64
+	sum     atomic.Int64
+}
+
+func newSizeHistogram() *sizeHistogram {
+	bounds := make([]int64, sizeBucketCount)
+	bound := int64(sizeBucketStart)
+	for i := range bounds {
+		bounds[i] = bound
+		bound *= sizeBucketFactor
+	}
+	return &sizeHistogram{bounds: bounds, buckets: make([]atomic.Int64, sizeBucketCount)}
+}
+
+func (h *sizeHistogram) Observe(size int64) {
+	h.count.Add(1)
+	h.sum.Add(size)
+	if i := sort.Search(len(h.bounds), func(i int) bool { return size <= h.bounds[i] }); i < len(h.bounds) {
+		h.buckets[i].Add(1)
+	}
+}
+
+func (h *sizeHistogram) snapshot() SizeHistogram {
+	s := SizeHistogram{
+		UpperBounds: append([]int64(nil), h.bounds...),
+		Counts:      make([]int64, len(h.bounds)),
+		Count:       h.count.Load(),
+		Sum:         h.sum.Load(),
+	}
+	var cumulative int64
+	for i := range h.buckets {
+		cumulative += h.buckets[i].Load()
+		s.Counts[i] = cumulative
+	}
+	return s
+}
+
+// PromptLengths returns the distribution of prompt sizes sent for generation
+func (c *Client) PromptLengths() SizeHistogram {
+	return c.promptLengths.snapshot()
+}
+
+// ImageSizes returns the distribution of downloaded image sizes
+func (c *Client) ImageSizes() SizeHistogram {
+	return c.imageSizes.snapshot()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WebSocket subprotocols, which set how messages are encoded
+const (
+	SubprotocolJSON    = "json"    // JSON text frames
+	SubprotocolMsgpack = "msgpack" // MessagePack binary frames
+)
+
+// supportedSubprotocols are the encodings encodeMessage and decodeMessage
+// implement
+var supportedSubprotocols = []string{SubprotocolJSON, SubprotocolMsgpack}
+
+// resultFrameType is the envelope type of task result frames under msgpack,
+// telling them apart from the binary frames of other messages
+const resultFrameType = "task_result_frame"
+
+// subprotocols are offered to the task server in order of preference,
+// leaving out any we don't implement. [json] when none are left.
+func (c ServerConfig) subprotocols() []string {
+	var offered []string
+	for _, subprotocol := range c.SubProtocols {
+		if slices.Contains(supportedSubprotocols, subprotocol) {
+			offered = append(offered, subprotocol)
+		}
+	}
+	if len(offered) == 0 {
+		return []string{SubprotocolJSON}
+	}
+	return offered
+}
+
+// Subprotocol returns the subprotocol negotiated on the last connection,
+// "" when the server didn't pick one and messages are JSON
+func (w *WebSocketClient) Subprotocol() string {
+	subprotocol, _ := w.subprotocol.Load().(string)
+	return subprotocol
+}
+
+// msgpackMessage is a WebSocketMessage with the payload decoded, so the
+// whole message is MessagePack on the wire
+type msgpackMessage struct {
+	Type    string `msgpack:"type"`
+	Payload any    `msgpack:"payload"`
+}
+
+// encodeMessage encodes msg for the wire in the given subprotocol,
+// returning the frame type to send it as
+func encodeMessage(msg WebSocketMessage, subprotocol string) (int, []byte, error) {
+	if subprotocol != SubprotocolMsgpack {
+		data, err := json.Marshal(msg)
+		return websocket.TextMessage, data, err
+	}
+
+	var payload any
+	if len(msg.Payload) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(msg.Payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&payload); err != nil {
+			return 0, nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+		payload = msgpackNumbers(payload)
+	}
+	data, err := msgpack.Marshal(msgpackMessage{Type: msg.Type, Payload: payload})
+	return websocket.BinaryMessage, data, err
+}
+
+// encodeResultFrame wraps a Boundary or Fragment result frame for the wire.
+// Under msgpack, where every message is a binary frame, it's sent in a
+// resultFrameType envelope so the server can tell it from other messages.
+func encodeResultFrame(frame []byte, subprotocol string) ([]byte, error) {
+	if subprotocol != SubprotocolMsgpack {
+		return frame, nil
+	}
+	return msgpack.Marshal(msgpackMessage{Type: resultFrameType, Payload: frame})
+}
+
+// resultFrameOverhead is how many bytes encodeResultFrame adds to a frame
+// at most
+func resultFrameOverhead(subprotocol string) int {
+	if subprotocol != SubprotocolMsgpack {
+		return 0
+	}
+	empty, _ := encodeResultFrame(nil, subprotocol)
+	// a nil payload is one byte, the largest bin header five
+	return len(empty) + 4
+}
+
+// msgpackNumbers replaces the json.Numbers in v with integers where they
+// fit and floats otherwise, so they aren't encoded as strings
+func msgpackNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, value := range v {
+			v[key] = msgpackNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = msgpackNumbers(value)
+		}
+	}
+	return v
+}
+
+// decodeMessage reads a message encoded in the given subprotocol. The
+// payload is handed on as JSON either way.
+func decodeMessage(r io.Reader, subprotocol string, message *WebSocketMessage) error {
+	if subprotocol != SubprotocolMsgpack {
+		err := json.NewDecoder(r).Decode(message)
+		if errors.Is(err, io.EOF) {
+			// as conn.ReadJSON does, an empty message isn't the end of the stream
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	var decoded msgpackMessage
+	if err := msgpack.NewDecoder(r).Decode(&decoded); err != nil {
+		return malformedPayload("", err)
+	}
+	message.Type = decoded.Type
+	message.Payload = nil
+	if decoded.Payload != nil {
+		payload, err := json.Marshal(decoded.Payload)
+		if err != nil {
+			return malformedPayload(decoded.Type, err)
+		}
+		message.Payload = payload
+	}
+	return nil
+}
+
+// validSubprotocols reports whether every configured subprotocol is one
+// we can speak
+func validSubprotocols(subprotocols []string) bool {
+	for _, subprotocol := range subprotocols {
+		if !slices.Contains(supportedSubprotocols, subprotocol) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// subprotocolServer records what the client sent over a connection
+type subprotocolServer struct {
+	subprotocol string
+	authFrame   int
+	auth        WebSocketMessage
+}
+
+// newSubprotocolServer accepts the given subprotocols and answers the
+// client's auth with auth_success and a models_update in the negotiated
+// encoding
+func newSubprotocolServer(t *testing.T, subprotocols ...string) (*httptest.Server, *subprotocolServer) {
+	t.Helper()
+	record := &subprotocolServer{}
+	upgrader := websocket.Upgrader{Subprotocols: subprotocols}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		record.subprotocol = conn.Subprotocol()
+
+		frame, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		record.authFrame = frame
+		if decodeMessage(bytes.NewReader(data), conn.Subprotocol(), &record.auth) != nil {
+			return
+		}
+
+		for _, reply := range []WebSocketMessage{
+			{Type: "auth_success", Payload: json.RawMessage(`{"token":"t"}`)},
+			{Type: "models_update", Payload: json.RawMessage(`[{"id":1,"name":"remote"}]`)},
+		} {
+			frame, data, _ := encodeMessage(reply, conn.Subprotocol())
+			conn.WriteMessage(frame, data)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.ReadMessage() // wait for the client to go away
+	}))
+	t.Cleanup(server.Close)
+	return server, record
+}
+
+func TestConnect_Subprotocols(t *testing.T) {
+	tests := []struct {
+		name   string
+		offer  []string
+		accept []string
+		want   string
+		frame  int
+	}{
+		{"default json", nil, []string{SubprotocolJSON}, SubprotocolJSON, websocket.TextMessage},
+		{"msgpack", []string{SubprotocolMsgpack, SubprotocolJSON}, []string{SubprotocolMsgpack}, SubprotocolMsgpack, websocket.BinaryMessage},
+		{"server without subprotocols", []string{SubprotocolMsgpack}, nil, "", websocket.TextMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, record := newSubprotocolServer(t, tt.accept...)
+			config := MockConfig()
+			config.Server.SubProtocols = tt.offer
+			useWebSocketServer(config, server)
+			w := newTestWebSocketClient(t, config)
+
+			w.connect()
+			assert.Equal(t, tt.want, record.subprotocol)
+			assert.Equal(t, tt.want, w.Subprotocol())
+			assert.Equal(t, tt.frame, record.authFrame)
+			assert.Equal(t, "auth", record.auth.Type)
+			assert.JSONEq(t, `{"password":"`+config.Server.Passcode+`"}`, string(record.auth.Payload))
+			assert.Equal(t, []Model{{ID: 1, Name: "remote"}}, w.models)
+		})
+	}
+}
+
+func TestEncodeMessage_Msgpack(t *testing.T) {
+	msg := WebSocketMessage{Type: "task_update", Payload: json.RawMessage(`{"id":3,"scale":1.5,"tags":["a"],"meta":{"n":-2}}`)}
+	frame, data, err := encodeMessage(msg, SubprotocolMsgpack)
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, frame)
+
+	// numbers stay numbers on the wire
+	var wire map[string]any
+	require.NoError(t, msgpack.Unmarshal(data, &wire))
+	payload := wire["payload"].(map[string]any)
+	assert.EqualValues(t, 3, payload["id"])
+	assert.Equal(t, 1.5, payload["scale"])
+
+	var decoded WebSocketMessage
+	require.NoError(t, decodeMessage(bytes.NewReader(data), SubprotocolMsgpack, &decoded))
+	assert.Equal(t, msg.Type, decoded.Type)
+	assert.JSONEq(t, string(msg.Payload), string(decoded.Payload))
+
+	_, data, err = encodeMessage(WebSocketMessage{Type: "get_models"}, SubprotocolMsgpack)
+	require.NoError(t, err)
+	require.NoError(t, decodeMessage(bytes.NewReader(data), SubprotocolMsgpack, &decoded))
+	assert.Equal(t, "get_models", decoded.Type)
+	assert.Nil(t, decoded.Payload)
+
+	var protocolErr *WebSocketProtocolError
+	assert.ErrorAs(t, decodeMessage(bytes.NewReader([]byte{0xc1}), SubprotocolMsgpack, &decoded), &protocolErr)
+}
+
+func TestEncodeResultFrame(t *testing.T) {
+	frame := append([]byte("Boundary: b\n"), bytes.Repeat([]byte{0xff}, 70000)...)
+
+	data, err := encodeResultFrame(frame, SubprotocolJSON)
+	require.NoError(t, err)
+	assert.Equal(t, frame, data, "JSON results are the only binary frames, so they go as is")
+
+	data, err = encodeResultFrame(frame, SubprotocolMsgpack)
+	require.NoError(t, err)
+	var envelope struct {
+		Type    string `msgpack:"type"`
+		Payload []byte `msgpack:"payload"`
+	}
+	require.NoError(t, msgpack.Unmarshal(data, &envelope))
+	assert.Equal(t, resultFrameType, envelope.Type)
+	assert.Equal(t, frame, envelope.Payload)
+	assert.LessOrEqual(t, len(data)-len(frame), resultFrameOverhead(SubprotocolMsgpack))
+}
+
+func TestServerConfig_Subprotocols(t *testing.T) {
+	assert.Equal(t, []string{SubprotocolJSON}, ServerConfig{}.subprotocols())
+	assert.Equal(t, []string{SubprotocolMsgpack, SubprotocolJSON}, ServerConfig{SubProtocols: []string{"cbor", SubprotocolMsgpack, SubprotocolJSON}}.subprotocols())
+	assert.Equal(t, []string{SubprotocolJSON}, ServerConfig{SubProtocols: []string{"cbor"}}.subprotocols())
+}
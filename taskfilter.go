@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// taskNotAccepted is the failure reason for tasks rejected by the task filter
+const taskNotAccepted = "not_accepted"
+
+// TaskFilter decides which incoming tasks this client handles
+type TaskFilter interface {
+	Accept(task *Tasukete) bool
+}
+
+// ModelNameFilter accepts tasks for the named models. Tasks refer to models
+// by ID, so Models is the config list the IDs index into. Tasks leaving the
+// model to us (ID 0) are accepted.
+type ModelNameFilter struct {
+	AllowedModels []string
+	Models        []ModelConfig
+}
+
+func (f ModelNameFilter) Accept(task *Tasukete) bool {
+	if task.Model == 0 {
+		return true
+	}
+	if task.Model < 0 || task.Model > len(f.Models) {
+		return false
+	}
+	return slices.Contains(f.AllowedModels, f.Models[task.Model-1].Name)
+}
+
+// AndFilter accepts tasks every filter accepts
+type AndFilter []TaskFilter
+
+func (f AndFilter) Accept(task *Tasukete) bool {
+	for _, filter := range f {
+		if !filter.Accept(task) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrFilter accepts tasks any filter accepts
+type OrFilter []TaskFilter
+
+func (f OrFilter) Accept(task *Tasukete) bool {
+	for _, filter := range f {
+		if filter.Accept(task) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTaskFilter rejects tasks the filter doesn't accept. Repeated options
+// must all accept a task.
+func WithTaskFilter(filter TaskFilter) WebSocketOption {
+	return func(w *WebSocketClient) {
+		f := filter
+		if w.filter != nil {
+			f = AndFilter{w.filter, filter}
+		}
+		w.filter = f
+	}
+}
+
+// acceptTask fails tasks rejected by the filter, or missing requested_by
+// when it's required, reporting whether the task should be dispatched
+func (w *WebSocketClient) acceptTask(ctx context.Context, conn *websocket.Conn, task *Tasukete) bool {
+	if w.config.Server.RequireRequestedBy && task.RequestedBy == "" {
+		loggerFromContext(ctx, w.logger).Info("Task has no requested_by", "uuid", task.UUID)
+		task.AddMetadata("reason", missingRequestedBy)
+		w.rejectTask(ctx, conn, task, time.Now(), errors.New(missingRequestedBy))
+		return false
+	}
+	if w.filter == nil || w.filter.Accept(task) {
+		return true
+	}
+	loggerFromContext(ctx, w.logger).Info("Task not accepted by filter", "uuid", task.UUID, "model", task.Model)
+	task.AddMetadata("reason", taskNotAccepted)
+	w.rejectTask(ctx, conn, task, time.Now(), errors.New(taskNotAccepted))
+	return false
+}
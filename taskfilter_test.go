@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type acceptFunc func(task *Tasukete) bool
+
+func (f acceptFunc) Accept(task *Tasukete) bool { return f(task) }
+
+var (
+	acceptAll  = acceptFunc(func(*Tasukete) bool { return true })
+	acceptNone = acceptFunc(func(*Tasukete) bool { return false })
+)
+
+func TestModelNameFilter(t *testing.T) {
+	filter := ModelNameFilter{
+		AllowedModels: []string{"Flux"},
+		Models:        []ModelConfig{{Name: "SD"}, {Name: "Flux"}},
+	}
+
+	tests := []struct {
+		model int
+		want  bool
+	}{
+		{model: 0, want: true}, // picked by the client
+		{model: 1, want: false},
+		{model: 2, want: true},
+		{model: 3, want: false},
+		{model: -1, want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, filter.Accept(NewTasukete(TTI, "a cat", tt.model)), "model %d", tt.model)
+	}
+}
+
+func TestFilterCombinators(t *testing.T) {
+	task := NewTasukete(TTI, "a cat", 1)
+
+	assert.True(t, AndFilter{}.Accept(task))
+	assert.True(t, AndFilter{acceptAll, acceptAll}.Accept(task))
+	assert.False(t, AndFilter{acceptAll, acceptNone}.Accept(task))
+
+	assert.False(t, OrFilter{}.Accept(task))
+	assert.True(t, OrFilter{acceptNone, acceptAll}.Accept(task))
+	assert.False(t, OrFilter{acceptNone, acceptNone}.Accept(task))
+
+	assert.True(t, OrFilter{acceptNone, AndFilter{acceptAll, acceptAll}}.Accept(task))
+}
+
+func TestWithTaskFilter_Combines(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig(), WithTaskFilter(acceptAll), WithTaskFilter(acceptNone))
+	assert.False(t, w.filter.Accept(NewTasukete(TTI, "a cat", 1)))
+}
+
+func TestHandleMessage_TaskFilter(t *testing.T) {
+	config := MockConfig()
+	config.Models[0].Name = "SD"
+	config.Models = append(config.Models, ModelConfig{Name: "Flux", String: "flux"})
+	w := newTestWebSocketClient(t, config, WithTaskFilter(ModelNameFilter{AllowedModels: []string{"Flux"}, Models: config.Models}))
+
+	send := func(task *Tasukete) {
+		payload, err := json.Marshal(task)
+		require.NoError(t, err)
+		w.handleMessage(nil, WebSocketMessage{Type: "task", Payload: payload})
+	}
+
+	rejected := NewTasukete(TTI, "a cat", 1)
+	send(rejected)
+	assert.Empty(t, w.queue, "rejected tasks must not be dispatched")
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	var update Tasukete
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &update))
+	assert.Equal(t, rejected.UUID, update.UUID)
+	assert.Equal(t, StatusFailed, update.Status())
+	assert.Equal(t, taskNotAccepted, update.Metadata["reason"])
+
+	accepted := NewTasukete(TTI, "a cat", 2)
+	send(accepted)
+	require.Len(t, w.queue, 1)
+	assert.Equal(t, accepted.UUID, (<-w.queue).task.UUID)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// TaskIndex is an inverted index from prompt words to the tasks in a task
+// log that used them, for searching prompts without scanning the log
+type TaskIndex struct {
+	Terms map[string][]uuid.UUID `json:"terms"` // postings in log order
+}
+
+// IndexTasks builds a TaskIndex from the JSONL task log at logPath
+func IndexTasks(logPath string) (*TaskIndex, error) {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	index := &TaskIndex{Terms: make(map[string][]uuid.UUID)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record TaskRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("task log line %d: %w", line, err)
+		}
+		index.add(record.UUID, record.Prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task log: %w", err)
+	}
+	return index, nil
+}
+
+func (idx *TaskIndex) add(id uuid.UUID, prompt string) {
+	seen := make(map[string]bool)
+	for _, term := range tokenizePrompt(prompt) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		idx.Terms[term] = append(idx.Terms[term], id)
+	}
+}
+
+// SearchTasks returns the tasks whose prompts contain every query term, in
+// log order
+func (idx *TaskIndex) SearchTasks(query string) []uuid.UUID {
+	terms := tokenizePrompt(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// sets holds the tasks of each remaining term, built once rather than
+	// scanned for every candidate
+	sets := make([]map[uuid.UUID]struct{}, len(terms)-1)
+	for i, term := range terms[1:] {
+		sets[i] = make(map[uuid.UUID]struct{}, len(idx.Terms[term]))
+		for _, id := range idx.Terms[term] {
+			sets[i][id] = struct{}{}
+		}
+	}
+
+	var result []uuid.UUID
+	seen := make(map[uuid.UUID]struct{})
+	for _, id := range idx.Terms[terms[0]] {
+		if _, dup := seen[id]; dup || !inAll(sets, id) {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+	return result
+}
+
+func inAll(sets []map[uuid.UUID]struct{}, id uuid.UUID) bool {
+	for _, set := range sets {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveIndex writes the index as JSON to path
+func (idx *TaskIndex) SaveIndex(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task index: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadIndex reads an index written by SaveIndex
+func LoadIndex(path string) (*TaskIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	index := &TaskIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse task index %s: %w", path, err)
+	}
+	if index.Terms == nil {
+		index.Terms = make(map[string][]uuid.UUID)
+	}
+	return index, nil
+}
+
+// tokenizePrompt splits a prompt into lowercase words, dropping punctuation
+func tokenizePrompt(prompt string) []string {
+	return strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
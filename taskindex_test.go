@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskIndex(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "tasks.jsonl")
+	taskLog, err := NewTaskLogger(logPath)
+	require.NoError(t, err)
+
+	prompts := []string{
+		"a red cat sitting on a mat",
+		"A Red Dog, running",
+		"blue cat sleeping",
+		"red car at night",
+		"cat, cat, cat",
+		"portrait of a woman",
+		"landscape with red mountains",
+		"a cat and a dog",
+		"neon city at night",
+		"RED CAT in the rain",
+	}
+	ids := make([]uuid.UUID, len(prompts))
+	for i, prompt := range prompts {
+		ids[i] = uuid.New()
+		require.NoError(t, taskLog.Log(TaskRecord{UUID: ids[i], Prompt: prompt}))
+	}
+	require.NoError(t, taskLog.Close())
+
+	index, err := IndexTasks(logPath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		query string
+		want  []uuid.UUID
+	}{
+		{query: "cat", want: []uuid.UUID{ids[0], ids[2], ids[4], ids[7], ids[9]}},
+		{query: "red cat", want: []uuid.UUID{ids[0], ids[9]}},
+		{query: "CAT, red!", want: []uuid.UUID{ids[0], ids[9]}},
+		{query: "cat dog", want: []uuid.UUID{ids[7]}},
+		{query: "at night", want: []uuid.UUID{ids[3], ids[8]}},
+		{query: "red unicorn", want: nil},
+		{query: "", want: nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, index.SearchTasks(tt.query), "query %q", tt.query)
+	}
+
+	indexPath := filepath.Join(dir, "tasks.index.json")
+	require.NoError(t, index.SaveIndex(indexPath))
+	loaded, err := LoadIndex(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, index.SearchTasks("red cat"), loaded.SearchTasks("red cat"))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const taskLogPromptLimit = 200
+
+// TaskRecord is a single line of the task log
+type TaskRecord struct {
+	UUID           uuid.UUID  `json:"uuid"`
+	Type           Type       `json:"type"`
+	Prompt         string     `json:"prompt"`
+	ModelName      string     `json:"model_name"`
+	Seed           int64      `json:"seed,omitempty"`
+	Status         TaskStatus `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    time.Time  `json:"completed_at"`
+	LatencyMs      int64      `json:"latency_ms"`
+	ImageSizeBytes int        `json:"image_size_bytes"`
+	Error          string     `json:"error,omitempty"`
+	RequestedBy    string     `json:"requested_by,omitempty"`
+}
+
+// TaskLogger appends task records to a JSONL file
+type TaskLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewTaskLogger(path string) (*TaskLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskLogger{file: file}, nil
+}
+
+// Log appends one record and syncs it to disk
+func (l *TaskLogger) Log(record TaskRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task record: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write task record: %w", err)
+	}
+	return l.file.Sync()
+}
+
+func (l *TaskLogger) Close() error {
+	return l.file.Close()
+}
+
+func (w *WebSocketClient) recordTask(ctx context.Context, task *Tasukete, start time.Time, imageSize int, taskErr error) {
+	if w.taskLog == nil {
+		return
+	}
+
+	record := TaskRecord{
+		UUID:           task.UUID,
+		Type:           task.Type,
+		Prompt:         truncate(task.Prompt, taskLogPromptLimit),
+		Seed:           taskSeed(task),
+		Status:         task.Status(),
+		CreatedAt:      task.CreatedAt,
+		CompletedAt:    time.Now(),
+		LatencyMs:      time.Since(start).Milliseconds(),
+		ImageSizeBytes: imageSize,
+		RequestedBy:    task.RequestedBy,
+	}
+	if task.Model > 0 && task.Model <= len(w.config.Models) {
+		record.ModelName = w.config.Models[task.Model-1].Name
+	}
+	if taskErr != nil {
+		record.Error = taskErr.Error()
+	}
+
+	if err := w.taskLog.Log(record); err != nil {
+		loggerFromContext(ctx, w.logger).Error("Failed to write task log", "uuid", task.UUID, "error", err)
+	}
+}
+
+// taskSeed reads the seed from task metadata, which arrives as a JSON number
+func taskSeed(task *Tasukete) int64 {
+	v, ok := task.GetMetadata("seed")
+	if !ok {
+		return 0
+	}
+	switch seed := v.(type) {
+	case float64:
+		return int64(seed)
+	case int:
+		return int64(seed)
+	case int64:
+		return seed
+	default:
+		return 0
+	}
+}
+
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit])
+}
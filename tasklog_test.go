@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskLogger_RecordsTasks(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	mock.SetNextImageResponse([]byte("12345678"))
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.Models[0].Name = "SD"
+
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	taskLog, err := NewTaskLogger(path)
+	require.NoError(t, err)
+	defer taskLog.Close()
+
+	w := newTestWebSocketClient(t, config, WithTaskLogger(taskLog))
+
+	ok := NewTasukete(TTI, strings.Repeat("a", 300), 1)
+	ok.AddMetadata("seed", float64(42))
+	w.handleTTITask(context.Background(), nil, ok)
+
+	failed := NewTasukete(TTI, "a dog", 7)
+	w.handleTTITask(context.Background(), nil, failed)
+
+	messages := sentMessages(t, w)
+	require.NotEmpty(t, messages)
+	var last Tasukete
+	require.NoError(t, json.Unmarshal(messages[len(messages)-1].Payload, &last))
+	assert.Equal(t, StatusFailed, last.Status())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []TaskRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record TaskRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, ok.UUID, records[0].UUID)
+	assert.Equal(t, StatusCompleted, records[0].Status)
+	assert.Equal(t, "SD", records[0].ModelName)
+	assert.Equal(t, int64(42), records[0].Seed)
+	assert.Len(t, records[0].Prompt, taskLogPromptLimit)
+	assert.Equal(t, 8, records[0].ImageSizeBytes)
+	assert.Empty(t, records[0].Error)
+
+	assert.Equal(t, failed.UUID, records[1].UUID)
+	assert.Equal(t, StatusFailed, records[1].Status)
+	assert.Empty(t, records[1].ModelName)
+	assert.NotEmpty(t, records[1].Error)
+}
+
+func TestTaskLogger_RecordsRejectedTasks(t *testing.T) {
+	config := MockConfig()
+	config.Server.RequireRequestedBy = true
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	taskLog, err := NewTaskLogger(path)
+	require.NoError(t, err)
+	defer taskLog.Close()
+	w := newTestWebSocketClient(t, config, WithTaskLogger(taskLog))
+
+	anonymous := NewTasukete(TTI, "a cat", 1)
+	assert.False(t, w.acceptTask(context.Background(), nil, anonymous))
+
+	needsGPU := NewTasukete(TTI, "a dog", 1)
+	needsGPU.RequiredCapabilities = []string{"fp16"}
+	w.handleTask(context.Background(), nil, needsGPU)
+
+	invalid := NewTasukete(TTI, "", 1)
+	w.handleTask(context.Background(), nil, invalid)
+
+	custom := registerTestTaskType(t, "TEST_LOGGED")
+	w.RegisterTaskHandler(custom, TaskHandlerFunc(func(ctx context.Context, conn *websocket.Conn, wsc *WebSocketClient, task *Tasukete) error {
+		return errors.New("handler broke")
+	}))
+	handled := NewTasukete(custom, "a bird", 1)
+	w.handleTask(context.Background(), nil, handled)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var records []TaskRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record TaskRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+
+	assert.Equal(t, anonymous.UUID, records[0].UUID)
+	assert.Equal(t, StatusFailed, records[0].Status)
+	assert.Equal(t, missingRequestedBy, records[0].Error)
+
+	assert.Equal(t, needsGPU.UUID, records[1].UUID)
+	assert.Equal(t, StatusFailed, records[1].Status)
+	assert.Contains(t, records[1].Error, capabilityMismatch)
+
+	assert.Equal(t, invalid.UUID, records[2].UUID)
+	assert.NotEmpty(t, records[2].Error)
+
+	assert.Equal(t, handled.UUID, records[3].UUID)
+	assert.Equal(t, "handler broke", records[3].Error)
+}
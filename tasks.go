@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,9 +17,10 @@ import (
 type Type int
 
 const (
-	TTI   Type = iota // Text to Image
-	LLM               // Language Model
-	Recon             // Recognition
+	TTI       Type = iota // Text to Image
+	LLM                   // Language Model
+	Recon                 // Recognition
+	Heartbeat             // echoes back to check the pipeline, generates nothing
 )
 
 func (t Type) String() string {
@@ -25,7 +31,12 @@ func (t Type) String() string {
 		return "LLM"
 	case Recon:
 		return "RECON"
+	case Heartbeat:
+		return "HEARTBEAT"
 	default:
+		if name, ok := customTypeName(t); ok {
+			return name
+		}
 		return "UNKNOWN"
 	}
 }
@@ -41,18 +52,38 @@ func (t *Type) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	// Convert string back to Type
+	parsed, err := ParseType(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// ParseType converts a type name back to Type
+func ParseType(s string) (Type, error) {
+	if t, ok := parseBuiltinType(s); ok {
+		return t, nil
+	}
+	if t, ok := parseCustomType(s); ok {
+		return t, nil
+	}
+	return 0, fmt.Errorf("unknown type: %s", s)
+}
+
+func parseBuiltinType(s string) (Type, bool) {
 	switch s {
 	case "TTI":
-		*t = TTI
+		return TTI, true
 	case "LLM":
-		*t = LLM
+		return LLM, true
 	case "RECON":
-		*t = Recon
+		return Recon, true
+	case "HEARTBEAT":
+		return Heartbeat, true
 	default:
-		return fmt.Errorf("unknown type: %s", s)
+		return 0, false
 	}
-	return nil
 }
 
 type TaskStatus int
@@ -62,6 +93,7 @@ const (
 	StatusProcessing
 	StatusCompleted
 	StatusFailed
+	StatusQueued // waiting for a free worker
 )
 
 func (ts TaskStatus) String() string {
@@ -74,6 +106,8 @@ func (ts TaskStatus) String() string {
 		return "COMPLETED"
 	case StatusFailed:
 		return "FAILED"
+	case StatusQueued:
+		return "QUEUED"
 	default:
 		return "UNKNOWN"
 	}
@@ -99,6 +133,8 @@ func (ts *TaskStatus) UnmarshalJSON(data []byte) error {
 		*ts = StatusCompleted
 	case "FAILED":
 		*ts = StatusFailed
+	case "QUEUED":
+		*ts = StatusQueued
 	default:
 		return fmt.Errorf("unknown task status: %s", s)
 	}
@@ -106,13 +142,35 @@ func (ts *TaskStatus) UnmarshalJSON(data []byte) error {
 }
 
 type Tasukete struct {
-	UUID      uuid.UUID      `json:"uuid"`
-	Type      Type           `json:"type"`
-	Prompt    string         `json:"prompt"`
-	Model     int            `json:"model"`
-	Metadata  map[string]any `json:"metadata"`
-	CreatedAt time.Time      `json:"created_at"`
-	Status    TaskStatus     `json:"status"`
+	UUID           uuid.UUID      `json:"uuid"`
+	Type           Type           `json:"type"`
+	Prompt         string         `json:"prompt"`
+	NegativePrompt string         `json:"negative_prompt,omitempty"`
+	PromptAlias    string         `json:"prompt_alias,omitempty"`
+	LoraPreset     string         `json:"lora_preset,omitempty"`
+	NotifyURL      string         `json:"notify_url,omitempty"`
+	Model          int            `json:"model"`
+	Metadata       map[string]any `json:"metadata"`
+	CreatedAt      time.Time      `json:"created_at"`
+	status         TaskStatus     `json:"-"`                        // changed through UpdateStatus
+	ImageChecksum  string         `json:"image_checksum,omitempty"` // hex SHA-256 of the result image
+
+	RequiredCapabilities []string `json:"required_capabilities,omitempty"` // worker features the task needs
+	CostEstimate         float64  `json:"cost_estimate,omitempty"`         // set before generation when pricing is configured
+	RetryCount           int      `json:"retry_count,omitempty"`           // generation retries so far
+	Priority             int      `json:"priority,omitempty"`              // higher runs first when preemption is enabled
+	RequestedBy          string   `json:"requested_by,omitempty"`          // user the task was made for
+
+	Language string `json:"language,omitempty"` // prompt language, translated to English unless empty or "en"
+
+	ControlNet         *ControlNetConfig `json:"controlnet,omitempty"`
+	ControlNetImageB64 string            `json:"controlnet_image_b64,omitempty"` // guidance image, base64 encoded
+
+	OutputMimeType string `json:"output_mime_type,omitempty"` // "image/png", "image/jpeg" or "image/webp", the model's format when empty
+
+	Annotations map[string]string `json:"annotations,omitempty"` // free-form string metadata, e.g. source or project_id
+
+	resultCh chan struct{} // closed once the task is terminal, nil for tasks not made by NewTasukete
 }
 
 // constructor
@@ -124,13 +182,80 @@ func NewTasukete(taskType Type, prompt string, model int) *Tasukete {
 		Model:     model,
 		Metadata:  make(map[string]any),
 		CreatedAt: time.Now(),
-		Status:    StatusPending,
+		status:    StatusPending,
+		resultCh:  make(chan struct{}),
 	}
 }
 
+// validTransitions lists the statuses each status may move to. Completed
+// and Failed are terminal. Pending and queued tasks may fail when they are
+// rejected before processing starts, and processing tasks go back to
+// pending when they are preempted.
+var validTransitions = map[TaskStatus][]TaskStatus{
+	StatusPending:    {StatusQueued, StatusProcessing, StatusFailed},
+	StatusQueued:     {StatusProcessing, StatusFailed},
+	StatusProcessing: {StatusCompleted, StatusFailed, StatusPending},
+}
+
 // Methods for task management
-func (t *Tasukete) UpdateStatus(status TaskStatus) {
-	t.Status = status
+
+// Status returns the task's current status
+func (t *Tasukete) Status() TaskStatus {
+	return t.status
+}
+
+// UpdateStatus moves the task to status, leaving it unchanged and returning
+// an error if the transition isn't allowed
+func (t *Tasukete) UpdateStatus(status TaskStatus) error {
+	if !slices.Contains(validTransitions[t.status], status) {
+		return fmt.Errorf("invalid status transition from %s to %s", t.status, status)
+	}
+	t.status = status
+	if t.terminal() {
+		t.complete()
+	}
+	return nil
+}
+
+func (t *Tasukete) terminal() bool {
+	return t.status == StatusCompleted || t.status == StatusFailed
+}
+
+// complete wakes up everyone waiting on the task. UpdateStatus calls it on
+// the one transition into a terminal status.
+func (t *Tasukete) complete() {
+	if t.resultCh != nil {
+		close(t.resultCh)
+	}
+}
+
+// Wait blocks until the task is completed or failed, or ctx is done. Tasks
+// not made by NewTasukete can't be waited on and only report whether they
+// are already terminal.
+func (t *Tasukete) Wait(ctx context.Context) error {
+	if t.resultCh == nil {
+		if t.terminal() {
+			return nil
+		}
+		return errors.New("task can't be waited on")
+	}
+	select {
+	case <-t.resultCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// snapshot returns a copy of the task that can be read or changed without
+// affecting it. The copy can't be waited on.
+func (t *Tasukete) snapshot() *Tasukete {
+	c := *t
+	c.Metadata = maps.Clone(t.Metadata)
+	c.Annotations = maps.Clone(t.Annotations)
+	c.RequiredCapabilities = slices.Clone(t.RequiredCapabilities)
+	c.resultCh = nil
+	return &c
 }
 
 func (t *Tasukete) AddMetadata(key string, value any) {
@@ -148,10 +273,60 @@ func (t *Tasukete) GetMetadata(key string) (any, bool) {
 	return val, exists
 }
 
+func (t *Tasukete) AddAnnotation(key, value string) {
+	if t.Annotations == nil {
+		t.Annotations = make(map[string]string)
+	}
+	t.Annotations[key] = value
+}
+
+func (t *Tasukete) GetAnnotation(key string) (string, bool) {
+	val, exists := t.Annotations[key]
+	return val, exists
+}
+
+func (t *Tasukete) RemoveAnnotation(key string) {
+	delete(t.Annotations, key)
+}
+
+// Validate checks the task against the default metadata schemas
 func (t *Tasukete) Validate() error {
+	return t.ValidateWith(defaultSchemaRegistry)
+}
+
+// ValidateWith checks the task, including its metadata against schemas
+func (t *Tasukete) ValidateWith(schemas SchemaRegistry) error {
 	if t.UUID == uuid.Nil {
 		return errors.New("invalid UUID")
 	}
+	if t.RetryCount < 0 {
+		return fmt.Errorf("invalid retry count: %d", t.RetryCount)
+	}
+	for key := range t.Annotations {
+		// dots are reserved for namespacing
+		if strings.Contains(key, ".") {
+			return fmt.Errorf("invalid annotation key %q: must not contain \".\"", key)
+		}
+	}
+	return schemas.Check(t)
+}
+
+// ValidateRetryCount rejects tasks that have already used up maxRetries
+func (t *Tasukete) ValidateRetryCount(maxRetries int) error {
+	if t.RetryCount > maxRetries {
+		return fmt.Errorf("retry count %d exceeds the limit of %d", t.RetryCount, maxRetries)
+	}
+	return nil
+}
+
+// VerifyImageChecksum checks imageData against the checksum recorded on the task
+func VerifyImageChecksum(imageData []byte, task *Tasukete) error {
+	if task.ImageChecksum == "" {
+		return errors.New("task has no image checksum")
+	}
+	if got := hashImage(imageData); got != task.ImageChecksum {
+		return fmt.Errorf("image checksum mismatch: expected %s, got %s", task.ImageChecksum, got)
+	}
 	return nil
 }
 
@@ -159,10 +334,12 @@ func (t *Tasukete) MarshalJSON() ([]byte, error) {
 	type Alias Tasukete // avoid recursive JSON marshaling
 	return json.Marshal(&struct {
 		*Alias
-		UUID string `json:"uuid"`
+		UUID   string     `json:"uuid"`
+		Status TaskStatus `json:"status"`
 	}{
-		Alias: (*Alias)(t),
-		UUID:  t.UUID.String(),
+		Alias:  (*Alias)(t),
+		UUID:   t.UUID.String(),
+		Status: t.status,
 	})
 }
 
@@ -170,13 +347,16 @@ func (t *Tasukete) UnmarshalJSON(data []byte) error {
 	type Alias Tasukete
 	aux := &struct {
 		*Alias
-		UUID string `json:"uuid"`
+		UUID   string     `json:"uuid"`
+		Status TaskStatus `json:"status"`
 	}{
-		Alias: (*Alias)(t),
+		Alias:  (*Alias)(t),
+		Status: t.status,
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	t.status = aux.Status
 	if parsedUUID, err := uuid.Parse(aux.UUID); err != nil {
 		return err
 	} else {
@@ -184,3 +364,27 @@ func (t *Tasukete) UnmarshalJSON(data []byte) error {
 	}
 	return nil
 }
+
+// ParseTaskFromReader decodes a task from r, filling in the UUID and
+// creation time when the payload omits them
+func ParseTaskFromReader(r io.Reader) (*Tasukete, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("failed to decode task: %w", err)
+	}
+	task := NewTasukete(TTI, "", 0)
+	if _, ok := fields["uuid"]; !ok {
+		fields["uuid"], _ = json.Marshal(task.UUID)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode task: %w", err)
+	}
+	if err := json.Unmarshal(data, task); err != nil {
+		return nil, fmt.Errorf("failed to decode task: %w", err)
+	}
+	if err := task.Validate(); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
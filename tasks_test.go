@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
@@ -28,7 +30,7 @@ func TestTasukete_MarshallJSON(t *testing.T) {
 				Model:     1,
 				Metadata:  map[string]any{"seed": 12345},
 				CreatedAt: fixedTime,
-				Status:    StatusPending,
+				status:    StatusPending,
 			},
 			expected: `{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"TTI","prompt":"generate a cat","model":1,"metadata":{"seed":12345},"created_at":"2024-02-14T12:00:00Z","status":"PENDING"}`,
 			wantErr:  false,
@@ -42,7 +44,7 @@ func TestTasukete_MarshallJSON(t *testing.T) {
 				Model:     2,
 				Metadata:  nil,
 				CreatedAt: fixedTime,
-				Status:    StatusProcessing,
+				status:    StatusProcessing,
 			},
 			expected: `{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"LLM","prompt":"test prompt","model":2,"metadata":null,"created_at":"2024-02-14T12:00:00Z","status":"PROCESSING"}`,
 			wantErr:  false,
@@ -79,7 +81,7 @@ func TestTasukete_UnmarshallJSON(t *testing.T) {
 				Model:     1,
 				Metadata:  map[string]any{"seed": float64(12345)}, // JSON numbers are decoded as float64
 				CreatedAt: time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC),
-				Status:    StatusPending,
+				status:    StatusPending,
 			},
 			wantErr: false,
 		},
@@ -88,6 +90,11 @@ func TestTasukete_UnmarshallJSON(t *testing.T) {
 			json:    `{"uuid":"invalid-uuid","type":"TTI","prompt":"test","model":1}`,
 			wantErr: true,
 		},
+		{
+			name:    "missing uuid",
+			json:    `{"type":"TTI","prompt":"test","model":1}`,
+			wantErr: true,
+		},
 		{
 			name:    "invalid type",
 			json:    `{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"INVALID","prompt":"test","model":1}`,
@@ -108,3 +115,146 @@ func TestTasukete_UnmarshallJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestTasukete_UpdateStatus(t *testing.T) {
+	statuses := []TaskStatus{StatusPending, StatusQueued, StatusProcessing, StatusCompleted, StatusFailed}
+	valid := map[[2]TaskStatus]bool{
+		{StatusPending, StatusQueued}:       true,
+		{StatusPending, StatusProcessing}:   true,
+		{StatusPending, StatusFailed}:       true,
+		{StatusQueued, StatusProcessing}:    true,
+		{StatusQueued, StatusFailed}:        true,
+		{StatusProcessing, StatusCompleted}: true,
+		{StatusProcessing, StatusFailed}:    true,
+		{StatusProcessing, StatusPending}:   true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			t.Run(from.String()+"->"+to.String(), func(t *testing.T) {
+				task := NewTasukete(TTI, "a cat", 1)
+				task.status = from
+
+				err := task.UpdateStatus(to)
+				if valid[[2]TaskStatus{from, to}] {
+					assert.NoError(t, err)
+					assert.Equal(t, to, task.Status())
+				} else {
+					assert.Error(t, err)
+					assert.Equal(t, from, task.Status())
+				}
+			})
+		}
+	}
+}
+
+func TestTasukete_Wait(t *testing.T) {
+	t.Run("returns after completion", func(t *testing.T) {
+		mock := NewMockSwarmUIServer()
+		server := mock.Start()
+		defer server.Close()
+		config := MockConfig()
+		useMockAPI(config, server)
+		w := newTestWebSocketClient(t, config)
+
+		task := NewTasukete(TTI, "a cat", 1)
+		go w.handleTTITask(context.Background(), nil, task)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, task.Wait(ctx))
+		assert.Equal(t, StatusCompleted, task.Status())
+	})
+
+	t.Run("returns after failure", func(t *testing.T) {
+		task := NewTasukete(TTI, "a cat", 1)
+		go task.UpdateStatus(StatusFailed)
+		assert.NoError(t, task.Wait(context.Background()))
+	})
+
+	t.Run("respects cancellation", func(t *testing.T) {
+		task := NewTasukete(TTI, "a cat", 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, task.Wait(ctx), context.DeadlineExceeded)
+		assert.Equal(t, StatusPending, task.Status())
+	})
+
+	t.Run("deserialized task", func(t *testing.T) {
+		var task Tasukete
+		assert.NoError(t, json.Unmarshal([]byte(`{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"TTI","status":"PENDING"}`), &task))
+		assert.Error(t, task.Wait(context.Background()))
+
+		assert.NoError(t, json.Unmarshal([]byte(`{"uuid":"550e8400-e29b-41d4-a716-446655440000","status":"COMPLETED"}`), &task))
+		assert.NoError(t, task.Wait(context.Background()))
+	})
+}
+
+func TestParseTaskFromReader(t *testing.T) {
+	t.Run("minimal task", func(t *testing.T) {
+		task, err := ParseTaskFromReader(bytes.NewBufferString(`{"prompt":"a cat"}`))
+		assert.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, task.UUID)
+		assert.Equal(t, TTI, task.Type)
+		assert.Equal(t, "a cat", task.Prompt)
+		assert.Equal(t, 0, task.Model)
+		assert.False(t, task.CreatedAt.IsZero())
+	})
+
+	t.Run("full task", func(t *testing.T) {
+		task, err := ParseTaskFromReader(bytes.NewBufferString(
+			`{"uuid":"550e8400-e29b-41d4-a716-446655440000","type":"TTI","prompt":"a dog","model":2}`))
+		assert.NoError(t, err)
+		assert.Equal(t, uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"), task.UUID)
+		assert.Equal(t, 2, task.Model)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := ParseTaskFromReader(bytes.NewBufferString(`{"prompt":`))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid uuid", func(t *testing.T) {
+		_, err := ParseTaskFromReader(bytes.NewBufferString(`{"uuid":"nope","prompt":"a cat"}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifyImageChecksum(t *testing.T) {
+	task := NewTasukete(TTI, "a cat", 1)
+	image := []byte("abc")
+
+	assert.Error(t, VerifyImageChecksum(image, task), "missing checksum")
+
+	task.ImageChecksum = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	assert.NoError(t, VerifyImageChecksum(image, task))
+
+	corrupted := []byte("abd")
+	assert.Error(t, VerifyImageChecksum(corrupted, task))
+}
+
+func TestTasukete_Annotations(t *testing.T) {
+	task := NewTasukete(TTI, "a cat", 1)
+	_, ok := task.GetAnnotation("source")
+	assert.False(t, ok)
+
+	task.AddAnnotation("source", "discord")
+	task.AddAnnotation("project_id", "p-42")
+	task.AddAnnotation("source", "slack")
+	source, ok := task.GetAnnotation("source")
+	assert.True(t, ok)
+	assert.Equal(t, "slack", source)
+
+	task.RemoveAnnotation("source")
+	_, ok = task.GetAnnotation("source")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"project_id": "p-42"}, task.Annotations)
+
+	data, err := json.Marshal(task)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"annotations":{"project_id":"p-42"}`)
+	assert.NoError(t, task.Validate())
+
+	task.AddAnnotation("acme.department", "art")
+	assert.ErrorContains(t, task.Validate(), "acme.department")
+}
@@ -0,0 +1,36 @@
+package main
+
+import "crypto/tls"
+
+// TLS versions accepted by server.min_tls_version
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+func validMinTLSVersion(version string) bool {
+	switch version {
+	case "", TLSVersion12, TLSVersion13:
+		return true
+	default:
+		return false
+	}
+}
+
+// minTLSVersion maps MinTLSVersion to its crypto/tls constant. Unknown
+// values fall back to TLS 1.2.
+func (s ServerConfig) minTLSVersion() uint16 {
+	if s.MinTLSVersion == TLSVersion13 {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// tlsConfig is used for every connection to the task server
+func (s ServerConfig) tlsConfig() *tls.Config {
+	// TODO: For production, use proper certificate validation instead of InsecureSkipVerify
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         s.minTLSVersion(),
+	}
+}
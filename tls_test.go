@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVersionedTLSServer starts a TLS server limited to maxVersion that
+// records the negotiated version of each request
+func newVersionedTLSServer(t *testing.T, maxVersion uint16) (*httptest.Server, chan uint16) {
+	t.Helper()
+	versions := make(chan uint16, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		versions <- r.TLS.Version
+		http.Error(w, "go away", http.StatusForbidden)
+	}))
+	server.TLS = &tls.Config{MaxVersion: maxVersion}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // failed handshakes are expected
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, versions
+}
+
+func TestServerConfig_MinTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+	}{
+		{"", tls.VersionTLS12},
+		{TLSVersion12, tls.VersionTLS12},
+		{TLSVersion13, tls.VersionTLS13},
+		{"1.0", tls.VersionTLS12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			config := ServerConfig{MinTLSVersion: tt.version}
+			assert.Equal(t, tt.want, config.tlsConfig().MinVersion)
+		})
+	}
+}
+
+func TestConnect_MinTLSVersion(t *testing.T) {
+	t.Run("negotiates TLS 1.3", func(t *testing.T) {
+		server, versions := newVersionedTLSServer(t, tls.VersionTLS13)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		config.Server.MinTLSVersion = TLSVersion13
+		w := newTestWebSocketClient(t, config)
+
+		assert.Error(t, w.connect(), "server rejects the upgrade")
+		assert.Equal(t, uint16(tls.VersionTLS13), <-versions)
+	})
+
+	t.Run("refuses a TLS 1.2 server", func(t *testing.T) {
+		server, versions := newVersionedTLSServer(t, tls.VersionTLS12)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		config.Server.MinTLSVersion = TLSVersion13
+		w := newTestWebSocketClient(t, config)
+
+		err := w.connect()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dial error")
+		assert.Empty(t, versions)
+	})
+
+	t.Run("accepts a TLS 1.2 server by default", func(t *testing.T) {
+		server, versions := newVersionedTLSServer(t, tls.VersionTLS12)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		w := newTestWebSocketClient(t, config)
+
+		assert.Error(t, w.connect(), "server rejects the upgrade")
+		assert.Equal(t, uint16(tls.VersionTLS12), <-versions)
+	})
+}
+
+func TestHTTPUploader_MinTLSVersion(t *testing.T) {
+	t.Run("negotiates TLS 1.3", func(t *testing.T) {
+		server, versions := newVersionedTLSServer(t, tls.VersionTLS13)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		config.Server.MinTLSVersion = TLSVersion13
+
+		_, err := NewHTTPUploader(config.Server).Upload(context.Background(), []byte("test image data"), "cat.png")
+		assert.Error(t, err, "server rejects the upload")
+		assert.Equal(t, uint16(tls.VersionTLS13), <-versions)
+	})
+
+	t.Run("refuses a TLS 1.2 server", func(t *testing.T) {
+		server, versions := newVersionedTLSServer(t, tls.VersionTLS12)
+		config := MockConfig()
+		useWebSocketServer(config, server)
+		config.Server.MinTLSVersion = TLSVersion13
+
+		_, err := NewHTTPUploader(config.Server).Upload(context.Background(), []byte("test image data"), "cat.png")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "protocol version")
+		assert.Empty(t, versions)
+	})
+}
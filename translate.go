@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// promptLanguage is the language prompts are translated into for the models
+const promptLanguage = "en"
+
+// Prompt translation providers for api.translation.provider
+const (
+	TranslationProviderGoogle = "google"
+)
+
+// TranslationConfig selects how non-English prompts are translated
+type TranslationConfig struct {
+	Provider string `yaml:"provider"` // "" (no translation) or "google"
+	APIKey   string `yaml:"api_key"`
+}
+
+// PromptTranslator translates prompts before generation
+type PromptTranslator interface {
+	Translate(ctx context.Context, text, targetLang string) (string, error)
+}
+
+// NoopTranslator returns prompts unchanged
+type NoopTranslator struct{}
+
+func (NoopTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	return text, nil
+}
+
+// newGoogleTranslator is set by translate_google.go in binaries built with
+// -tags googletranslate
+var newGoogleTranslator func(apiKey string) PromptTranslator
+
+// newPromptTranslator builds the configured translator, NoopTranslator
+// when none is configured
+func newPromptTranslator(config TranslationConfig) (PromptTranslator, error) {
+	switch config.Provider {
+	case "":
+		return NoopTranslator{}, nil
+	case TranslationProviderGoogle:
+		if newGoogleTranslator == nil {
+			return nil, fmt.Errorf("translation provider %s needs a build with -tags googletranslate", config.Provider)
+		}
+		return newGoogleTranslator(config.APIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider: %s", config.Provider)
+	}
+}
+
+// WithPromptTranslator translates prompts of tasks with a non-English language
+func WithPromptTranslator(translator PromptTranslator) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.translator = translator
+	}
+}
+
+// translatePrompts returns the task's prompt and negative prompt in
+// promptLanguage, unchanged for English tasks
+func (w *WebSocketClient) translatePrompts(ctx context.Context, task *Tasukete) (prompt, negative string, err error) {
+	if task.Language == "" || task.Language == promptLanguage {
+		return task.Prompt, task.NegativePrompt, nil
+	}
+
+	prompt, err = w.translator.Translate(ctx, task.Prompt, promptLanguage)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to translate prompt from %s: %w", task.Language, err)
+	}
+	if task.NegativePrompt != "" {
+		negative, err = w.translator.Translate(ctx, task.NegativePrompt, promptLanguage)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to translate negative prompt from %s: %w", task.Language, err)
+		}
+	}
+	return prompt, negative, nil
+}
@@ -0,0 +1,76 @@
+//go:build googletranslate
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// googleTranslateURL is the Cloud Translation v2 endpoint
+const googleTranslateURL = "https://translation.googleapis.com/language/translate/v2"
+
+func init() {
+	newGoogleTranslator = func(apiKey string) PromptTranslator {
+		return NewGoogleTranslateTranslator(apiKey)
+	}
+}
+
+// GoogleTranslateTranslator translates prompts with the Google Cloud
+// Translation API, detecting the source language
+type GoogleTranslateTranslator struct {
+	apiKey     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+func NewGoogleTranslateTranslator(apiKey string) *GoogleTranslateTranslator {
+	return &GoogleTranslateTranslator{
+		apiKey:     apiKey,
+		endpoint:   googleTranslateURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *GoogleTranslateTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	form := url.Values{
+		"q":      {text},
+		"target": {targetLang},
+		"format": {"text"},
+		"key":    {g.apiKey},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", parseAPIError(resp)
+	}
+
+	var result struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode translation: %w", err)
+	}
+	if len(result.Data.Translations) == 0 {
+		return "", fmt.Errorf("translation response has no translations")
+	}
+	return result.Data.Translations[0].TranslatedText, nil
+}
@@ -0,0 +1,34 @@
+//go:build googletranslate
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleTranslateTranslator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "eine Katze", r.Form.Get("q"))
+		assert.Equal(t, "en", r.Form.Get("target"))
+		assert.Equal(t, "secret", r.Form.Get("key"))
+		w.Write([]byte(`{"data":{"translations":[{"translatedText":"a cat","detectedSourceLanguage":"de"}]}}`))
+	}))
+	defer server.Close()
+
+	translator := NewGoogleTranslateTranslator("secret")
+	translator.endpoint = server.URL
+	text, err := translator.Translate(context.Background(), "eine Katze", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "a cat", text)
+
+	configured, err := newPromptTranslator(TranslationConfig{Provider: TranslationProviderGoogle, APIKey: "secret"})
+	require.NoError(t, err)
+	assert.IsType(t, &GoogleTranslateTranslator{}, configured)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTranslator prefixes text with its target language and records calls
+type mockTranslator struct {
+	calls []string
+	err   error
+}
+
+func (m *mockTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	m.calls = append(m.calls, text)
+	if m.err != nil {
+		return "", m.err
+	}
+	return targetLang + ": " + text, nil
+}
+
+func TestHandleTTITask_TranslatesPrompt(t *testing.T) {
+	tests := []struct {
+		name           string
+		language       string
+		wantPrompt     string
+		wantNegative   string
+		wantTranslated bool
+	}{
+		{"no language", "", "eine Katze", "Hund", false},
+		{"english", "en", "eine Katze", "Hund", false},
+		{"german", "de", "en: eine Katze", "en: Hund", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockSwarmUIServer()
+			server := mock.Start()
+			defer server.Close()
+			config := MockConfig()
+			useMockAPI(config, server)
+
+			translator := &mockTranslator{}
+			w := newTestWebSocketClient(t, config, WithPromptTranslator(translator))
+			task := NewTasukete(TTI, "eine Katze", 1)
+			task.NegativePrompt = "Hund"
+			task.Language = tt.language
+			w.handleTTITask(context.Background(), nil, task)
+
+			require.Equal(t, StatusCompleted, task.Status())
+			require.Len(t, mock.Generations(), 1)
+			assert.Equal(t, tt.wantPrompt, mock.Generations()[0]["prompt"])
+			assert.Equal(t, tt.wantNegative, mock.Generations()[0]["negativeprompt"])
+			assert.Equal(t, tt.wantTranslated, len(translator.calls) > 0)
+			assert.Equal(t, "eine Katze", task.Prompt, "the task keeps its original prompt")
+		})
+	}
+}
+
+func TestHandleTTITask_TranslationFailure(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	config := MockConfig()
+	useMockAPI(config, server)
+
+	w := newTestWebSocketClient(t, config, WithPromptTranslator(&mockTranslator{err: errors.New("quota exceeded")}))
+	task := NewTasukete(TTI, "eine Katze", 1)
+	task.Language = "de"
+	w.handleTTITask(context.Background(), nil, task)
+
+	assert.Equal(t, StatusFailed, task.Status())
+	assert.Empty(t, mock.Generations())
+}
+
+func TestNewPromptTranslator(t *testing.T) {
+	translator, err := newPromptTranslator(TranslationConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, NoopTranslator{}, translator)
+
+	_, err = newPromptTranslator(TranslationConfig{Provider: "babelfish"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Connection pool defaults for the API transport
+const (
+	defaultMaxIdleConns        = 10
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// newTransport builds the client's own API transport from http.DefaultTransport's
+// settings, with the connection pool sized by the config
+func (c APIConfig) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = defaultMaxIdleConns
+	if c.MaxIdleConns > 0 {
+		transport.MaxIdleConns = c.MaxIdleConns
+	}
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = defaultIdleConnTimeout
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(c.IdleConnTimeout) * time.Second
+	}
+	return transport
+}
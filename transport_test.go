@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func clientTransport(t *testing.T, config *Config) *http.Transport {
+	t.Helper()
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	require.True(t, ok, "client has its own *http.Transport")
+	return transport
+}
+
+func TestNewClient_Transport(t *testing.T) {
+	transport := clientTransport(t, MockConfig())
+	assert.NotSame(t, http.DefaultTransport, transport)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.NotNil(t, transport.Proxy, "keeps the default transport's proxy settings")
+
+	config := MockConfig()
+	config.API.MaxIdleConns = 32
+	config.API.MaxIdleConnsPerHost = 8
+	config.API.IdleConnTimeout = 15
+	configured := clientTransport(t, config)
+	assert.Equal(t, 32, configured.MaxIdleConns)
+	assert.Equal(t, 8, configured.MaxIdleConnsPerHost)
+	assert.Equal(t, 15*time.Second, configured.IdleConnTimeout)
+
+	assert.NotSame(t, transport, configured, "clients don't share a transport")
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns, "configuring one client leaves the other alone")
+}
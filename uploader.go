@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ImageUploader stores image data and returns where it ended up
+type ImageUploader interface {
+	Upload(ctx context.Context, data []byte, name string) (string, error)
+}
+
+func newImageUploader(config *Config, logger *slog.Logger) ImageUploader {
+	switch config.Upload.Backend {
+	case "", "http":
+		return NewHTTPUploader(config.Server)
+	case "s3":
+		return NewS3Uploader(config.Upload.S3)
+	default:
+		logger.Warn("Unknown upload backend, using http", "backend", config.Upload.Backend)
+		return NewHTTPUploader(config.Server)
+	}
+}
+
+// HTTPUploader posts images to the generation server as multipart form data
+type HTTPUploader struct {
+	server     ServerConfig
+	httpClient *http.Client
+	userAgent  string
+}
+
+func NewHTTPUploader(server ServerConfig) *HTTPUploader {
+	transport := &http.Transport{
+		TLSClientConfig: server.tlsConfig(),
+	}
+	return &HTTPUploader{
+		server:     server,
+		httpClient: &http.Client{Transport: transport},
+		userAgent:  defaultUserAgent(),
+	}
+}
+
+// Upload uploads image data directly without saving to disk first
+func (u *HTTPUploader) Upload(ctx context.Context, data []byte, name string) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	if _, err = io.Copy(part, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("failed to copy image data: %w", err)
+	}
+
+	if err = writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	// batch archives go to their own endpoint
+	endpoint := "image"
+	if http.DetectContentType(data) == "application/zip" {
+		endpoint = "images"
+	}
+
+	url := fmt.Sprintf("https://%s:%s/%s", u.server.Host, u.server.Port, endpoint)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("User-Agent", u.userAgent)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", UploadError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", UploadError{StatusCode: resp.StatusCode, Body: truncate(string(bodyBytes), maxErrorBody)}
+	}
+
+	return url, nil
+}
+
+// S3Uploader stores images in an S3-compatible bucket
+type S3Uploader struct {
+	bucket string
+	client *s3.Client
+}
+
+func NewS3Uploader(cfg S3Config) *S3Uploader {
+	opts := s3.Options{
+		Region:      cfg.Region,
+		Credentials: credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		// MinIO and friends don't understand the newer default checksums
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+	}
+	if cfg.Endpoint != "" {
+		opts.BaseEndpoint = aws.String(cfg.Endpoint)
+		opts.UsePathStyle = true
+	}
+	return &S3Uploader{
+		bucket: cfg.Bucket,
+		client: s3.New(opts),
+	}
+}
+
+func (u *S3Uploader) Upload(ctx context.Context, data []byte, name string) (string, error) {
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(u.bucket),
+		Key:           aws.String(name),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String(http.DetectContentType(data)),
+	})
+	if err != nil {
+		uploadErr := UploadError{Err: fmt.Errorf("s3: %w", err)}
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) {
+			uploadErr.StatusCode = respErr.HTTPStatusCode()
+		}
+		return "", uploadErr
+	}
+	return fmt.Sprintf("s3://%s/%s", u.bucket, name), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestS3Uploader_PutObject runs the uploader against a fake path-style S3 endpoint
+func TestS3Uploader_PutObject(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	uploader := NewS3Uploader(S3Config{
+		Bucket:          "images",
+		Region:          "us-east-1",
+		Endpoint:        server.URL,
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	})
+
+	location, err := uploader.Upload(context.Background(), []byte("test image data"), "cat.png")
+	require.NoError(t, err)
+	assert.Equal(t, "s3://images/cat.png", location)
+	assert.Equal(t, http.MethodPut, gotMethod)
+	assert.Equal(t, "/images/cat.png", gotPath)
+	assert.Equal(t, "test image data", string(gotBody))
+}
+
+func TestNewImageUploader(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+
+	assert.IsType(t, &HTTPUploader{}, newImageUploader(config, logger))
+
+	config.Upload.Backend = "s3"
+	assert.IsType(t, &S3Uploader{}, newImageUploader(config, logger))
+
+	config.Upload.Backend = "ftp"
+	assert.IsType(t, &HTTPUploader{}, newImageUploader(config, logger))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodedImage(t *testing.T, width, height int, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestCheckUpscaleInput(t *testing.T) {
+	encodePNG := png.Encode
+	encodeJPEG := func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }
+
+	config := MockConfig()
+	config.API.MaxUpscaleInputWidth = 64
+	config.API.MaxUpscaleInputHeight = 32
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	assert.NoError(t, client.checkUpscaleInput(encodedImage(t, 64, 32, encodePNG)))
+	assert.NoError(t, client.checkUpscaleInput(encodedImage(t, 16, 16, encodeJPEG)))
+
+	err := client.checkUpscaleInput(encodedImage(t, 65, 32, encodePNG))
+	var tooLarge ErrImageTooLarge
+	require.True(t, errors.As(err, &tooLarge), "expected ErrImageTooLarge, got %v", err)
+	assert.Equal(t, ErrImageTooLarge{Width: 65, Height: 32}, tooLarge)
+
+	assert.ErrorAs(t, client.checkUpscaleInput(encodedImage(t, 10, 33, encodeJPEG)), &tooLarge)
+	assert.Error(t, client.checkUpscaleInput([]byte("not an image")))
+}
+
+func TestMaxUpscaleInput_Defaults(t *testing.T) {
+	width, height := APIConfig{}.maxUpscaleInput()
+	assert.Equal(t, 2048, width)
+	assert.Equal(t, 2048, height)
+}
+
+func TestUpscaleImage(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	upscaled, err := client.UpscaleImage(context.Background(), UpscaleRequest{Image: encodedImage(t, 64, 32, png.Encode), ModelID: 1})
+	require.NoError(t, err)
+	assert.NotEmpty(t, upscaled)
+
+	generations := mock.Generations()
+	require.Len(t, generations, 1)
+	assert.Equal(t, float64(128), generations[0]["width"])
+	assert.Equal(t, float64(64), generations[0]["height"])
+	assert.Contains(t, generations[0]["initimage"], "data:image/png;base64,")
+	assert.Equal(t, float64(0), generations[0]["initimagecreativity"])
+}
+
+func TestUpscaleImage_TooLarge(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.MaxUpscaleInputWidth = 32
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := client.UpscaleImage(context.Background(), UpscaleRequest{Image: encodedImage(t, 64, 32, png.Encode), ModelID: 1})
+	var tooLarge ErrImageTooLarge
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Zero(t, mock.CallCount("/API/GenerateText2Image"), "oversized images must not reach the API")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// packageVersion is set at build time with
+// -ldflags "-X main.packageVersion=v1.2.3"
+var packageVersion = "dev"
+
+// defaultUserAgent identifies this client in SwarmUI access logs
+func defaultUserAgent() string {
+	return fmt.Sprintf("genclient/%s Go/%s", packageVersion, runtime.Version())
+}
+
+// WithUserAgentSuffix appends suffix to the User-Agent sent with every request
+func WithUserAgentSuffix(suffix string) ClientOption {
+	return func(c *Client) {
+		if suffix != "" {
+			c.userAgent += " " + suffix
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgent_SentWithEveryRequest(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err := client.Generate(context.Background(), GenerateRequest{Prompt: "test prompt", ModelID: 1})
+	require.NoError(t, err)
+	_, err = client.FetchAvailableModels(context.Background())
+	require.NoError(t, err)
+
+	pattern := regexp.MustCompile(`^genclient/dev Go/go\S+$`)
+	agents := mock.UserAgents()
+	require.Len(t, agents, 4) // session, generation, download, list models
+	for i, agent := range agents {
+		if !pattern.MatchString(agent) {
+			t.Errorf("Request %d: unexpected User-Agent %q", i+1, agent)
+		}
+	}
+}
+
+func TestWithUserAgentSuffix(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+	uploads := NewMockSwarmUIServer()
+	uploadServer := uploads.StartTLS()
+	defer uploadServer.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	useMockUploads(config, uploadServer)
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)), WithUserAgentSuffix("bot/1.0"))
+
+	_, err := client.FetchAvailableModels(context.Background())
+	require.NoError(t, err)
+	_, err = client.uploader.Upload(context.Background(), mockImage, "test.png")
+	require.NoError(t, err)
+
+	want := defaultUserAgent() + " bot/1.0"
+	assert.Equal(t, []string{want}, mock.UserAgents())
+	assert.Equal(t, []string{want}, uploads.UserAgents())
+}
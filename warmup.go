@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/robfig/cron/v3"
+)
+
+// WarmupJob generates an image ahead of time on a cron schedule
+type WarmupJob struct {
+	Cron       string `yaml:"cron"` // standard five fields, an optional leading seconds field, or a descriptor like @daily
+	Prompt     string `yaml:"prompt"`
+	ModelID    int    `yaml:"model_id"`
+	OutputPath string `yaml:"output_path"` // directory the images are written to
+}
+
+// warmupParser accepts cron specs with or without a seconds field
+var warmupParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// WarmupScheduler runs the configured warmup jobs
+type WarmupScheduler struct {
+	client *Client
+	cron   *cron.Cron
+	logger *slog.Logger
+}
+
+// NewWarmupScheduler schedules jobs without starting them, failing on
+// specs that don't parse or models that don't exist
+func NewWarmupScheduler(client *Client, jobs []WarmupJob, logger *slog.Logger) (*WarmupScheduler, error) {
+	s := &WarmupScheduler{
+		client: client,
+		cron:   cron.New(cron.WithParser(warmupParser)),
+		logger: logger,
+	}
+	for i, job := range jobs {
+		if job.ModelID <= 0 || job.ModelID > len(client.config.Models) {
+			return nil, fmt.Errorf("warmup job %d: invalid model_id: %d", i, job.ModelID)
+		}
+		if job.OutputPath == "" {
+			return nil, fmt.Errorf("warmup job %d: output_path is required", i)
+		}
+		if _, err := s.cron.AddFunc(job.Cron, func() { s.run(job) }); err != nil {
+			return nil, fmt.Errorf("warmup job %d: invalid cron %q: %w", i, job.Cron, err)
+		}
+	}
+	return s, nil
+}
+
+// Start runs the schedule in the background
+func (s *WarmupScheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops the schedule and waits for running jobs to finish
+func (s *WarmupScheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+func (s *WarmupScheduler) run(job WarmupJob) {
+	path, err := s.generate(job)
+	if err != nil {
+		s.logger.Error("Warmup generation failed", "cron", job.Cron, "model", job.ModelID, "error", err)
+		return
+	}
+	s.logger.Info("Warmup image generated", "cron", job.Cron, "path", path)
+}
+
+// generate makes the job's image and writes it under OutputPath, named by
+// server.filename_format like task results
+func (s *WarmupScheduler) generate(job WarmupJob) (string, error) {
+	image, err := s.client.GenerateImage(job.Prompt, job.ModelID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(job.OutputPath, 0o755); err != nil {
+		return "", err
+	}
+	task := NewTasukete(TTI, job.Prompt, job.ModelID)
+	path := filepath.Join(job.OutputPath, taskFilename(task, s.client.config.Server.FilenameFormat))
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmupScheduler_WritesImages(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(config, logger)
+
+	dir := filepath.Join(t.TempDir(), "digest")
+	scheduler, err := NewWarmupScheduler(client, []WarmupJob{
+		{Cron: "* * * * * *", Prompt: "morning skyline", ModelID: 1, OutputPath: dir},
+	}, logger)
+	require.NoError(t, err)
+
+	scheduler.Start()
+	var files []os.DirEntry
+	require.Eventually(t, func() bool {
+		files, _ = os.ReadDir(dir)
+		return len(files) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	scheduler.Stop()
+
+	assert.Regexp(t, `^[0-9a-f-]{36}\.png$`, files[0].Name())
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(mockImage, data), "warmup image matches the generation")
+	assert.Equal(t, "morning skyline", mock.Generations()[0]["prompt"])
+}
+
+func TestNewWarmupScheduler_InvalidJobs(t *testing.T) {
+	client := NewClient(MockConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	tests := []struct {
+		name string
+		job  WarmupJob
+		want string
+	}{
+		{"bad cron", WarmupJob{Cron: "every day", ModelID: 1, OutputPath: "out"}, "invalid cron"},
+		{"unknown model", WarmupJob{Cron: "@daily", ModelID: 9, OutputPath: "out"}, "invalid model_id"},
+		{"no output path", WarmupJob{Cron: "0 6 * * *", ModelID: 1}, "output_path is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWarmupScheduler(client, []WarmupJob{tt.job}, client.logger)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
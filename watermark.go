@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	defaultWatermarkFontSize = 24
+	defaultWatermarkOpacity  = 0.5
+	watermarkMargin          = 8
+	watermarkJPEGQuality     = 95
+)
+
+// ErrWatermarkUnsupported means the image is in a format ApplyWatermark
+// can't decode or re-encode, such as WebP
+var ErrWatermarkUnsupported = errors.New("image format can't be watermarked")
+
+type WatermarkConfig struct {
+	Text     string  `yaml:"text"`
+	FontSize int     `yaml:"font_size"`
+	Opacity  float64 `yaml:"opacity"`
+	Position string  `yaml:"position"` // top-left, top-right, bottom-left, bottom-right (default), center
+}
+
+// ApplyWatermark renders cfg.Text on top of the image and returns it
+// re-encoded in its original format. Only PNG and JPEG are supported.
+func ApplyWatermark(imageData []byte, cfg WatermarkConfig) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(imageData))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, fmt.Errorf("%w: %s", ErrWatermarkUnsupported, http.DetectContentType(imageData))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if format != "png" && format != "jpeg" {
+		return nil, fmt.Errorf("%w: %s", ErrWatermarkUnsupported, format)
+	}
+
+	size := cfg.FontSize
+	if size <= 0 {
+		size = defaultWatermarkFontSize
+	}
+	opacity := cfg.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = defaultWatermarkOpacity
+	}
+
+	ttf, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	face, err := opentype.NewFace(ttf, &opentype.FaceOptions{Size: float64(size), DPI: 72})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	defer face.Close()
+
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: uint8(opacity * 255)}),
+		Face: face,
+	}
+	drawer.Dot = watermarkOrigin(dst.Bounds(), drawer.MeasureString(cfg.Text), face.Metrics(), cfg.Position)
+	drawer.DrawString(cfg.Text)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: watermarkJPEGQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// watermarkOrigin returns the baseline origin for text of the given width
+func watermarkOrigin(bounds image.Rectangle, width fixed.Int26_6, metrics font.Metrics, position string) fixed.Point26_6 {
+	margin := fixed.I(watermarkMargin)
+	left := fixed.I(bounds.Min.X) + margin
+	right := fixed.I(bounds.Max.X) - margin - width
+	top := fixed.I(bounds.Min.Y) + margin + metrics.Ascent
+	bottom := fixed.I(bounds.Max.Y) - margin - metrics.Descent
+
+	switch position {
+	case "top-left":
+		return fixed.Point26_6{X: left, Y: top}
+	case "top-right":
+		return fixed.Point26_6{X: right, Y: top}
+	case "bottom-left":
+		return fixed.Point26_6{X: left, Y: bottom}
+	case "center":
+		return fixed.Point26_6{
+			X: (fixed.I(bounds.Min.X+bounds.Max.X) - width) / 2,
+			Y: (fixed.I(bounds.Min.Y+bounds.Max.Y) + metrics.Ascent - metrics.Descent) / 2,
+		}
+	default:
+		return fixed.Point26_6{X: right, Y: bottom}
+	}
+}
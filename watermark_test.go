@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func solidPNG(t *testing.T, width, height int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestApplyWatermark(t *testing.T) {
+	input := solidPNG(t, 200, 100, color.Black)
+
+	for _, position := range []string{"top-left", "top-right", "bottom-left", "bottom-right", "center", ""} {
+		t.Run(position, func(t *testing.T) {
+			out, err := ApplyWatermark(input, WatermarkConfig{Text: "genclient", FontSize: 16, Opacity: 0.8, Position: position})
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 200, 100), img.Bounds())
+
+			changed := 0
+			for x := 0; x < 200; x++ {
+				for y := 0; y < 100; y++ {
+					if r, _, _, _ := img.At(x, y).RGBA(); r != 0 {
+						changed++
+					}
+				}
+			}
+			assert.Greater(t, changed, 0, "watermark left the image untouched")
+		})
+	}
+}
+
+func TestApplyWatermark_InvalidImage(t *testing.T) {
+	_, err := ApplyWatermark([]byte("nope"), WatermarkConfig{Text: "x"})
+	assert.Error(t, err)
+}
+
+func TestApplyWatermark_KeepsJPEG(t *testing.T) {
+	var input bytes.Buffer
+	require.NoError(t, jpeg.Encode(&input, image.NewGray(image.Rect(0, 0, 64, 64)), nil))
+
+	out, err := ApplyWatermark(input.Bytes(), WatermarkConfig{Text: "genclient"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", http.DetectContentType(out))
+}
+
+func TestApplyWatermark_UnsupportedFormat(t *testing.T) {
+	webp := []byte("RIFF\x1a\x00\x00\x00WEBPVP8 \x0e\x00\x00\x00")
+	_, err := ApplyWatermark(webp, WatermarkConfig{Text: "x"})
+	assert.ErrorIs(t, err, ErrWatermarkUnsupported)
+}
+
+func TestGenerate_WatermarkBeforeFormatCheck(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	img, err := png.Decode(bytes.NewReader(mockImage))
+	require.NoError(t, err)
+	var jpegImage bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegImage, img, nil))
+	mock.SetNextImageResponse(jpegImage.Bytes())
+
+	config := MockConfig()
+	useMockAPI(config, server)
+	config.API.Watermark = WatermarkConfig{Text: "genclient"}
+	client := NewClient(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	result, err := client.Generate(context.Background(), GenerateRequest{Prompt: "a cat", ModelID: 1, OutputMimeType: "image/jpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", http.DetectContentType(result.Image))
+	assert.NotEqual(t, jpegImage.Bytes(), result.Image, "watermark not applied")
+}
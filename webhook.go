@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookTimeout    = 10 * time.Second
+	webhookRetryDelay = 5 * time.Second
+)
+
+type WebhookPayload struct {
+	UUID     uuid.UUID  `json:"uuid"`
+	Status   TaskStatus `json:"status"`
+	ImageB64 string     `json:"image_b64,omitempty"`
+}
+
+// PostWebhook delivers a JSON payload to url
+func (c *Client) PostWebhook(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned non-OK status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyWebhook posts the task result to task.NotifyURL, retrying once
+func (w *WebSocketClient) notifyWebhook(ctx context.Context, task *Tasukete, result []byte) {
+	logger := loggerFromContext(ctx, w.logger)
+	payload := WebhookPayload{
+		UUID:     task.UUID,
+		Status:   task.Status(),
+		ImageB64: base64.StdEncoding.EncodeToString(result),
+	}
+
+	err := w.client.PostWebhook(ctx, task.NotifyURL, payload)
+	if err != nil {
+		logger.Warn("Webhook delivery failed, retrying", "uuid", task.UUID, "error", err)
+		time.Sleep(w.webhookRetryDelay)
+		err = w.client.PostWebhook(ctx, task.NotifyURL, payload)
+	}
+	if err != nil {
+		logger.Error("Webhook delivery failed", "uuid", task.UUID, "url", task.NotifyURL, "error", err)
+	}
+}
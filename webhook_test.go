@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyWebhook_RetriesOnce(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan WebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+	w := NewWebSocketClient(config, NewClient(config, logger), logger)
+	w.webhookRetryDelay = 10 * time.Millisecond
+
+	task := NewTasukete(TTI, "a cat", 1)
+	task.NotifyURL = server.URL
+	task.status = StatusCompleted
+	w.notifyWebhook(context.Background(), task, []byte("image bytes"))
+
+	require.Len(t, received, 1)
+	payload := <-received
+	assert.Equal(t, task.UUID, payload.UUID)
+	assert.Equal(t, StatusCompleted, payload.Status)
+	image, err := base64.StdEncoding.DecodeString(payload.ImageB64)
+	require.NoError(t, err)
+	assert.Equal(t, "image bytes", string(image))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestNotifyWebhook_GivesUpAfterRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	config := MockConfig()
+	w := NewWebSocketClient(config, NewClient(config, logger), logger)
+	w.webhookRetryDelay = time.Millisecond
+
+	task := NewTasukete(TTI, "a cat", 1)
+	task.NotifyURL = server.URL
+	w.notifyWebhook(context.Background(), task, nil)
+
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"mime/multipart"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -23,6 +27,55 @@ type WebSocketClient struct {
 	logger *slog.Logger
 	token  string
 	models []Model
+
+	selector ModelSelector
+	prompts  *PromptLibrary
+	schemas  SchemaRegistry
+	taskLog  *TaskLogger
+	pending  PersistentQueue
+	events   *TaskEvents
+	handlers taskHandlers
+	filter   TaskFilter
+
+	translator PromptTranslator
+
+	capabilities []string
+	clientInfo   map[string]string // extra client_info fields
+
+	webhookRetryDelay time.Duration
+	reconnectDelay    time.Duration
+	reconnect         reconnectState
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	lastPong     atomic.Int64 // unix nanoseconds
+
+	progressInterval time.Duration
+
+	out                 atomic.Pointer[outbox] // the current connection's, swapped on reconnect
+	droppedMessages     atomic.Int64
+	unknownMessageTypes atomic.Int64
+
+	queue     chan queuedTask // created once, so tasks survive a reconnect
+	backlog   taskBacklog     // tasks waiting for room in queue
+	depth     atomic.Int64    // tasks in queue and backlog
+	inFlight  atomic.Int32
+	abStats   abStats
+	dashboard dashboardState
+
+	workerDone chan struct{} // closed when the last connection's task worker returns
+
+	urgent     chan queuedTask // tasks that preempted the running one, run next
+	preemption preemptionState
+	requesters requesterCounts
+	registry   *TaskRegistry // tasks being handled
+
+	limiter  *ConnectionLimiter             // nil when connections aren't limited
+	conn     atomic.Pointer[websocket.Conn] // current connection, for GracefulStop
+	stopping atomic.Bool
+	stopped  chan struct{} // closed once GracefulStop is done
+
+	subprotocol atomic.Value // string, negotiated on the current connection
 }
 
 type WebSocketMessage struct {
@@ -30,29 +83,115 @@ type WebSocketMessage struct {
 	Payload json.RawMessage `json:"payload"`
 }
 
-func NewWebSocketClient(config *Config, client *Client, logger *slog.Logger) *WebSocketClient {
-	return &WebSocketClient{
-		config: config,
-		client: client,
-		logger: logger,
+// WebSocketOption customizes a WebSocketClient created by NewWebSocketClient
+type WebSocketOption func(*WebSocketClient)
+
+// WithPromptLibrary lets tasks refer to prompt presets by alias
+func WithPromptLibrary(library *PromptLibrary) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.prompts = library
+	}
+}
+
+// WithSchemaRegistry replaces the default task metadata schemas
+func WithSchemaRegistry(schemas SchemaRegistry) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.schemas = schemas
+	}
+}
+
+// WithTaskLogger records every processed task to a JSONL log
+func WithTaskLogger(taskLog *TaskLogger) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.taskLog = taskLog
+	}
+}
+
+// WithPersistentQueue hands queued tasks to q when the client stops
+func WithPersistentQueue(q PersistentQueue) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.pending = q
 	}
 }
 
+// WithTaskEvents publishes task status changes to events
+func WithTaskEvents(events *TaskEvents) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.events = events
+	}
+}
+
+// WithWebSocketLogger replaces the logger passed to NewWebSocketClient
+func WithWebSocketLogger(logger *slog.Logger) WebSocketOption {
+	return func(w *WebSocketClient) {
+		w.logger = logger
+	}
+}
+
+func NewWebSocketClient(config *Config, client *Client, logger *slog.Logger, opts ...WebSocketOption) *WebSocketClient {
+	selector, ok := newModelSelector(config.ModelSelectionStrategy)
+	w := &WebSocketClient{
+		config:   config,
+		client:   client,
+		logger:   logger,
+		selector: selector,
+		schemas:  defaultSchemaRegistry,
+
+		translator: NoopTranslator{},
+
+		capabilities: config.Server.Capabilities,
+
+		webhookRetryDelay: webhookRetryDelay,
+		reconnectDelay:    reconnectDelay,
+
+		pingInterval: pingInterval,
+		pongTimeout:  config.Server.pongTimeout(),
+
+		progressInterval: config.API.progressInterval(),
+
+		queue:    newTaskQueue(config.Server.QueueDepth),
+		urgent:   make(chan queuedTask, 1),
+		registry: NewTaskRegistry(),
+		stopped:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if !ok {
+		w.logger.Warn("Unknown model selection strategy, using first model", "strategy", config.ModelSelectionStrategy)
+	}
+	if !validFilenameFormat(config.Server.FilenameFormat) {
+		w.logger.Warn("Unknown filename format, using uuid", "format", config.Server.FilenameFormat)
+	}
+	if !validMinTLSVersion(config.Server.MinTLSVersion) {
+		w.logger.Warn("Unknown minimum TLS version, using 1.2", "version", config.Server.MinTLSVersion)
+	}
+	if !validSubprotocols(config.Server.SubProtocols) {
+		w.logger.Warn("Unknown subprotocols are not offered", "subprotocols", config.Server.SubProtocols)
+	}
+	return w
+}
+
+// Start keeps the client connected until GracefulStop is called
 func (w *WebSocketClient) Start() {
-	for {
-		if err := w.connect(); err != nil {
-			w.logger.Error("WebSocket connection failed", "error", err)
-			time.Sleep(5 * time.Second)
-			continue
+	for !w.stopping.Load() {
+		delay := w.connectOnce()
+		select {
+		case <-w.stopped:
+			return
+		case <-time.After(delay):
 		}
 	}
 }
 
 func (w *WebSocketClient) connect() error {
-	dialer := websocket.Dialer{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	dialer := w.config.Server.dialer()
+
+	if w.limiter != nil {
+		if err := w.limiter.acquire(w.stopped); err != nil {
+			return err
+		}
+		defer w.limiter.release()
 	}
 
 	url := fmt.Sprintf("wss://%s:%s/ws", w.config.Server.Host, w.config.Server.Port)
@@ -61,6 +200,25 @@ func (w *WebSocketClient) connect() error {
 		return fmt.Errorf("dial error: %w", err)
 	}
 	defer conn.Close()
+	w.subprotocol.Store(conn.Subprotocol())
+	w.conn.Store(conn)
+	defer w.conn.CompareAndSwap(conn, nil)
+	w.trackPongs(conn)
+
+	out := newOutbox(w.config.Server.SendBufferSize, &w.droppedMessages, w.logger)
+	out.limiter = newOutboundLimiter(w.config.Server)
+	defer out.close()
+	w.out.Store(out)
+	w.goRecover("outbox writer", func() {
+		err := out.run(func(messageType int, data []byte) error {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			return conn.WriteMessage(messageType, data)
+		})
+		if err != nil {
+			w.logger.Error("WebSocket write failed", "error", err)
+			conn.Close()
+		}
+	})
 
 	if err := w.authenticate(conn); err != nil {
 		return fmt.Errorf("authentication error: %w", err)
@@ -70,7 +228,16 @@ func (w *WebSocketClient) connect() error {
 		return fmt.Errorf("models send error: %w", err)
 	}
 
-	go w.startPingLoop(conn)
+	if err := w.sendClientInfo(conn, cap(w.queue)); err != nil {
+		return fmt.Errorf("client info send error: %w", err)
+	}
+	w.markConnected()
+	w.reloadPending(conn)
+	w.startTaskWorker(out.done)
+	w.goRecover("queue reporter", func() { w.startQueueReporter(out.done) })
+
+	w.goRecover("ping loop", func() { w.startPingLoop(out) })
+	w.goRecover("liveness check", func() { w.startLivenessCheck(conn, out.done) })
 	return w.handleMessages(conn)
 }
 
@@ -80,24 +247,29 @@ func (w *WebSocketClient) authenticate(conn *websocket.Conn) error {
 		Payload: json.RawMessage(fmt.Sprintf(`{"password":"%s"}`, w.config.Server.Passcode)),
 	}
 
-	if err := w.writeJSON(conn, authReq); err != nil {
+	if err := w.writeJSON(context.Background(), conn, authReq); err != nil {
 		return err
 	}
 
 	var response WebSocketMessage
-	if err := conn.ReadJSON(&response); err != nil {
+	if err := readMessage(conn, &response); err != nil {
 		return err
 	}
+	w.logIncoming(context.Background(), response)
 
 	if response.Type != "auth_success" {
-		return fmt.Errorf("auth failed")
+		return &WebSocketProtocolError{
+			Code:        ProtocolErrMissingAuthSuccess,
+			MessageType: response.Type,
+			Details:     "auth failed",
+		}
 	}
 
 	var authResponse struct {
 		Token string `josn:"token"`
 	}
 	if err := json.Unmarshal(response.Payload, &authResponse); err != nil {
-		return err
+		return malformedPayload(response.Type, err)
 	}
 	w.token = authResponse.Token
 
@@ -105,17 +277,36 @@ func (w *WebSocketClient) authenticate(conn *websocket.Conn) error {
 
 }
 
-func (w *WebSocketClient) writeJSON(conn *websocket.Conn, v interface{}) error {
-	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	defer conn.SetWriteDeadline(time.Time{})
-	return conn.WriteJSON(v)
+// DroppedMessages returns the number of outbound messages dropped because the send queue was full
+func (w *WebSocketClient) DroppedMessages() int64 {
+	return w.droppedMessages.Load()
+}
+
+func (w *WebSocketClient) writeJSON(ctx context.Context, conn *websocket.Conn, msg WebSocketMessage) error {
+	w.logOutgoing(ctx, msg)
+	messageType, data, err := encodeMessage(msg, w.Subprotocol())
+	if err != nil {
+		return err
+	}
+	return w.out.Load().enqueueContext(ctx, messageType, data)
+}
+
+// writeControlJSON is writeJSON for time-sensitive replies such as
+// heartbeats, which are sent ahead of queued data messages
+func (w *WebSocketClient) writeControlJSON(ctx context.Context, msg WebSocketMessage) error {
+	w.logOutgoing(ctx, msg)
+	messageType, data, err := encodeMessage(msg, w.Subprotocol())
+	if err != nil {
+		return err
+	}
+	return w.out.Load().enqueueControl(messageType, data)
 }
 
 func (w *WebSocketClient) requestModels(conn *websocket.Conn) error {
 	req := WebSocketMessage{
 		Type: "get_models",
 	}
-	return w.writeJSON(conn, req)
+	return w.writeJSON(context.Background(), conn, req)
 }
 
 func (w *WebSocketClient) sendModels(conn *websocket.Conn) error {
@@ -127,82 +318,195 @@ func (w *WebSocketClient) sendModels(conn *websocket.Conn) error {
 		})
 	}
 
-	var msg struct {
-		Type    string          `json:"type"`
-		Payload json.RawMessage `json:"payload"`
-	}
 	modelsJSON, err := json.Marshal(models)
 	if err != nil {
 		return err
 	}
-	msg.Type = "models_update"
-	msg.Payload = modelsJSON
-
-	modelsMSG, err := json.Marshal(msg)
-	if err != nil {
-		return err
+	msg := WebSocketMessage{
+		Type:    "models_update",
+		Payload: modelsJSON,
 	}
-
-	return conn.WriteMessage(websocket.TextMessage, modelsMSG)
+	return w.writeJSON(context.Background(), conn, msg)
 }
 
-func (w *WebSocketClient) startPingLoop(conn *websocket.Conn) {
-	ticker := time.NewTicker(10 * time.Second)
+func (w *WebSocketClient) startPingLoop(out *outbox) {
+	ticker := time.NewTicker(w.pingInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+	for {
+		select {
+		case <-out.done:
 			return
+		case <-ticker.C:
+			out.enqueue(websocket.PingMessage, nil)
 		}
 	}
 }
 
+// handleMessages processes messages until the connection drops or the
+// server violates the protocol, which returns a *WebSocketProtocolError
 func (w *WebSocketClient) handleMessages(conn *websocket.Conn) error {
 	for {
 		var message WebSocketMessage
-		err := conn.ReadJSON(&message)
-		if err != nil {
+		if err := readMessage(conn, &message); err != nil {
 			if websocket.IsUnexpectedCloseError(err) {
 				return fmt.Errorf("connection closed: %w", err)
 			}
 			return err
 		}
 
-		switch message.Type {
-		case "task":
-			var task Tasukete
-			if err := json.Unmarshal(message.Payload, &task); err != nil {
-				w.logger.Error("Failed to unmarshal task", "error", err)
-				continue
-			}
-			w.handleTask(conn, &task)
+		if err := w.handleMessage(conn, message); err != nil {
+			return err
+		}
+	}
+}
 
-		case "models_update":
-			var models []Model
-			if err := json.Unmarshal(message.Payload, &models); err != nil {
-				w.logger.Error("Failed to unmarshal models", "error", err)
-				continue
-			}
-			w.models = models
-			w.logger.Info("Models updated", "count", len(models))
+// handleMessage dispatches a single message within its own request scope
+func (w *WebSocketClient) handleMessage(conn *websocket.Conn, message WebSocketMessage) error {
+	ctx := newMessageContext(context.Background(), messageRequestID(message), w.logger)
+	logger := loggerFromContext(ctx, w.logger)
+	w.logIncoming(ctx, message)
+
+	switch message.Type {
+	case "task":
+		var task Tasukete
+		if err := json.Unmarshal(message.Payload, &task); err != nil {
+			return malformedPayload(message.Type, err)
+		}
+		if w.acceptTask(ctx, conn, &task) {
+			w.enqueueTask(ctx, conn, &task)
+		}
+
+	case "models_update":
+		var models []Model
+		if err := json.Unmarshal(message.Payload, &models); err != nil {
+			return malformedPayload(message.Type, err)
+		}
+		w.models = models
+		logger.Info("Models updated", "count", len(models))
+
+	case "server_info":
+		return w.handleServerInfo(ctx, message)
+
+	case "cancel_task":
+		return w.handleCancelTask(ctx, message)
 
-		default:
-			w.logger.Warn("Unknown message type", "type", message.Type)
+	case "auth", "auth_success":
+		return &WebSocketProtocolError{
+			Code:        ProtocolErrUnexpectedSequence,
+			MessageType: message.Type,
+			Details:     "already authenticated",
 		}
+
+	default:
+		w.unknownMessageTypes.Add(1)
+		logger.Warn("Unknown message type", "type", message.Type)
 	}
+	return nil
 }
 
-func (w *WebSocketClient) handleTask(conn *websocket.Conn, task *Tasukete) {
+// UnknownMessageTypes returns the number of messages ignored because their
+// type wasn't recognized
+func (w *WebSocketClient) UnknownMessageTypes() int64 {
+	return w.unknownMessageTypes.Load()
+}
+
+// readMessage reads the next message, reporting undecodable frames as a
+// protocol error
+func readMessage(conn *websocket.Conn, message *WebSocketMessage) error {
+	_, r, err := conn.NextReader()
+	if err == nil {
+		err = decodeMessage(r, conn.Subprotocol(), message)
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return malformedPayload("", err)
+	}
+	return err
+}
+
+func malformedPayload(messageType string, err error) *WebSocketProtocolError {
+	return &WebSocketProtocolError{
+		Code:        ProtocolErrMalformedPayload,
+		MessageType: messageType,
+		Details:     err.Error(),
+	}
+}
+
+func (w *WebSocketClient) handleTask(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	logger := loggerFromContext(ctx, w.logger)
+	start := time.Now()
+
 	// Validate task
-	if err := task.Validate(); err != nil {
-		w.logger.Error("Invalid task received", "error", err)
+	if err := task.ValidateWith(w.schemas); err != nil {
+		logger.Error("Invalid task received", "error", err)
+		w.recordTask(ctx, task, start, 0, err)
+		return
+	}
+
+	// Heartbeats check the pipeline, they don't need a model
+	if task.Type == Heartbeat {
+		w.handleHeartbeatTask(ctx, conn, task)
+		return
+	}
+
+	// Refuse tasks needing worker features we don't have
+	if missing := w.missingCapabilities(task.RequiredCapabilities); len(missing) > 0 {
+		logger.Warn("Task requires missing capabilities", "uuid", task.UUID, "missing", missing)
+		task.AddMetadata("reason", capabilityMismatch)
+		task.AddMetadata("missing_capabilities", missing)
+		w.rejectTask(ctx, conn, task, start, fmt.Errorf("%s: %v", capabilityMismatch, missing))
+		return
+	}
+
+	// Expand prompt presets
+	if task.PromptAlias != "" {
+		if err := w.resolvePromptAlias(task); err != nil {
+			logger.Error("Failed to resolve prompt alias", "alias", task.PromptAlias, "error", err)
+			w.rejectTask(ctx, conn, task, start, err)
+			return
+		}
+	}
+
+	// Pick a model if the task left it to us, or swap one that can't run it
+	requested := task.Model
+	w.assignABGroup(ctx, task)
+	if err := w.client.resolveModel(w.selector, task, w.config.AutoSelectModel); err != nil {
+		logger.Error("No model can run task", "uuid", task.UUID, "model", task.Model, "error", err)
+		w.rejectTask(ctx, conn, task, start, err)
+		return
+	}
+	if task.Model != requested {
+		logger.Debug("Model auto-selected", "model", task.Model, "requested", requested)
+	}
+
+	// Resent tasks can't go past their model's retry budget
+	if task.Model > 0 && task.Model <= len(w.config.Models) {
+		retry := perModelRetryConfig(w.config.Models[task.Model-1], w.config.API.Retry)
+		if err := task.ValidateRetryCount(retry.MaxRetries); err != nil {
+			logger.Error("Invalid task received", "uuid", task.UUID, "error", err)
+			w.rejectTask(ctx, conn, task, start, err)
+			return
+		}
+	}
+
+	// Track the task while it runs, so cancel_task can stop it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w.registry.Register(task, cancel)
+	defer w.registry.Unregister(task.UUID)
+
+	// Registered handlers take precedence over the built-in types
+	if handler, ok := w.handlers.lookup(task.Type); ok {
+		w.runTaskHandler(ctx, conn, handler, task)
 		return
 	}
 
 	// Process task based on type
 	switch task.Type {
 	case TTI:
-		w.handleTTITask(conn, task)
+		w.handleTTITask(ctx, conn, task)
 		// case LLM:
 		// 	w.handleLLMTask(conn, task)
 		// case Recon:
@@ -210,36 +514,188 @@ func (w *WebSocketClient) handleTask(conn *websocket.Conn, task *Tasukete) {
 	}
 }
 
-func (w *WebSocketClient) handleTTITask(conn *websocket.Conn, task *Tasukete) {
-	// Update task status
-	task.Status = StatusProcessing
-	w.sendTaskUpdate(conn, task)
+// resolvePromptAlias replaces the task prompts with the library preset,
+// keeping any task prompt text as an addition to the preset
+func (w *WebSocketClient) resolvePromptAlias(task *Tasukete) error {
+	if w.prompts == nil {
+		return fmt.Errorf("no prompt library configured")
+	}
+	positive, negative, err := w.prompts.ResolvePrompt(task.PromptAlias)
+	if err != nil {
+		return err
+	}
+	task.Prompt = joinPrompts(positive, task.Prompt)
+	task.NegativePrompt = joinPrompts(negative, task.NegativePrompt)
+	return nil
+}
+
+func joinPrompts(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
 
-	// Generate image
-	result, err := w.client.GenerateImage(task.Prompt, task.Model)
+func (w *WebSocketClient) handleTTITask(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	ctx = withRequester(ctx, task, w.logger)
+	logger := loggerFromContext(ctx, w.logger)
+
+	start := time.Now()
+	var result *GenerateResult
+	var err error
+	defer func() {
+		size := 0
+		if result != nil {
+			size = len(result.Image)
+		}
+		w.recordTask(ctx, task, start, size, err)
+		w.recordABResult(task, time.Since(start), err)
+		w.requesters.add(task.RequestedBy)
+	}()
+
+	w.estimateTaskCost(task)
+
+	// Report each generation retry so the server can show the attempt
+	ctx = withRetryHook(ctx, func(err error) {
+		task.RetryCount++
+		logger.Warn("Retrying task", "uuid", task.UUID, "retry_count", task.RetryCount, "error", err)
+		w.sendTaskUpdate(ctx, conn, task)
+	})
+
+	// Update task status
+	if err = task.UpdateStatus(StatusProcessing); err != nil {
+		logger.Error("Failed to start task", "uuid", task.UUID, "error", err)
+		w.sendTaskError(ctx, conn, task, err)
+		return
+	}
+	w.sendTaskUpdate(ctx, conn, task)
+
+	// Generate image, reporting progress while it runs
+	var prompt, negativePrompt string
+	prompt, negativePrompt, err = w.translatePrompts(ctx, task)
+	var controlNetImage []byte
+	if err == nil {
+		controlNetImage, err = task.controlNetImage()
+	}
+	if err == nil {
+		genCtx, stopProgress := w.trackProgress(ctx, conn, task)
+		result, err = w.client.Generate(genCtx, GenerateRequest{
+			Prompt:          prompt,
+			NegativePrompt:  negativePrompt,
+			ModelID:         task.Model,
+			LoraPreset:      task.LoraPreset,
+			Seed:            taskSeed(task),
+			ControlNet:      task.ControlNet,
+			ControlNetImage: controlNetImage,
+			OutputMimeType:  task.OutputMimeType,
+		})
+		stopProgress()
+	}
 	if err != nil {
-		task.Status = StatusFailed
-		w.sendTaskUpdate(conn, task)
+		attrs := []any{"uuid", task.UUID, "retry_count", task.RetryCount, "error", err}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorID != "" {
+			attrs = append(attrs, "error_id", apiErr.ErrorID)
+		}
+		logger.Error("Image generation failed", attrs...)
+		w.failTask(ctx, conn, task)
+		return
+	}
+
+	task.AddMetadata("resolved_model", result.ModelName)
+	if w.config.API.RequestJitterMs > 0 {
+		task.AddMetadata("jitter_ms", result.Jitter.Milliseconds())
+	}
+	task.ImageChecksum = hashImage(result.Image)
+
+	// The result carries the task as completed, but the task itself only
+	// completes once the result is on its way
+	completed := task.snapshot()
+	if err = completed.UpdateStatus(StatusCompleted); err != nil {
+		logger.Error("Failed to complete task", "uuid", task.UUID, "error", err)
+		w.sendTaskError(ctx, conn, task, err)
+		return
+	}
+	if err = w.sendTaskResult(conn, completed, result.Image); err != nil {
+		logger.Error("Failed to send task result", "uuid", task.UUID, "error", err)
+		task.AddMetadata("error", "failed to send result")
+		w.failTask(ctx, conn, task)
+		return
+	}
+	if err = task.UpdateStatus(StatusCompleted); err != nil {
+		logger.Error("Failed to complete task", "uuid", task.UUID, "error", err)
+		return
+	}
+	w.publishStatus(task)
+
+	if task.NotifyURL != "" {
+		notified := task.snapshot()
+		w.goRecoverTask("webhook", func() { w.notifyWebhook(context.WithoutCancel(ctx), notified, result.Image) })
+	}
+}
+
+// rejectTask fails a task turned away before it reached a handler and
+// records it in the task log, which the handlers write for tasks they run
+func (w *WebSocketClient) rejectTask(ctx context.Context, conn *websocket.Conn, task *Tasukete, start time.Time, err error) {
+	w.failTask(ctx, conn, task)
+	w.recordTask(ctx, task, start, 0, err)
+}
+
+// failTask marks the task failed and reports it to the server. Tasks that
+// stopped because they were preempted are requeued instead.
+func (w *WebSocketClient) failTask(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	if w.requeuePreempted(task) {
 		return
 	}
+	if err := task.UpdateStatus(StatusFailed); err != nil {
+		loggerFromContext(ctx, w.logger).Error("Failed to fail task", "uuid", task.UUID, "error", err)
+		w.sendTaskError(ctx, conn, task, err)
+		return
+	}
+	w.sendTaskUpdate(ctx, conn, task)
+}
+
+// taskErrorPayload is the payload of a task_error message
+type taskErrorPayload struct {
+	UUID   uuid.UUID  `json:"uuid"`
+	Status TaskStatus `json:"status"` // the status the task is left in
+	Error  string     `json:"error"`
+}
 
-	// Send result
-	if err := w.sendTaskResult(conn, task, result); err != nil {
-		w.logger.Error("Failed to send task result", "error", err)
+// sendTaskError tells the server a task couldn't change status, so it
+// doesn't wait for an update that will never come
+func (w *WebSocketClient) sendTaskError(ctx context.Context, conn *websocket.Conn, task *Tasukete, err error) {
+	msg := WebSocketMessage{
+		Type:    "task_error",
+		Payload: must(json.Marshal(taskErrorPayload{UUID: task.UUID, Status: task.Status(), Error: err.Error()})),
+	}
+	if err := w.writeJSON(ctx, conn, msg); err != nil {
+		loggerFromContext(ctx, w.logger).Error("Failed to send task error", "error", err)
 	}
 }
 
-func (w *WebSocketClient) sendTaskUpdate(conn *websocket.Conn, task *Tasukete) {
+func (w *WebSocketClient) sendTaskUpdate(ctx context.Context, conn *websocket.Conn, task *Tasukete) {
+	w.publishStatus(task)
 	msg := WebSocketMessage{
 		Type:    "task_update",
 		Payload: must(json.Marshal(task)),
 	}
-	if err := w.writeJSON(conn, msg); err != nil {
-		w.logger.Error("Failed to send task update", "error", err)
+	if err := w.writeJSON(ctx, conn, msg); err != nil {
+		loggerFromContext(ctx, w.logger).Error("Failed to send task update", "error", err)
 	}
 }
 
+// sendTaskResult sends the task and image as one multipart binary message,
+// or as fragments when that message would be larger than max_frame_size
 func (w *WebSocketClient) sendTaskResult(conn *websocket.Conn, task *Tasukete, result []byte) error {
+	size := w.resultFrameSize()
+	if size > 0 && len(result) > size {
+		return w.fragmentedSendTaskResult(conn, task, result)
+	}
+
 	var b bytes.Buffer
 	writer := multipart.NewWriter(&b)
 
@@ -253,7 +709,7 @@ func (w *WebSocketClient) sendTaskResult(conn *websocket.Conn, task *Tasukete, r
 	}
 
 	// Add file
-	fileField, err := writer.CreateFormFile("file", fmt.Sprintf("%s.png", task.UUID))
+	fileField, err := writer.CreateFormFile("file", taskFilename(task, w.config.Server.FilenameFormat))
 	if err != nil {
 		return err
 	}
@@ -266,9 +722,22 @@ func (w *WebSocketClient) sendTaskResult(conn *websocket.Conn, task *Tasukete, r
 	// Prepend the boundary to the message
 	boundaryPrefix := []byte(fmt.Sprintf("Boundary: %s\n", writer.Boundary()))
 	msg := append(boundaryPrefix, b.Bytes()...)
+	if size > 0 && len(msg) > size {
+		return w.fragmentedSendTaskResult(conn, task, result)
+	}
 
 	// Send as binary WebSocket message
-	return conn.WriteMessage(websocket.BinaryMessage, msg)
+	return w.sendResultFrame(msg)
+}
+
+// sendResultFrame queues a Boundary or Fragment result frame in the
+// negotiated subprotocol's envelope
+func (w *WebSocketClient) sendResultFrame(frame []byte) error {
+	data, err := encodeResultFrame(frame, w.Subprotocol())
+	if err != nil {
+		return err
+	}
+	return w.out.Load().enqueue(websocket.BinaryMessage, data)
 }
 
 // Helper function for JSON marshaling
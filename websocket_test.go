@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWebSocketClient builds a client whose outbound messages are kept
+// in its queue instead of being written to a connection
+func newTestWebSocketClient(t *testing.T, config *Config, opts ...WebSocketOption) *WebSocketClient {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewWebSocketClient(config, NewClient(config, logger), logger, opts...)
+	w.out.Store(newOutbox(256, &w.droppedMessages, logger))
+	return w
+}
+
+// useWebSocketServer points the config's task server WebSocket at a
+// NewTLSServer server
+func useWebSocketServer(config *Config, server *httptest.Server) {
+	config.Server.Host, config.Server.Port, _ = net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+}
+
+// sentMessages drains the queued outbound text messages
+func sentMessages(t *testing.T, w *WebSocketClient) []WebSocketMessage {
+	t.Helper()
+	var messages []WebSocketMessage
+	for _, queue := range []chan outboundMessage{w.out.Load().control, w.out.Load().queue} {
+		for len(queue) > 0 {
+			m := <-queue
+			if m.messageType != websocket.TextMessage {
+				continue
+			}
+			var msg WebSocketMessage
+			if err := json.Unmarshal(m.data, &msg); err != nil {
+				t.Fatalf("Failed to decode outbound message: %v", err)
+			}
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// sentResult decodes the first queued binary task result
+func sentResult(t *testing.T, w *WebSocketClient) (*Tasukete, []byte) {
+	t.Helper()
+	for {
+		select {
+		case m := <-w.out.Load().queue:
+			if m.messageType != websocket.BinaryMessage {
+				continue
+			}
+			header, body, ok := bytes.Cut(m.data, []byte("\n"))
+			if !ok {
+				t.Fatal("Result message has no boundary line")
+			}
+			boundary := strings.TrimPrefix(string(header), "Boundary: ")
+			form, err := multipart.NewReader(bytes.NewReader(body), boundary).ReadForm(10 << 20)
+			if err != nil {
+				t.Fatalf("Failed to parse result: %v", err)
+			}
+
+			var task Tasukete
+			if err := json.Unmarshal([]byte(form.Value["task"][0]), &task); err != nil {
+				t.Fatalf("Failed to decode result task: %v", err)
+			}
+			file, err := form.File["file"][0].Open()
+			if err != nil {
+				t.Fatalf("Failed to open result file: %v", err)
+			}
+			defer file.Close()
+			image, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatalf("Failed to read result file: %v", err)
+			}
+			return &task, image
+		default:
+			t.Fatal("No task result was sent")
+			return nil, nil
+		}
+	}
+}
+
+// TestHandleTTITask_ImageChecksum verifies the sent result carries the image's checksum
+func TestHandleTTITask_ImageChecksum(t *testing.T) {
+	mock := NewMockSwarmUIServer()
+	server := mock.Start()
+	defer server.Close()
+
+	config := MockConfig()
+	useMockAPI(config, server)
+
+	w := newTestWebSocketClient(t, config)
+	w.handleTTITask(context.Background(), nil, NewTasukete(TTI, "a cat", 1))
+
+	task, image := sentResult(t, w)
+	if task.ImageChecksum == "" {
+		t.Fatal("Expected image checksum on result task")
+	}
+	if err := VerifyImageChecksum(image, task); err != nil {
+		t.Errorf("Checksum verification failed: %v", err)
+	}
+
+	image[len(image)-1] ^= 0xff
+	if err := VerifyImageChecksum(image, task); err == nil {
+		t.Error("Expected corrupted image to fail verification")
+	}
+}
+
+// TestHandleTTITask_ResultSendFails verifies a task whose result can't be
+// sent fails instead of completing
+func TestHandleTTITask_ResultSendFails(t *testing.T) {
+	client := newMockGenerationClient(t, testPNG(t, 8, 8), nil)
+	w := newTestWebSocketClient(t, client.config)
+	w.client = client
+	w.out.Load().close()
+
+	task := NewTasukete(TTI, "a cat", 1)
+	w.handleTTITask(context.Background(), nil, task)
+	if task.Status() != StatusFailed {
+		t.Errorf("Expected task to fail when its result can't be sent, got %s", task.Status())
+	}
+}
+
+// TestHandleTTITask_Webhook verifies the webhook reports the completed task
+func TestHandleTTITask_Webhook(t *testing.T) {
+	var payload WebhookPayload
+	received := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		close(received)
+	}))
+	defer hook.Close()
+
+	client := newMockGenerationClient(t, testPNG(t, 8, 8), nil)
+	w := newTestWebSocketClient(t, client.config)
+	w.client = client
+
+	task := NewTasukete(TTI, "a cat", 1)
+	task.NotifyURL = hook.URL
+	w.handleTTITask(context.Background(), nil, task)
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Webhook was not called")
+	}
+	if payload.UUID != task.UUID || payload.Status != StatusCompleted {
+		t.Errorf("Unexpected webhook payload: %+v", payload)
+	}
+}
+
+// newScriptedServer answers the client's auth message with replies, sent
+// as raw text frames, then closes the connection
+func newScriptedServer(t *testing.T, replies ...string) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var auth WebSocketMessage
+		if conn.ReadJSON(&auth) != nil {
+			return
+		}
+		for _, reply := range replies {
+			conn.WriteMessage(websocket.TextMessage, []byte(reply))
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.ReadMessage() // wait for the client to go away
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConnect_ProtocolErrors(t *testing.T) {
+	authOK := `{"type":"auth_success","payload":{"token":"t"}}`
+	tests := []struct {
+		name        string
+		replies     []string
+		code        int
+		messageType string
+	}{
+		{"auth rejected", []string{`{"type":"auth_failed","payload":null}`}, ProtocolErrMissingAuthSuccess, "auth_failed"},
+		{"auth response not JSON", []string{`not json`}, ProtocolErrMalformedPayload, ""},
+		{"auth payload malformed", []string{`{"type":"auth_success","payload":[1,2]}`}, ProtocolErrMalformedPayload, "auth_success"},
+		{"second auth_success", []string{authOK, authOK}, ProtocolErrUnexpectedSequence, "auth_success"},
+		{"malformed task", []string{authOK, `{"type":"task","payload":"a cat"}`}, ProtocolErrMalformedPayload, "task"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := MockConfig()
+			useWebSocketServer(config, newScriptedServer(t, tt.replies...))
+			w := newTestWebSocketClient(t, config)
+
+			err := w.connect()
+			var protocolErr *WebSocketProtocolError
+			require.ErrorAs(t, err, &protocolErr)
+			assert.Equal(t, tt.code, protocolErr.Code)
+			assert.Equal(t, tt.messageType, protocolErr.MessageType)
+		})
+	}
+}
+
+func TestConnect_CountsUnknownMessageTypes(t *testing.T) {
+	config := MockConfig()
+	useWebSocketServer(config, newScriptedServer(t,
+		`{"type":"auth_success","payload":{"token":"t"}}`,
+		`{"type":"server_news","payload":{}}`,
+		`{"type":"server_news","payload":{}}`,
+	))
+	w := newTestWebSocketClient(t, config)
+
+	err := w.connect()
+	var protocolErr *WebSocketProtocolError
+	assert.False(t, errors.As(err, &protocolErr), "unknown message types are not fatal: %v", err)
+	assert.Equal(t, int64(2), w.UnknownMessageTypes())
+}
+
+// TestFailTask_AlreadyTerminal verifies the server hears about a task that
+// can't be failed because it already finished
+func TestFailTask_AlreadyTerminal(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	task := NewTasukete(TTI, "a cat", 1)
+	require.NoError(t, task.UpdateStatus(StatusProcessing))
+	require.NoError(t, task.UpdateStatus(StatusCompleted))
+
+	w.failTask(context.Background(), nil, task)
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "task_error", messages[0].Type)
+	var payload taskErrorPayload
+	require.NoError(t, json.Unmarshal(messages[0].Payload, &payload))
+	assert.Equal(t, task.UUID, payload.UUID)
+	assert.Equal(t, StatusCompleted, payload.Status)
+	assert.NotEmpty(t, payload.Error)
+}
+
+// TestHandleTTITask_CannotStart verifies a task that can't start processing
+// is reported to the server
+func TestHandleTTITask_CannotStart(t *testing.T) {
+	w := newTestWebSocketClient(t, MockConfig())
+	task := NewTasukete(TTI, "a cat", 1)
+	require.NoError(t, task.UpdateStatus(StatusFailed))
+
+	w.handleTTITask(context.Background(), nil, task)
+
+	messages := sentMessages(t, w)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "task_error", messages[0].Type)
+}